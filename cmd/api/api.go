@@ -13,9 +13,15 @@ import (
 
 	"github.com/Althaf66/Appointr/docs"
 	"github.com/Althaf66/Appointr/internal/auth"
+	"github.com/Althaf66/Appointr/internal/availability"
+	"github.com/Althaf66/Appointr/internal/earnings"
 	// "github.com/Althaf66/Appointr/internal/env"
 	"github.com/Althaf66/Appointr/internal/mailer"
+	"github.com/Althaf66/Appointr/internal/payments"
+	"github.com/Althaf66/Appointr/internal/sfu"
+	"github.com/Althaf66/Appointr/internal/signaling"
 	"github.com/Althaf66/Appointr/internal/store"
+	"github.com/Althaf66/Appointr/internal/video"
 	"github.com/Althaf66/Appointr/internal/websocket"
 	chi "github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -30,19 +36,26 @@ type application struct {
 	logger        *zap.SugaredLogger
 	mailer        mailer.Client
 	authenticator auth.Authenticator
+	payments      payments.Provider
 	wsManager     *websocket.WebSocketManager
+	signaling     *signaling.Hub
+	sfu           *sfu.SFU
+	rooms         video.Registry
+	availability  *availability.Service
+	earnings      *earnings.Service
 }
 
 type config struct {
-	addr          string
-	env           string
-	frontendURL   string
-	apiUrl        string
-	db            dbConfig
-	mail          mailconfig
-	auth          authConfig
-	stripeKey     string
-	stripeWebhook string
+	addr        string
+	env         string
+	frontendURL string
+	apiUrl      string
+	db          dbConfig
+	mail        mailconfig
+	auth        authConfig
+	payments    paymentsConfig
+	video       videoConfig
+	recording   recordingConfig
 }
 
 type dbConfig struct {
@@ -68,6 +81,47 @@ type basicConfig struct {
 	password string
 }
 
+type paymentsConfig struct {
+	stripeKey     string
+	webhookSecret string
+	// currency charges gigs that don't set their own
+	currency         string
+	allowedCountries []string
+	successURL       string
+	cancelURL        string
+	// provider is "stripe", or "fake" for local development
+	provider string
+	pricing  payments.Pricing
+	// commissionRate is the share of each session price the platform keeps
+	commissionRate float64
+	// lateCancelRefundRate is the share of the price mentees get back when
+	// they cancel inside the mentor's cancellation window or miss the meeting
+	lateCancelRefundRate float64
+	// stripeAPIURL points the Stripe client at another backend, like a
+	// local stub; empty means the real API
+	stripeAPIURL string
+}
+
+type videoConfig struct {
+	// registry is "postgres", or "memory" for a single instance
+	registry string
+	// maxParticipants is the most users a meeting can be sized for; rooms
+	// of more than two run through the SFU
+	maxParticipants int
+	// joinEarly is how long before a meeting starts its room can be joined
+	joinEarly time.Duration
+	// roomGrace is how long after a meeting ends its room stays open
+	roomGrace time.Duration
+	ice       video.ICEConfig
+}
+
+type recordingConfig struct {
+	// dir is where recorded tracks are saved, a directory per meeting
+	dir string
+	// retention is how long recordings are kept
+	retention time.Duration
+}
+
 type mailconfig struct {
 	exp       time.Duration
 	mailTrap  mailTrapConfig
@@ -97,17 +151,19 @@ func (app *application) mount() *chi.Mux {
 
 	r.Route("/ws", func(r chi.Router) {
 		r.Get("/messages/{conversationID}", app.HandleWebSocket(app.wsManager))
+		r.With(app.WebSocketAuthMiddleware).Get("/video/{roomID}", app.videoSignalingHandler)
 	})
 
 	r.Route("/video", func(r chi.Router) {
 		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 			w.Write([]byte("Video Meeting API"))
 		})
-		r.Post("/create-room", createRoomHandler)
-		r.Post("/join-room/{roomID}", joinRoomHandler)
-		r.Post("/signal", signalHandler)
-		r.Get("/room-status/{roomID}", roomStatusHandler)
-		r.Get("/pending-signals/{roomID}/{userID}", pendingSignalsHandler)
+		r.Group(func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Get("/room-status/{roomID}", app.roomStatusHandler)
+			r.Post("/ticket/{roomID}", app.createRoomTicketHandler)
+			r.Get("/ice-servers", app.getICEServersHandler)
+		})
 	})
 
 	r.Route("/v1", func(r chi.Router) {
@@ -146,6 +202,7 @@ func (app *application) mount() *chi.Mux {
 			r.Route("/{userID}", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
 				r.Get("/", app.getUserHandler)
+				r.Patch("/timezone", app.updateUserTimezoneHandler)
 			})
 		})
 		r.Route("/authentication", func(r chi.Router) {
@@ -157,6 +214,41 @@ func (app *application) mount() *chi.Mux {
 			r.Use(app.AuthTokenMiddleware)
 			r.Post("/create-checkout-session", app.createCheckoutSession)
 		})
+		r.Route("/payments", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Get("/", app.getPaymentsHandler)
+			r.Get("/{paymentID}/invoice", app.getPaymentInvoiceHandler)
+		})
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.requireAdmin)
+			r.Get("/events", app.getWebhookEventsHandler)
+			r.Post("/events/{eventID}/reprocess", app.reprocessWebhookEventHandler)
+		})
+		r.Route("/packages", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Post("/create", app.createPackageHandler)
+			r.Get("/u/{userID}", app.getPackagesByUserIDHandler)
+			r.Delete("/{packageID}", app.deactivatePackageHandler)
+			r.Post("/{packageID}/checkout", app.packageCheckoutHandler)
+		})
+		r.Route("/credits", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Get("/", app.getCreditsHandler)
+		})
+		r.Route("/coupons", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Post("/", app.createCouponHandler)
+			r.Get("/", app.getCouponsHandler)
+			r.Delete("/{couponID}", app.deactivateCouponHandler)
+		})
+		r.Route("/payouts", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.requireAdmin)
+			r.Get("/", app.getPayoutsHandler)
+			r.Post("/{payoutID}/approve", app.approvePayoutHandler)
+			r.Post("/{payoutID}/reject", app.rejectPayoutHandler)
+		})
 		r.Route("/messages", func(r chi.Router) {
 			r.Use(app.AuthTokenMiddleware)
 			r.Post("/conversations", app.createConversationHandler)
@@ -178,7 +270,11 @@ func (app *application) mount() *chi.Mux {
 			r.Group(func(r chi.Router) {
 				r.Use(app.mentorContextMiddleware)
 				r.Get("/{mentorID}", app.getMentorByIDHandler)
-				r.Patch("/{mentorID}", app.updateMentorHandler)
+				r.Get("/{mentorID}/availability", app.getMentorAvailabilityHandler)
+				r.With(app.requireMentorOrAdmin).Get("/{mentorID}/earnings", app.getMentorEarningsHandler)
+				r.With(app.requireMentorOrAdmin).Get("/{mentorID}/payouts", app.getMentorPayoutsHandler)
+				r.With(app.requireMentorOrAdmin).Post("/{mentorID}/payouts", app.createPayoutHandler)
+				r.With(app.requireMentorOrAdmin).Patch("/{mentorID}", app.updateMentorHandler)
 				r.Delete("/{mentorID}", app.deleteMentorHandler)
 			})
 		})
@@ -226,27 +322,70 @@ func (app *application) mount() *chi.Mux {
 			r.Patch("/{workingatID}", app.updateWorkingAtHandler)
 			r.Delete("/{workingatID}", app.deleteWorkingAtHandler)
 		})
-		r.Put("/meetings/paid/{meetingID}", app.updateMeetingPaidHandler)
 		r.Route("/meetings", func(r chi.Router) {
 			r.Use(app.AuthTokenMiddleware)
 			r.Post("/create", app.createMeetingHandler)
-			r.Get("/", app.getAllMeetingsHandler)
-			r.Get("/u/{userID}", app.getMeetingByUserIDHandler)
-			r.Get("/mentor-not-confirm/{mentorID}", app.getMeetingMentorNotConfirmHandler)
-			r.Get("/user-not-paid/{userID}", app.getMeetingUserNotPaidHandler)
-			r.Get("/user-not-completed/{userID}", app.getMeetingUserNotCompletedHandler)
-			r.Get("/mentor-not-completed/{mentorID}", app.getMeetingMentorNotCompletedHandler)
-			r.Put("/confirm/{meetingID}", app.updateMeetingConfirmHandler)
-			r.Put("/completed/{meetingID}", app.updateMeetingCompletedHandler)
-			r.Put("/link/{meetingID}", app.updateLinkHandler)
-			r.Delete("/{meetingID}", app.deleteMeetingHandler)
+			r.With(app.requireAdmin).Get("/", app.getAllMeetingsHandler)
+			r.With(app.requireSelfOrAdmin("userID")).Get("/u/{userID}", app.getMeetingByUserIDHandler)
+			r.With(app.requireSelfOrAdmin("mentorID")).Get("/mentor-not-confirm/{mentorID}", app.getMeetingMentorNotConfirmHandler)
+			r.With(app.requireSelfOrAdmin("userID")).Get("/user-not-paid/{userID}", app.getMeetingUserNotPaidHandler)
+			r.With(app.requireSelfOrAdmin("userID")).Get("/user-not-completed/{userID}", app.getMeetingUserNotCompletedHandler)
+			r.With(app.requireSelfOrAdmin("mentorID")).Get("/mentor-not-completed/{mentorID}", app.getMeetingMentorNotCompletedHandler)
+			r.Group(func(r chi.Router) {
+				r.Use(app.meetingContextMiddleware)
+
+				r.With(app.authorizeMeeting(meetingReader)).Get("/{meetingID}", app.getMeetingHandler)
+				r.With(app.authorizeMeeting(meetingReader)).Get("/history/{meetingID}", app.getMeetingHistoryHandler)
+				r.With(app.requireAdmin).Delete("/{meetingID}", app.deleteMeetingHandler)
+
+				r.With(app.authorizeMeeting(meetingMentor)).Put("/confirm/{meetingID}", app.updateMeetingConfirmHandler)
+				r.With(app.authorizeMeeting(meetingMentor)).Put("/completed/{meetingID}", app.updateMeetingCompletedHandler)
+				r.With(app.requireAdmin).Put("/paid/{meetingID}", app.updateMeetingPaidHandler)
+				r.With(app.authorizeMeeting(meetingMentor)).Put("/link/{meetingID}", app.updateLinkHandler)
+				r.Put("/status/{meetingID}", app.updateMeetingStatusHandler)
+
+				r.With(app.authorizeMeeting(meetingParticipant)).Post("/{meetingID}/cancel", app.cancelMeetingHandler)
+				r.With(app.authorizeMeeting(meetingReader)).Post("/{meetingID}/refund", app.refundMeetingHandler)
+				r.With(app.authorizeMeeting(meetingReader)).Get("/{meetingID}/reschedule", app.getReschedulesHandler)
+				r.Get("/{meetingID}/room", app.getMeetingRoomHandler)
+				r.With(app.authorizeMeeting(meetingMentor)).Put("/{meetingID}/participants", app.setMaxParticipantsHandler)
+				r.Get("/{meetingID}/attendees", app.getAttendeesHandler)
+				r.With(app.authorizeMeeting(meetingMentor)).Post("/{meetingID}/attendees", app.addAttendeeHandler)
+				r.Delete("/{meetingID}/attendees/{userID}", app.removeAttendeeHandler)
+				r.With(app.authorizeMeeting(meetingReader)).Get("/{meetingID}/recording", app.getRecordingConsentHandler)
+				r.With(app.authorizeMeeting(meetingParticipant)).Put("/{meetingID}/recording", app.setRecordingConsentHandler)
+				r.With(app.authorizeMeeting(meetingReader)).Get("/{meetingID}/recordings", app.getRecordingsHandler)
+				r.With(app.authorizeMeeting(meetingReader)).Get("/{meetingID}/recordings/{recordingID}", app.downloadRecordingHandler)
+				r.With(app.authorizeMeeting(meetingParticipant)).Post("/{meetingID}/reschedule", app.proposeRescheduleHandler)
+				r.With(app.authorizeMeeting(meetingParticipant)).Post("/{meetingID}/reschedule/{rescheduleID}/accept", app.acceptRescheduleHandler)
+				r.With(app.authorizeMeeting(meetingParticipant)).Post("/{meetingID}/reschedule/{rescheduleID}/decline", app.declineRescheduleHandler)
+			})
 		})
 		r.Route("/bookingslots", func(r chi.Router) {
 			r.Use(app.AuthTokenMiddleware)
-			r.Post("/create", app.createBookingSlotHandler) // POST /bookingslots/create
-			// r.Get("/", getBookingSlots)          // GET /bookingslots
-			// r.Patch("/{id}", updateBookingSlot)  // PATCH /bookingslots/{id}
-			// r.Delete("/{id}", deleteBookingSlot) // DELETE /bookingslots/{id}
+			r.Post("/create", app.createBookingSlotHandler)
+			r.Get("/", app.getBookingSlotsHandler)
+			r.Put("/", app.replaceBookingSlotsHandler)
+			r.Get("/u/{id}", app.getBookingSlotsByUserIDHandler)
+			r.Group(func(r chi.Router) {
+				r.Use(app.bookingSlotContextMiddleware)
+				r.Patch("/{bookingSlotID}", app.updateBookingSlotHandler)
+				r.Delete("/{bookingSlotID}", app.deleteBookingSlotHandler)
+			})
+		})
+		r.Route("/overrides", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Get("/", app.getOverridesHandler)
+			r.Post("/block", app.blockTimeHandler)
+			r.Post("/extra", app.addExtraHoursHandler)
+			r.Get("/holiday-calendars", app.getHolidayCalendarsHandler)
+			r.Put("/holiday-calendars", app.setHolidayCalendarsHandler)
+			r.With(app.overrideContextMiddleware).Delete("/{overrideID}", app.deleteOverrideHandler)
+		})
+		r.Route("/holidays", func(r chi.Router) {
+			r.Get("/", app.getHolidaysHandler)
+			r.With(app.BasicAuthMiddleware()).Post("/", app.createHolidayHandler)
+			r.With(app.BasicAuthMiddleware()).Delete("/{holidayID}", app.deleteHolidayHandler)
 		})
 	})
 
@@ -267,6 +406,11 @@ func (app *application) run(mux http.Handler) error {
 
 	shutdown := make(chan error)
 
+	ctx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go app.closeExpiredVideoRooms(ctx, time.Minute)
+	go app.deleteExpiredRecordings(ctx, time.Hour)
+
 	go func() {
 		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
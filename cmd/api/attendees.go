@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Althaf66/Appointr/internal/store"
+	chi "github.com/go-chi/chi/v5"
+)
+
+// SetMaxParticipantsPayload sizes a meeting's video room, counting the
+// mentor and the mentee. Past two it becomes a group session.
+type SetMaxParticipantsPayload struct {
+	MaxParticipants int `json:"max_participants" validate:"required,min=2"`
+}
+
+// AddAttendeePayload invites a user to a group meeting.
+type AddAttendeePayload struct {
+	UserID int64 `json:"user_id" validate:"required"`
+}
+
+// setMaxParticipantsHandler godoc
+//
+//	@Summary		Size a meeting
+//	@Description	Set how many people the meeting's video room holds, mentor and mentee included. Only before the meeting is paid; its mentor only.
+//	@Tags			meetings
+//	@Accept			json
+//	@Produce		json
+//	@Param			meetingID	path		int64						true	"Meeting ID"
+//	@Param			payload		body		SetMaxParticipantsPayload	true	"Size"
+//	@Success		200			{object}	store.Meetings
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meetings/{meetingID}/participants [put]
+func (app *application) setMaxParticipantsHandler(w http.ResponseWriter, r *http.Request) {
+	meeting := getMeetingFromCtx(r)
+
+	var payload SetMaxParticipantsPayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.MaxParticipants > app.config.video.maxParticipants {
+		app.badRequestResponse(w, r, fmt.Errorf("meetings hold at most %d participants", app.config.video.maxParticipants))
+		return
+	}
+
+	loc, err := viewerLocation(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Attendees.SetMaxParticipants(r.Context(), meeting.ID, payload.MaxParticipants); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrMeetingLocked), errors.Is(err, store.ErrMeetingFull):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	meeting.MaxParticipants = payload.MaxParticipants
+
+	meeting.Localize(loc)
+	if err := JsonResponse(w, http.StatusOK, meeting); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getAttendeesHandler godoc
+//
+//	@Summary		Get a meeting's attendees
+//	@Description	Get the users invited to a group meeting besides its mentee. Its participants, attendees or admins only.
+//	@Tags			meetings
+//	@Accept			json
+//	@Produce		json
+//	@Param			meetingID	path		int64	true	"Meeting ID"
+//	@Success		200			{array}		store.Attendee
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meetings/{meetingID}/attendees [get]
+func (app *application) getAttendeesHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+	meeting := getMeetingFromCtx(r)
+
+	if !user.IsAdmin {
+		ok, err := app.attendsMeeting(r.Context(), meeting, user.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if !ok {
+			app.forbidden(w, r)
+			return
+		}
+	}
+
+	attendees, err := app.store.Attendees.GetAttendees(r.Context(), meeting.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := JsonResponse(w, http.StatusOK, attendees); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// addAttendeeHandler godoc
+//
+//	@Summary		Invite to a meeting
+//	@Description	Invite a user to a group meeting, taking one of its places. Its mentor only.
+//	@Tags			meetings
+//	@Accept			json
+//	@Produce		json
+//	@Param			meetingID	path		int64				true	"Meeting ID"
+//	@Param			payload		body		AddAttendeePayload	true	"Attendee"
+//	@Success		201			{object}	store.Attendee
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meetings/{meetingID}/attendees [post]
+func (app *application) addAttendeeHandler(w http.ResponseWriter, r *http.Request) {
+	meeting := getMeetingFromCtx(r)
+
+	var payload AddAttendeePayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if isMeetingParticipant(meeting, payload.UserID) {
+		app.badRequestResponse(w, r, errors.New("the mentor and the mentee already attend the meeting"))
+		return
+	}
+	if !invitable(meeting.Status) {
+		app.conflictResponse(w, r, fmt.Errorf("meeting is %s, attendees can't be invited", meeting.Status))
+		return
+	}
+
+	if _, err := app.store.Users.GetByID(r.Context(), payload.UserID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	attendee := &store.Attendee{
+		MeetingID: meeting.ID,
+		UserID:    payload.UserID,
+	}
+	if err := app.store.Attendees.AddAttendee(r.Context(), attendee); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrMeetingFull), errors.Is(err, store.ErrDuplicateAttendee):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := JsonResponse(w, http.StatusCreated, attendee); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// removeAttendeeHandler godoc
+//
+//	@Summary		Remove an attendee
+//	@Description	Take an attendee off a group meeting, freeing their place. The meeting's mentor, or attendees removing themselves.
+//	@Tags			meetings
+//	@Accept			json
+//	@Produce		json
+//	@Param			meetingID	path		int64	true	"Meeting ID"
+//	@Param			userID		path		int64	true	"Attendee's user ID"
+//	@Success		204			{object}	string
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meetings/{meetingID}/attendees/{userID} [delete]
+func (app *application) removeAttendeeHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+	meeting := getMeetingFromCtx(r)
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !meetingMentor(user, meeting) && user.ID != userID {
+		app.forbidden(w, r)
+		return
+	}
+
+	if err := app.store.Attendees.RemoveAttendee(r.Context(), meeting.ID, userID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// attendsMeeting reports whether userID takes part in meeting, as its
+// mentor, its mentee or an invited attendee.
+func (app *application) attendsMeeting(ctx context.Context, meeting *store.Meetings, userID int64) (bool, error) {
+	if isMeetingParticipant(meeting, userID) {
+		return true, nil
+	}
+	return app.store.Attendees.IsAttendee(ctx, meeting.ID, userID)
+}
+
+// invitable reports whether meetings in status can still take attendees.
+func invitable(status store.MeetingStatus) bool {
+	switch status {
+	case store.MeetingRequested, store.MeetingConfirmed, store.MeetingPaid, store.MeetingInProgress:
+		return true
+	}
+	return false
+}
@@ -19,6 +19,7 @@ type RegisterUserPayload struct {
 	Username string `json:"username" validate:"required,max=100"`
 	Email    string `json:"email" validate:"required,email,max=255"`
 	Password string `json:"password" validate:"required,min=3,max=72"`
+	Timezone string `json:"timezone" validate:"omitempty,timezone"`
 }
 
 type UserWithToken struct {
@@ -55,6 +56,7 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 	user := &store.User{
 		Username: payload.Username,
 		Email:    payload.Email,
+		Timezone: payload.Timezone,
 	}
 
 	if err := user.Password.Set(payload.Password); err != nil {
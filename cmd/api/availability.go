@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Althaf66/Appointr/internal/availability"
+)
+
+const (
+	availabilityDateLayout = "2006-01-02"
+	maxAvailabilityDays    = 62
+)
+
+// getMentorAvailabilityHandler godoc
+//
+//	@Summary		Get mentor availability
+//	@Description	Expands the mentor's booking slots into free time slots, excluding booked meetings, time off and holidays
+//	@Tags			mentor
+//	@Accept			json
+//	@Produce		json
+//	@Param			mentorID	path		int64	true	"Mentor ID"
+//	@Param			from		query		string	false	"First day (YYYY-MM-DD), defaults to today"
+//	@Param			to			query		string	false	"Last day (YYYY-MM-DD), defaults to a week after from"
+//	@Param			duration	query		int		false	"Slot length in minutes, defaults to 30"
+//	@Param			tz			query		string	false	"IANA timezone to render in, defaults to the caller's"
+//	@Success		200			{object}	[]availability.Slot
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/mentors/{mentorID}/availability [get]
+func (app *application) getMentorAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	mentor := getMentorFromCtx(r)
+
+	loc, err := viewerLocation(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	query := r.URL.Query()
+
+	now := time.Now().In(loc)
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if v := query.Get("from"); v != "" {
+		t, err := time.ParseInLocation(availabilityDateLayout, v, loc)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		from = t
+	}
+
+	to := from.AddDate(0, 0, 6)
+	if v := query.Get("to"); v != "" {
+		t, err := time.ParseInLocation(availabilityDateLayout, v, loc)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		to = t
+	}
+
+	if to.Before(from) {
+		app.badRequestResponse(w, r, errors.New("to must not be before from"))
+		return
+	}
+	if to.Sub(from) > maxAvailabilityDays*24*time.Hour {
+		app.badRequestResponse(w, r, errors.New("date range is too large"))
+		return
+	}
+
+	duration := availability.DefaultDuration
+	if v := query.Get("duration"); v != "" {
+		minutes, err := strconv.Atoi(v)
+		if err != nil || minutes < 15 || minutes > 8*60 {
+			app.badRequestResponse(w, r, errors.New("duration must be between 15 and 480 minutes"))
+			return
+		}
+		duration = time.Duration(minutes) * time.Minute
+	}
+
+	// to is an inclusive day in the viewer's timezone
+	slots, err := app.availability.FreeSlots(r.Context(), mentor.Userid, from, to.AddDate(0, 0, 1), duration)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	for i := range slots {
+		slots[i] = slots[i].In(loc)
+	}
+
+	err = JsonResponse(w, http.StatusOK, slots)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
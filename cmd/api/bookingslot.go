@@ -1,29 +1,53 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"strconv"
 
+	"github.com/Althaf66/Appointr/internal/availability"
 	"github.com/Althaf66/Appointr/internal/store"
+	chi "github.com/go-chi/chi/v5"
 )
 
+type bookingSlotKey string
+
+const bookingSlotCtx bookingSlotKey = "bookingslot"
+
 type RegisterBookingSlotPayload struct {
-	Days        []string `json:"days"`
-	StartTime   string   `json:"start_time"`
-	StartPeriod string   `json:"start_period"`
-	EndTime     string   `json:"end_time"`
-	EndPeriod   string   `json:"end_period"`
+	Days        []string `json:"days" validate:"required,min=1,dive,oneof=Sunday Monday Tuesday Wednesday Thursday Friday Saturday"`
+	StartTime   string   `json:"start_time" validate:"required"`
+	StartPeriod string   `json:"start_period" validate:"required,oneof=AM PM"`
+	EndTime     string   `json:"end_time" validate:"required"`
+	EndPeriod   string   `json:"end_period" validate:"required,oneof=AM PM"`
+	Timezone    string   `json:"timezone" validate:"omitempty,timezone"`
+}
+
+type UpdateBookingSlotPayload struct {
+	Days        *[]string `json:"days" validate:"omitempty,min=1,dive,oneof=Sunday Monday Tuesday Wednesday Thursday Friday Saturday"`
+	StartTime   *string   `json:"start_time"`
+	StartPeriod *string   `json:"start_period" validate:"omitempty,oneof=AM PM"`
+	EndTime     *string   `json:"end_time"`
+	EndPeriod   *string   `json:"end_period" validate:"omitempty,oneof=AM PM"`
+	Timezone    *string   `json:"timezone" validate:"omitempty,timezone"`
+}
+
+type ReplaceBookingSlotsPayload struct {
+	Slots []RegisterBookingSlotPayload `json:"slots" validate:"dive"`
 }
 
 // createBookingSlotHandler godoc
 //
 //	@Summary		Create a new booking slot
-//	@Description	Create a new booking slot
+//	@Description	Create a new booking slot. A slot ending at or before its start time runs overnight. Slots can't overlap, whatever their timezones.
 //	@Tags			booking
 //	@Accept			json
 //	@Produce		json
 //	@Param			booking	slot		body	RegisterBookingSlotPayload	true	"Booking Slot"
 //	@Success		200		{object}	error
 //	@Failure		400		{object}	error
+//	@Failure		409		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/bookingslots/create [post]
@@ -34,24 +58,331 @@ func (app *application) createBookingSlotHandler(w http.ResponseWriter, r *http.
 	err := ReadJSON(w, r, &payload)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
+		return
 	}
 
-	bookingslot := &store.BookingSlot{
-		UserID:      user.ID,
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	bookingslot := newBookingSlot(user.ID, payload, app.slotTimezone(r.Context(), user))
+
+	check := &scheduleCheck{}
+	err = app.store.BookingSlot.CreateBookingSlot(r.Context(), bookingslot, check.validate)
+	if err != nil {
+		switch {
+		case check.err != nil:
+			app.bookingSlotValidationError(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = JsonResponse(w, http.StatusCreated, bookingslot)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getBookingSlotsHandler godoc
+//
+//	@Summary		Get my booking slots
+//	@Description	Get the weekly booking slots of the authenticated mentor
+//	@Tags			booking
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]store.BookingSlot
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bookingslots [get]
+func (app *application) getBookingSlotsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	slots, err := app.store.BookingSlot.GetBookingSlotsByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = JsonResponse(w, http.StatusOK, slots)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getBookingSlotsByUserIDHandler godoc
+//
+//	@Summary		Get booking slots by mentor
+//	@Description	Get the weekly booking slots of a mentor by their user ID
+//	@Tags			booking
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int64	true	"Mentor user ID"
+//	@Success		200	{object}	[]store.BookingSlot
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bookingslots/u/{id} [get]
+func (app *application) getBookingSlotsByUserIDHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	slots, err := app.store.BookingSlot.GetBookingSlotsByUserID(r.Context(), userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = JsonResponse(w, http.StatusOK, slots)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// updateBookingSlotHandler godoc
+//
+//	@Summary		Update a booking slot
+//	@Description	Update one of the authenticated mentor's booking slots
+//	@Tags			booking
+//	@Accept			json
+//	@Produce		json
+//	@Param			bookingSlotID	path		int64						true	"Booking slot ID"
+//	@Param			payload			body		UpdateBookingSlotPayload	true	"Booking slot payload"
+//	@Success		200				{object}	store.BookingSlot
+//	@Failure		400				{object}	error
+//	@Failure		401				{object}	error
+//	@Failure		403				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		409				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bookingslots/{bookingSlotID} [patch]
+func (app *application) updateBookingSlotHandler(w http.ResponseWriter, r *http.Request) {
+	slot := getBookingSlotFromCtx(r)
+
+	var payload UpdateBookingSlotPayload
+	err := ReadJSON(w, r, &payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.Days != nil {
+		slot.Days = *payload.Days
+	}
+	if payload.StartTime != nil {
+		slot.StartTime = *payload.StartTime
+	}
+	if payload.StartPeriod != nil {
+		slot.StartPeriod = *payload.StartPeriod
+	}
+	if payload.EndTime != nil {
+		slot.EndTime = *payload.EndTime
+	}
+	if payload.EndPeriod != nil {
+		slot.EndPeriod = *payload.EndPeriod
+	}
+	if payload.Timezone != nil {
+		slot.Timezone = *payload.Timezone
+	}
+
+	check := &scheduleCheck{}
+	err = app.store.BookingSlot.UpdateBookingSlot(r.Context(), slot, check.validate)
+	if err != nil {
+		switch {
+		case check.err != nil:
+			app.bookingSlotValidationError(w, r, err)
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = JsonResponse(w, http.StatusOK, slot)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// deleteBookingSlotHandler godoc
+//
+//	@Summary		Delete a booking slot
+//	@Description	Delete one of the authenticated mentor's booking slots
+//	@Tags			booking
+//	@Accept			json
+//	@Produce		json
+//	@Param			bookingSlotID	path		int64	true	"Booking slot ID"
+//	@Success		200				{object}	nil
+//	@Failure		400				{object}	error
+//	@Failure		401				{object}	error
+//	@Failure		403				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bookingslots/{bookingSlotID} [delete]
+func (app *application) deleteBookingSlotHandler(w http.ResponseWriter, r *http.Request) {
+	slot := getBookingSlotFromCtx(r)
+
+	err := app.store.BookingSlot.DeleteBookingSlot(r.Context(), slot.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// replaceBookingSlotsHandler godoc
+//
+//	@Summary		Set my weekly schedule
+//	@Description	Replace all of the authenticated mentor's booking slots in one go
+//	@Tags			booking
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ReplaceBookingSlotsPayload	true	"Weekly schedule"
+//	@Success		200		{object}	[]store.BookingSlot
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/bookingslots [put]
+func (app *application) replaceBookingSlotsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	var payload ReplaceBookingSlotsPayload
+	err := ReadJSON(w, r, &payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	timezone := app.slotTimezone(r.Context(), user)
+	slots := make([]*store.BookingSlot, 0, len(payload.Slots))
+	for _, p := range payload.Slots {
+		slots = append(slots, newBookingSlot(user.ID, p, timezone))
+	}
+
+	if err := availability.ValidateRules(slots); err != nil {
+		app.bookingSlotValidationError(w, r, err)
+		return
+	}
+
+	err = app.store.BookingSlot.ReplaceBookingSlots(r.Context(), user.ID, slots)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = JsonResponse(w, http.StatusOK, slots)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// slotTimezone is the zone slots default to: the mentor's own, falling back
+// to the user's.
+func (app *application) slotTimezone(ctx context.Context, user *store.User) string {
+	if mentor, err := app.store.Mentor.GetMentorByUserID(ctx, user.ID); err == nil {
+		return mentor.Timezone
+	}
+	return user.Timezone
+}
+
+func newBookingSlot(userID int64, payload RegisterBookingSlotPayload, defaultTimezone string) *store.BookingSlot {
+	timezone := payload.Timezone
+	if timezone == "" {
+		timezone = defaultTimezone
+	}
+
+	return &store.BookingSlot{
+		UserID:      userID,
 		Days:        payload.Days,
 		StartTime:   payload.StartTime,
 		StartPeriod: payload.StartPeriod,
 		EndTime:     payload.EndTime,
 		EndPeriod:   payload.EndPeriod,
+		Timezone:    timezone,
 	}
+}
 
-	err = app.store.BookingSlot.CreateBookingSlot(r.Context(), bookingslot)
-	if err != nil {
+// scheduleCheck validates a mentor's schedule inside the store's
+// transaction, keeping what was wrong with it to answer with.
+type scheduleCheck struct {
+	err error
+}
+
+func (c *scheduleCheck) validate(slots []*store.BookingSlot) error {
+	c.err = availability.ValidateRules(slots)
+	return c.err
+}
+
+func (app *application) bookingSlotValidationError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, availability.ErrOverlappingSlots):
+		app.conflictResponse(w, r, err)
+	default:
 		app.badRequestResponse(w, r, err)
 	}
+}
 
-	err = JsonResponse(w, http.StatusCreated, bookingslot)
-	if err != nil {
-		app.internalServerError(w, r, err)
-	}
+func (app *application) bookingSlotContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "bookingSlotID"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+
+		slot, err := app.store.BookingSlot.GetBookingSlotByID(ctx, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		// only the owning mentor may touch a slot
+		if slot.UserID != getUserfromCtx(r).ID {
+			app.forbidden(w, r)
+			return
+		}
+
+		ctx = context.WithValue(ctx, bookingSlotCtx, slot)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getBookingSlotFromCtx(r *http.Request) *store.BookingSlot {
+	slot, _ := r.Context().Value(bookingSlotCtx).(*store.BookingSlot)
+	return slot
 }
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Althaf66/Appointr/internal/store"
+	chi "github.com/go-chi/chi/v5"
+)
+
+// CreateCouponPayload describes a discount code. Percent coupons need
+// percent_off and fixed ones amount_off, in the currency (which defaults to
+// the checkout currency). Admins may leave out mentor_id for a coupon that
+// works for every mentor; mentors always create coupons for themselves.
+type CreateCouponPayload struct {
+	Code           string     `json:"code" validate:"required,alphanum,min=3,max=32"`
+	Kind           string     `json:"kind" validate:"required,oneof=percent fixed"`
+	PercentOff     *int       `json:"percent_off" validate:"required_if=Kind percent,excluded_unless=Kind percent,omitempty,min=1,max=100"`
+	AmountOff      *float64   `json:"amount_off" validate:"required_if=Kind fixed,excluded_unless=Kind fixed,omitempty,gt=0"`
+	Currency       string     `json:"currency" validate:"excluded_unless=Kind fixed,omitempty,len=3,alpha"`
+	MentorID       *int64     `json:"mentor_id"`
+	MaxRedemptions *int       `json:"max_redemptions" validate:"omitempty,min=1"`
+	MaxPerUser     *int       `json:"max_per_user" validate:"omitempty,min=1"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+}
+
+// createCouponHandler godoc
+//
+//	@Summary		Create a coupon
+//	@Description	Create a discount code mentees can apply at checkout; mentors create them for their own sessions, admins for any mentor or all of them
+//	@Tags			coupons
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateCouponPayload	true	"Coupon"
+//	@Success		201		{object}	store.Coupon
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/coupons [post]
+func (app *application) createCouponHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	var payload CreateCouponPayload
+	err := ReadJSON(w, r, &payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.ExpiresAt != nil && !payload.ExpiresAt.After(time.Now()) {
+		app.badRequestResponse(w, r, errors.New("expires_at must be in the future"))
+		return
+	}
+
+	mentorID := payload.MentorID
+	if !user.IsAdmin {
+		if _, err := app.store.Mentor.GetMentorByUserID(r.Context(), user.ID); err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.forbidden(w, r)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+		if mentorID != nil && *mentorID != user.ID {
+			app.forbidden(w, r)
+			return
+		}
+		mentorID = &user.ID
+	}
+
+	coupon := &store.Coupon{
+		Code:           strings.ToUpper(payload.Code),
+		MentorID:       mentorID,
+		Kind:           payload.Kind,
+		PercentOff:     payload.PercentOff,
+		AmountOff:      payload.AmountOff,
+		MaxRedemptions: payload.MaxRedemptions,
+		MaxPerUser:     payload.MaxPerUser,
+		ExpiresAt:      payload.ExpiresAt,
+		CreatedBy:      &user.ID,
+	}
+	if payload.Kind == store.CouponFixed {
+		currency := app.config.payments.currency
+		if payload.Currency != "" {
+			currency = strings.ToLower(payload.Currency)
+		}
+		coupon.Currency = &currency
+	}
+
+	if err := app.store.Coupons.CreateCoupon(r.Context(), coupon); err != nil {
+		switch {
+		case errors.Is(err, store.ErrDuplicateCoupon):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := JsonResponse(w, http.StatusCreated, coupon); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getCouponsHandler godoc
+//
+//	@Summary		List coupons
+//	@Description	Admins get every coupon, mentors the ones for their own sessions, newest first
+//	@Tags			coupons
+//	@Accept			json
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit, admins only"	default(20)
+//	@Param			offset	query		int	false	"Offset, admins only"	default(0)
+//	@Success		200		{array}		store.Coupon
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/coupons [get]
+func (app *application) getCouponsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	var (
+		coupons []*store.Coupon
+		err     error
+	)
+	if user.IsAdmin {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit <= 0 || limit > 100 {
+			limit = 20
+		}
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		if offset < 0 {
+			offset = 0
+		}
+		coupons, err = app.store.Coupons.GetCoupons(r.Context(), limit, offset)
+	} else {
+		coupons, err = app.store.Coupons.GetCouponsByMentorID(r.Context(), user.ID)
+	}
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := JsonResponse(w, http.StatusOK, coupons); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// deactivateCouponHandler godoc
+//
+//	@Summary		Deactivate a coupon
+//	@Description	Stop a coupon from being applied again; meetings it was applied to keep their discount. Its mentor or admins only.
+//	@Tags			coupons
+//	@Accept			json
+//	@Produce		json
+//	@Param			couponID	path		int64	true	"Coupon ID"
+//	@Success		204			{object}	string
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/coupons/{couponID} [delete]
+func (app *application) deactivateCouponHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "couponID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	coupon, err := app.store.Coupons.GetCouponByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if !user.IsAdmin && (coupon.MentorID == nil || *coupon.MentorID != user.ID) {
+		app.forbidden(w, r)
+		return
+	}
+
+	if err := app.store.Coupons.DeactivateCoupon(r.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Althaf66/Appointr/internal/store"
+)
+
+func TestCreateCouponHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{
+			name:     "user without a mentor profile",
+			body:     `{"code": "WELCOME10", "kind": "percent", "percent_off": 10}`,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "user without a mentor profile, for another mentor",
+			body:     `{"code": "WELCOME10", "kind": "percent", "percent_off": 10, "mentor_id": 2}`,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "percent coupon without percent_off",
+			body:     `{"code": "WELCOME10", "kind": "percent"}`,
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, _ := newTestApplication(t, "")
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/coupons", strings.NewReader(tt.body))
+			req = withUser(req, &store.User{ID: 1})
+			rr := httptest.NewRecorder()
+			app.createCouponHandler(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("got status %d, want %d: %s", rr.Code, tt.wantCode, rr.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+const maxEarningsDays = 366
+
+// getMentorEarningsHandler godoc
+//
+//	@Summary		Get mentor earnings
+//	@Description	Gross, platform fee and net earnings of the mentor per currency, with daily and monthly breakdowns. Net earned on sessions that haven't happened yet is pending; the rest is available. Mentor or admins only.
+//	@Tags			mentor
+//	@Accept			json
+//	@Produce		json
+//	@Param			mentorID	path		int64	true	"Mentor ID"
+//	@Param			from		query		string	false	"First day (YYYY-MM-DD), defaults to the first of this month"
+//	@Param			to			query		string	false	"Last day (YYYY-MM-DD), defaults to today"
+//	@Param			tz			query		string	false	"IANA timezone days are counted in, defaults to the caller's"
+//	@Success		200			{object}	earnings.Report
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/mentors/{mentorID}/earnings [get]
+func (app *application) getMentorEarningsHandler(w http.ResponseWriter, r *http.Request) {
+	mentor := getMentorFromCtx(r)
+
+	loc, err := viewerLocation(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	query := r.URL.Query()
+
+	now := time.Now().In(loc)
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	if v := query.Get("from"); v != "" {
+		t, err := time.ParseInLocation(availabilityDateLayout, v, loc)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		from = t
+	}
+
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if v := query.Get("to"); v != "" {
+		t, err := time.ParseInLocation(availabilityDateLayout, v, loc)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		to = t
+	}
+
+	if to.Before(from) {
+		app.badRequestResponse(w, r, errors.New("to must not be before from"))
+		return
+	}
+	if to.Sub(from) > maxEarningsDays*24*time.Hour {
+		app.badRequestResponse(w, r, errors.New("date range is too large"))
+		return
+	}
+
+	// to is an inclusive day in the viewer's timezone
+	report, err := app.earnings.Report(r.Context(), mentor.Userid, from, to.AddDate(0, 0, 1), loc)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := JsonResponse(w, http.StatusOK, report); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/Althaf66/Appointr/internal/store"
 	chi "github.com/go-chi/chi/v5"
@@ -20,6 +21,7 @@ type RegisterGigPayload struct {
 	Title       string   `json:"title" validate:"required,max=100"`
 	Description string   `json:"description" validate:"required"`
 	Amount      float64  `json:"amount" validate:"required"`
+	Currency    string   `json:"currency" validate:"omitempty,len=3,alpha"`
 	Expertise   string   `json:"expertise" validate:"required"`
 	Discipline  []string `json:"discipline" validate:"required"`
 }
@@ -27,6 +29,7 @@ type RegisterGigPayload struct {
 type UpdateGigPayload struct {
 	Title       *string   `json:"title"`
 	Amount      *float64  `json:"amount"`
+	Currency    *string   `json:"currency" validate:"omitempty,len=3,alpha"`
 	Description *string   `json:"description"`
 	Expertise   *string   `json:"expertise"`
 	Discipline  *[]string `json:"discipline"`
@@ -61,11 +64,17 @@ func (app *application) createGigHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	currency := app.config.payments.currency
+	if payload.Currency != "" {
+		currency = strings.ToLower(payload.Currency)
+	}
+
 	gig := &store.Gig{
 		Userid: user.ID,
 		// Mentorid:    payload.MentorID,
 		Title:       payload.Title,
 		Amount:      payload.Amount,
+		Currency:    currency,
 		Description: payload.Description,
 		Expertise:   payload.Expertise,
 		Discipline:  payload.Discipline,
@@ -191,7 +200,7 @@ func (app *application) getGigByIDHandler(w http.ResponseWriter, r *http.Request
 // updateGigHandler godoc
 //
 //	@Summary		Update gig
-//	@Description	Update gig
+//	@Description	Update gig; only its mentor or an admin may
 //	@Tags			gig
 //	@Accept			json
 //	@Produce		json
@@ -200,10 +209,14 @@ func (app *application) getGigByIDHandler(w http.ResponseWriter, r *http.Request
 //	@Success		200		{object}	store.Gig
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/gigs/{gigID} [patch]
 func (app *application) updateGigHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
 	gigID, err := strconv.ParseInt(chi.URLParam(r, "gigID"), 10, 64)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
@@ -221,6 +234,11 @@ func (app *application) updateGigHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if gig.Userid != user.ID && !user.IsAdmin {
+		app.forbidden(w, r)
+		return
+	}
+
 	var payload UpdateGigPayload
 	err = ReadJSON(w, r, &payload)
 	if err != nil {
@@ -229,13 +247,18 @@ func (app *application) updateGigHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Since all fields are optional, we only validate if at least one field is provided
-	hasUpdates := payload.Title != nil || payload.Amount != nil || payload.Description != nil ||
-		payload.Expertise != nil || payload.Discipline != nil
+	hasUpdates := payload.Title != nil || payload.Amount != nil || payload.Currency != nil ||
+		payload.Description != nil || payload.Expertise != nil || payload.Discipline != nil
 	if !hasUpdates {
 		app.badRequestResponse(w, r, errors.New("no fields provided for update"))
 		return
 	}
 
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
 	// Update only provided fields
 	if payload.Title != nil {
 		gig.Title = *payload.Title
@@ -243,6 +266,9 @@ func (app *application) updateGigHandler(w http.ResponseWriter, r *http.Request)
 	if payload.Amount != nil {
 		gig.Amount = *payload.Amount
 	}
+	if payload.Currency != nil {
+		gig.Currency = strings.ToLower(*payload.Currency)
+	}
 	if payload.Description != nil {
 		gig.Description = *payload.Description
 	}
@@ -268,7 +294,7 @@ func (app *application) updateGigHandler(w http.ResponseWriter, r *http.Request)
 // deleteGigHandler godoc
 //
 //	@Summary		Delete gig
-//	@Description	Delete gig
+//	@Description	Delete gig; only its mentor or an admin may
 //	@Tags			gig
 //	@Accept			json
 //	@Produce		json
@@ -276,16 +302,36 @@ func (app *application) updateGigHandler(w http.ResponseWriter, r *http.Request)
 //	@Success		200		{object}	nil
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/gigs/{gigID} [delete]
 func (app *application) deleteGigHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
 	gigID, err := strconv.ParseInt(chi.URLParam(r, "gigID"), 10, 64)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
 	}
 
+	gig, err := app.store.Gig.GetGigByID(r.Context(), gigID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if gig.Userid != user.ID && !user.IsAdmin {
+		app.forbidden(w, r)
+		return
+	}
+
 	err = app.store.Gig.DeleteGig(r.Context(), gigID)
 	if err != nil {
 		switch {
@@ -0,0 +1,508 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Althaf66/Appointr/internal/payments"
+	"github.com/Althaf66/Appointr/internal/signaling"
+	"github.com/Althaf66/Appointr/internal/store"
+	"github.com/Althaf66/Appointr/internal/video"
+	"go.uber.org/zap"
+)
+
+const testWebhookSecret = "whsec_test"
+
+// stripeStub stands in for the Stripe API. It refunds up to what each
+// payment intent was charged and keeps the refund requests it accepted.
+type stripeStub struct {
+	*httptest.Server
+	mu       sync.Mutex
+	charged  map[string]int64
+	refunded map[string]int64
+	requests []url.Values
+	keys     []string
+}
+
+func newStripeStub(t *testing.T) *stripeStub {
+	t.Helper()
+
+	s := &stripeStub{charged: map[string]int64{}, refunded: map[string]int64{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *stripeStub) charge(paymentIntentID string, amount int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.charged[paymentIntentID] = amount
+}
+
+func (s *stripeStub) refundRequests() []url.Values {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]url.Values(nil), s.requests...)
+}
+
+func (s *stripeStub) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/refunds" {
+		stripeStubError(w, http.StatusNotFound, "resource_missing", "no such route")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		stripeStubError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	intent := r.PostForm.Get("payment_intent")
+	amount, _ := strconv.ParseInt(r.PostForm.Get("amount"), 10, 64)
+	charged, ok := s.charged[intent]
+	if !ok {
+		stripeStubError(w, http.StatusNotFound, "resource_missing", "no such payment_intent: "+intent)
+		return
+	}
+	if amount <= 0 || s.refunded[intent]+amount > charged {
+		stripeStubError(w, http.StatusBadRequest, "invalid_request_error", "refund exceeds the charge")
+		return
+	}
+
+	s.refunded[intent] += amount
+	s.requests = append(s.requests, r.PostForm)
+	s.keys = append(s.keys, r.Header.Get("Idempotency-Key"))
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"id":             fmt.Sprintf("re_stub_%d", len(s.requests)),
+		"object":         "refund",
+		"amount":         amount,
+		"currency":       "usd",
+		"payment_intent": intent,
+		"status":         "succeeded",
+	})
+}
+
+func stripeStubError(w http.ResponseWriter, status int, kind, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{"type": kind, "message": message},
+	})
+}
+
+// newTestApplication wires the app to in-memory stores and to Stripe at
+// stripeAPIURL.
+func newTestApplication(t *testing.T, stripeAPIURL string) (*application, *testStore) {
+	t.Helper()
+
+	ts := newTestStore()
+	cfg := config{
+		frontendURL: "http://localhost:5173",
+		payments: paymentsConfig{
+			stripeKey:            "sk_test_stub",
+			webhookSecret:        testWebhookSecret,
+			provider:             "stripe",
+			lateCancelRefundRate: 0.5,
+			stripeAPIURL:         stripeAPIURL,
+		},
+	}
+
+	app := &application{
+		config:    cfg,
+		store:     ts.storage(),
+		logger:    zap.NewNop().Sugar(),
+		payments:  payments.NewStripeProvider(cfg.payments.stripeKey, cfg.payments.webhookSecret, cfg.payments.stripeAPIURL),
+		signaling: signaling.NewHub(2),
+		rooms:     video.NewMemory(),
+	}
+	return app, ts
+}
+
+// testStore keeps the meetings, payments, webhook events and recordings the
+// handlers under test touch. Its stores embed nil Postgres ones, so calls to anything
+// else panic.
+type testStore struct {
+	mu       sync.Mutex
+	meetings map[int64]*store.Meetings
+	history  map[int64][]*store.MeetingStatusChange
+	payments map[int64]*store.Payment
+	refunds  []*store.Refund
+	expired  []string
+	failed   []string
+	events   map[string]*store.WebhookEvent
+	// recordings are kept in the order they were added
+	recordings []*store.Recording
+}
+
+func newTestStore() *testStore {
+	return &testStore{
+		meetings: map[int64]*store.Meetings{},
+		history:  map[int64][]*store.MeetingStatusChange{},
+		payments: map[int64]*store.Payment{},
+		events:   map[string]*store.WebhookEvent{},
+	}
+}
+
+func (s *testStore) storage() store.Storage {
+	return store.Storage{
+		Meetings:      &testMeetings{s: s},
+		Mentor:        &testMentors{},
+		Recordings:    &testRecordings{s: s},
+		Payments:      &testPayments{s: s},
+		Packages:      &testPackages{},
+		WebhookEvents: &testWebhookEvents{s: s},
+	}
+}
+
+func (s *testStore) addMeeting(meeting *store.Meetings, history ...*store.MeetingStatusChange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.meetings[meeting.ID] = meeting
+	s.history[meeting.ID] = history
+}
+
+func (s *testStore) addPayment(payment *store.Payment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.payments[payment.ID] = payment
+}
+
+func (s *testStore) payment(id int64) store.Payment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.payments[id]
+}
+
+func (s *testStore) meeting(id int64) store.Meetings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.meetings[id]
+}
+
+func (s *testStore) event(eventID string) store.WebhookEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.events[eventID]
+}
+
+func (s *testStore) paymentBySession(sessionID string) *store.Payment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range s.payments {
+		if p.CheckoutSessionID == sessionID {
+			out := *p
+			return &out
+		}
+	}
+	return nil
+}
+
+// paymentByIntent finds a payment by its payment intent. The caller holds
+// s.mu.
+func (s *testStore) paymentByIntent(paymentIntentID string) *store.Payment {
+	for _, p := range s.payments {
+		if p.PaymentIntentID == paymentIntentID {
+			return p
+		}
+	}
+	return nil
+}
+
+// settle mirrors the ledger's settleRefunds. The caller holds s.mu.
+func (s *testStore) settle(p *store.Payment) {
+	switch {
+	case p.RefundedAmount >= p.Amount:
+		p.Status = store.PaymentRefunded
+	case p.RefundedAmount > 0:
+		p.Status = store.PaymentPartiallyRefunded
+	}
+	meeting := s.meetings[p.MeetingID]
+	if p.RefundedAmount >= p.Amount && !p.Unapplied && meeting.Status.CanTransitionTo(store.MeetingRefunded) {
+		meeting.Status = store.MeetingRefunded
+	}
+}
+
+type testMeetings struct {
+	*store.MeetingsStore
+	s *testStore
+}
+
+func (m *testMeetings) GetMeetingByID(ctx context.Context, id int64) (*store.Meetings, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	meeting, ok := m.s.meetings[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	out := *meeting
+	return &out, nil
+}
+
+func (m *testMeetings) GetMeetingStatusHistory(ctx context.Context, meetingID int64) ([]*store.MeetingStatusChange, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+	return m.s.history[meetingID], nil
+}
+
+func (m *testMeetings) UpdateLink(ctx context.Context, meeting *store.Meetings) error {
+	return nil
+}
+
+// testMentors has no mentor profiles, so every mentor gets the default
+// cancellation window.
+type testMentors struct {
+	*store.MentorStore
+}
+
+func (m *testMentors) GetMentorByUserID(ctx context.Context, userid int64) (*store.Mentor, error) {
+	return nil, store.ErrNotFound
+}
+
+type testRecordings struct {
+	*store.RecordingStore
+	s *testStore
+}
+
+func (r *testRecordings) GetRecordingConsents(ctx context.Context, meetingID int64) ([]int64, error) {
+	return nil, nil
+}
+
+func (r *testRecordings) GetRecordingsBefore(ctx context.Context, before time.Time) ([]*store.Recording, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	recordings := []*store.Recording{}
+	for _, rec := range r.s.recordings {
+		if rec.StartedAt.Before(before) {
+			recordings = append(recordings, rec)
+		}
+	}
+	return recordings, nil
+}
+
+func (r *testRecordings) DeleteRecording(ctx context.Context, id int64) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for i, rec := range r.s.recordings {
+		if rec.ID == id {
+			r.s.recordings = append(r.s.recordings[:i], r.s.recordings[i+1:]...)
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
+
+type testPackages struct {
+	*store.PackageStore
+}
+
+func (p *testPackages) ExpirePurchase(ctx context.Context, sessionID string) error {
+	return nil
+}
+
+type testPayments struct {
+	*store.PaymentStore
+	s *testStore
+}
+
+func (p *testPayments) CompleteCheckout(ctx context.Context, meetingID int64, payment store.MeetingPayment) error {
+	p.s.mu.Lock()
+	defer p.s.mu.Unlock()
+
+	meeting, ok := p.s.meetings[meetingID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if !meeting.Status.CanTransitionTo(store.MeetingPaid) {
+		return store.ErrInvalidTransition
+	}
+	meeting.Status = store.MeetingPaid
+	return nil
+}
+
+func (p *testPayments) RecordUnappliedPayment(ctx context.Context, meetingID int64, payment store.MeetingPayment) (*store.Payment, error) {
+	p.s.mu.Lock()
+	defer p.s.mu.Unlock()
+
+	meeting, ok := p.s.meetings[meetingID]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	for _, existing := range p.s.payments {
+		if existing.CheckoutSessionID == payment.SessionID {
+			existing.Unapplied = true
+			out := *existing
+			return &out, nil
+		}
+	}
+
+	paidAt := meeting.StartAt
+	recorded := &store.Payment{
+		ID:                int64(len(p.s.payments) + 1),
+		MeetingID:         meetingID,
+		PayerID:           meeting.Userid,
+		PayeeID:           meeting.Mentorid,
+		CheckoutSessionID: payment.SessionID,
+		PaymentIntentID:   payment.PaymentIntentID,
+		Amount:            payment.Amount,
+		Currency:          payment.Currency,
+		Status:            store.PaymentSucceeded,
+		PaidAt:            &paidAt,
+		Unapplied:         true,
+	}
+	p.s.payments[recorded.ID] = recorded
+	out := *recorded
+	return &out, nil
+}
+
+func (p *testPayments) GetPaymentBySessionID(ctx context.Context, sessionID string) (*store.Payment, error) {
+	if payment := p.s.paymentBySession(sessionID); payment != nil {
+		return payment, nil
+	}
+	return nil, store.ErrNotFound
+}
+
+func (p *testPayments) GetPaidPaymentByMeetingID(ctx context.Context, meetingID int64) (*store.Payment, error) {
+	p.s.mu.Lock()
+	defer p.s.mu.Unlock()
+
+	for _, payment := range p.s.payments {
+		if payment.MeetingID == meetingID && payment.PaidAt != nil && !payment.Unapplied {
+			out := *payment
+			return &out, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (p *testPayments) RecordRefund(ctx context.Context, refund *store.Refund) error {
+	p.s.mu.Lock()
+	defer p.s.mu.Unlock()
+
+	payment, ok := p.s.payments[refund.PaymentID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	refund.ID = int64(len(p.s.refunds) + 1)
+	p.s.refunds = append(p.s.refunds, refund)
+	payment.RefundedAmount = min(payment.Amount, payment.RefundedAmount+refund.Amount)
+	p.s.settle(payment)
+	return nil
+}
+
+func (p *testPayments) SyncRefundedAmount(ctx context.Context, paymentIntentID string, refunded int64) error {
+	p.s.mu.Lock()
+	defer p.s.mu.Unlock()
+
+	payment := p.s.paymentByIntent(paymentIntentID)
+	if payment == nil {
+		return store.ErrNotFound
+	}
+	payment.RefundedAmount = min(payment.Amount, max(payment.RefundedAmount, refunded))
+	p.s.settle(payment)
+	return nil
+}
+
+func (p *testPayments) MarkPaymentDisputed(ctx context.Context, paymentIntentID string) error {
+	p.s.mu.Lock()
+	defer p.s.mu.Unlock()
+
+	payment := p.s.paymentByIntent(paymentIntentID)
+	if payment == nil {
+		return store.ErrNotFound
+	}
+	payment.Status = store.PaymentDisputed
+	return nil
+}
+
+func (p *testPayments) ExpireCheckout(ctx context.Context, sessionID string) error {
+	p.s.mu.Lock()
+	defer p.s.mu.Unlock()
+
+	p.s.expired = append(p.s.expired, sessionID)
+	for _, payment := range p.s.payments {
+		if payment.CheckoutSessionID == sessionID && payment.Status == store.PaymentPending {
+			payment.Status = store.PaymentExpired
+		}
+	}
+	return nil
+}
+
+func (p *testPayments) FailCheckout(ctx context.Context, sessionID string) error {
+	p.s.mu.Lock()
+	defer p.s.mu.Unlock()
+
+	p.s.failed = append(p.s.failed, sessionID)
+	for _, payment := range p.s.payments {
+		if payment.CheckoutSessionID == sessionID && payment.Status == store.PaymentPending {
+			payment.Status = store.PaymentFailed
+		}
+	}
+	return nil
+}
+
+type testWebhookEvents struct {
+	*store.WebhookEventStore
+	s *testStore
+}
+
+func (e *testWebhookEvents) ClaimWebhookEvent(ctx context.Context, event *store.WebhookEvent) (bool, error) {
+	e.s.mu.Lock()
+	defer e.s.mu.Unlock()
+
+	if existing, ok := e.s.events[event.EventID]; ok && existing.Status != store.WebhookFailed {
+		return false, nil
+	}
+	event.ID = int64(len(e.s.events) + 1)
+	event.Status = store.WebhookProcessing
+	event.Attempts++
+	e.s.events[event.EventID] = event
+	return true, nil
+}
+
+func (e *testWebhookEvents) FinishWebhookEvent(ctx context.Context, id int64, status, errMsg string) error {
+	e.s.mu.Lock()
+	defer e.s.mu.Unlock()
+
+	for _, event := range e.s.events {
+		if event.ID == id {
+			event.Status = status
+			event.LastError = errMsg
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
+
+// withUser and withMeeting put what the auth and meeting middlewares would
+// on the request's context.
+func withUser(r *http.Request, user *store.User) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), userCtx, user))
+}
+
+func withMeeting(r *http.Request, meeting *store.Meetings) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), meetingCtx, meeting))
+}
+
+func mustParseInt(t *testing.T, s string) int64 {
+	t.Helper()
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Althaf66/Appointr/internal/store"
+	chi "github.com/go-chi/chi/v5"
+)
+
+type RegisterHolidayPayload struct {
+	Calendar string `json:"calendar" validate:"required,max=50"`
+	Date     string `json:"date" validate:"required,datetime=2006-01-02"`
+	Name     string `json:"name" validate:"required,max=255"`
+}
+
+// getHolidaysHandler godoc
+//
+//	@Summary		Get holidays
+//	@Description	Get every date in a holiday calendar
+//	@Tags			availability
+//	@Accept			json
+//	@Produce		json
+//	@Param			calendar	query		string	true	"Calendar name"
+//	@Success		200			{object}	[]store.Holiday
+//	@Failure		400			{object}	error
+//	@Failure		500			{object}	error
+//	@Router			/holidays [get]
+func (app *application) getHolidaysHandler(w http.ResponseWriter, r *http.Request) {
+	calendar := r.URL.Query().Get("calendar")
+	if calendar == "" {
+		app.badRequestResponse(w, r, errors.New("calendar is required"))
+		return
+	}
+
+	holidays, err := app.store.Holidays.GetHolidays(r.Context(), calendar)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = JsonResponse(w, http.StatusOK, holidays)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// createHolidayHandler godoc
+//
+//	@Summary		Add a holiday
+//	@Description	Add a date to a holiday calendar, renaming it if already there
+//	@Tags			availability
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RegisterHolidayPayload	true	"Holiday"
+//	@Success		201		{object}	store.Holiday
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/holidays [post]
+func (app *application) createHolidayHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RegisterHolidayPayload
+	err := ReadJSON(w, r, &payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	holiday := &store.Holiday{
+		Calendar: payload.Calendar,
+		Date:     payload.Date,
+		Name:     payload.Name,
+	}
+
+	err = app.store.Holidays.CreateHoliday(r.Context(), holiday)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = JsonResponse(w, http.StatusCreated, holiday)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// deleteHolidayHandler godoc
+//
+//	@Summary		Delete a holiday
+//	@Description	Remove a date from its holiday calendar
+//	@Tags			availability
+//	@Accept			json
+//	@Produce		json
+//	@Param			holidayID	path		int64	true	"Holiday ID"
+//	@Success		200			{object}	nil
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Router			/holidays/{holidayID} [delete]
+func (app *application) deleteHolidayHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "holidayID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.store.Holidays.DeleteHoliday(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Althaf66/Appointr/internal/invoice"
+	"github.com/Althaf66/Appointr/internal/mailer"
+	"github.com/Althaf66/Appointr/internal/payments"
+	"github.com/Althaf66/Appointr/internal/store"
+	chi "github.com/go-chi/chi/v5"
+)
+
+// getPaymentInvoiceHandler godoc
+//
+//	@Summary		Download an invoice
+//	@Description	Download the invoice of a paid payment as a PDF or an HTML page. Its payer, payee or admins only.
+//	@Tags			payments
+//	@Produce		application/pdf
+//	@Produce		html
+//	@Param			paymentID	path		int64	true	"Payment ID"
+//	@Param			format		query		string	false	"pdf or html"	default(pdf)
+//	@Success		200			{file}		file
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/payments/{paymentID}/invoice [get]
+func (app *application) getPaymentInvoiceHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	paymentID, err := strconv.ParseInt(chi.URLParam(r, "paymentID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "pdf"
+	}
+	if format != "pdf" && format != "html" {
+		app.badRequestResponse(w, r, fmt.Errorf("unknown invoice format %q", format))
+		return
+	}
+
+	payment, err := app.store.Payments.GetPaymentByID(r.Context(), paymentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if payment.PayerID != user.ID && payment.PayeeID != user.ID && !user.IsAdmin {
+		app.forbidden(w, r)
+		return
+	}
+
+	if payment.PaidAt == nil {
+		app.conflictResponse(w, r, errors.New("payment has not been paid"))
+		return
+	}
+	if payment.Unapplied {
+		app.conflictResponse(w, r, errors.New("payment did not pay for the meeting and is refunded"))
+		return
+	}
+
+	inv, err := app.store.Invoices.GetInvoiceByPaymentID(r.Context(), payment.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		// paid before invoices were issued, or the issue after payment failed
+		inv, err = app.issueInvoice(r.Context(), payment)
+	}
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if format == "html" {
+		body, err := invoice.HTML(inv)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(body)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", inv.Number+".pdf"))
+	w.Write(invoice.PDF(inv))
+}
+
+// issueInvoice numbers the invoice of a paid payment and, the first time,
+// emails it to the mentee. A payment that already has an invoice gets that
+// one back. Email failures are logged, not returned: the invoice stays
+// downloadable.
+func (app *application) issueInvoice(ctx context.Context, payment *store.Payment) (*store.Invoice, error) {
+	if payment.PaidAt == nil {
+		return nil, errors.New("payment has not been paid")
+	}
+
+	meeting, err := app.store.Meetings.GetMeetingByID(ctx, payment.MeetingID)
+	if err != nil {
+		return nil, err
+	}
+	mentee, err := app.store.Users.GetByID(ctx, payment.PayerID)
+	if err != nil {
+		return nil, err
+	}
+	mentor, err := app.store.Users.GetByID(ctx, payment.PayeeID)
+	if err != nil {
+		return nil, err
+	}
+
+	description, _ := app.checkoutProduct(ctx, meeting)
+	if meeting.CouponID != nil {
+		if coupon, err := app.store.Coupons.GetCouponByID(ctx, *meeting.CouponID); err == nil {
+			description = couponProduct(description, coupon.Code)
+		}
+	}
+
+	inv := &store.Invoice{
+		PaymentID:   payment.ID,
+		MentorName:  mentor.Username,
+		MentorEmail: mentor.Email,
+		MenteeName:  mentee.Username,
+		MenteeEmail: mentee.Email,
+		Description: description,
+		Currency:    payment.Currency,
+		Subtotal:    payment.SessionAmount,
+		Fee:         payment.FeeAmount,
+		Tax:         payment.TaxAmount,
+		Total:       payment.Amount,
+		PaidAt:      *payment.PaidAt,
+	}
+	created, err := app.store.Invoices.CreateInvoice(ctx, inv)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		app.emailInvoice(ctx, inv)
+	}
+
+	return inv, nil
+}
+
+// emailInvoice sends the mentee their invoice as PDF and HTML attachments.
+func (app *application) emailInvoice(ctx context.Context, inv *store.Invoice) {
+	page, err := invoice.HTML(inv)
+	if err != nil {
+		app.logger.Errorw("error rendering invoice", "invoice", inv.Number, "error", err)
+		return
+	}
+
+	vars := struct {
+		Username    string
+		Number      string
+		Description string
+		Total       string
+		PaymentsURL string
+	}{
+		Username:    inv.MenteeName,
+		Number:      inv.Number,
+		Description: inv.Description,
+		Total:       payments.FormatMinor(inv.Total, inv.Currency),
+		PaymentsURL: fmt.Sprintf("%s/profile", app.config.frontendURL),
+	}
+	attachments := []mailer.Attachment{
+		{Filename: inv.Number + ".pdf", Data: invoice.PDF(inv)},
+		{Filename: inv.Number + ".html", Data: page},
+	}
+
+	isProdenv := app.config.env == "production"
+
+	status, err := app.mailer.SendWithAttachments(mailer.PaymentInvoiceTemplate, inv.MenteeName, inv.MenteeEmail, vars, attachments, !isProdenv)
+	if err != nil {
+		app.logger.Errorw("error sending invoice email", "invoice", inv.Number, "error", err)
+		return
+	}
+	app.logger.Infow("Email sent", "status code", status)
+
+	if err := app.store.Invoices.MarkInvoiceEmailed(ctx, inv.ID); err != nil {
+		app.logger.Errorw("error recording invoice email", "invoice", inv.Number, "error", err)
+	}
+}
+
+// invoiceCheckout issues the invoice of the payment made through a
+// completed checkout session. It only logs: the payment is recorded either
+// way and the invoice can still be issued on download.
+func (app *application) invoiceCheckout(ctx context.Context, sessionID string) {
+	payment, err := app.store.Payments.GetPaymentBySessionID(ctx, sessionID)
+	if err != nil {
+		app.logger.Errorw("error loading payment for invoice", "session", sessionID, "error", err)
+		return
+	}
+	if _, err := app.issueInvoice(ctx, payment); err != nil {
+		app.logger.Errorw("error issuing invoice", "payment", payment.ID, "error", err)
+	}
+}
@@ -5,15 +5,23 @@ import (
 	"log"
 	"os"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/Althaf66/Appointr/internal/auth"
+	"github.com/Althaf66/Appointr/internal/availability"
 	"github.com/Althaf66/Appointr/internal/db"
-	// "github.com/Althaf66/Appointr/internal/env"
+	"github.com/Althaf66/Appointr/internal/earnings"
+	"github.com/Althaf66/Appointr/internal/env"
 	"github.com/Althaf66/Appointr/internal/mailer"
+	"github.com/Althaf66/Appointr/internal/payments"
+	"github.com/Althaf66/Appointr/internal/sfu"
+	"github.com/Althaf66/Appointr/internal/signaling"
 	"github.com/Althaf66/Appointr/internal/store"
+	"github.com/Althaf66/Appointr/internal/video"
 	"github.com/Althaf66/Appointr/internal/websocket"
 	"github.com/joho/godotenv"
+	"github.com/pion/webrtc/v3"
 	"go.uber.org/zap"
 )
 
@@ -69,8 +77,38 @@ func main() {
 				iss:    "appointr",
 			},
 		},
-		stripeKey:     os.Getenv("STRIPE_KEY"),
-		stripeWebhook: os.Getenv("STRIPE_WEBHOOK"),
+		payments: paymentsConfig{
+			provider:         env.GetString("PAYMENTS_PROVIDER", "stripe"),
+			stripeKey:        os.Getenv("STRIPE_KEY"),
+			webhookSecret:    os.Getenv("STRIPE_WEBHOOK"),
+			currency:         strings.ToLower(env.GetString("CHECKOUT_CURRENCY", "inr")),
+			allowedCountries: env.GetList("CHECKOUT_ALLOWED_COUNTRIES", []string{"IN"}),
+			successURL:       env.GetString("CHECKOUT_SUCCESS_URL", os.Getenv("FRONTEND_URL")+"/profile?success=true"),
+			cancelURL:        env.GetString("CHECKOUT_CANCEL_URL", os.Getenv("FRONTEND_URL")+"/profile?canceled=true"),
+			pricing: payments.Pricing{
+				FeeRate: env.GetFloat("CHECKOUT_FEE_RATE", 0),
+				TaxRate: env.GetFloat("CHECKOUT_TAX_RATE", 0),
+			},
+			commissionRate:       env.GetFloat("PLATFORM_COMMISSION_RATE", 0.1),
+			lateCancelRefundRate: env.GetFloat("REFUND_LATE_CANCEL_RATE", 0.5),
+			stripeAPIURL:         os.Getenv("STRIPE_API_URL"),
+		},
+		video: videoConfig{
+			registry:        env.GetString("VIDEO_ROOM_REGISTRY", "postgres"),
+			maxParticipants: env.GetInt("VIDEO_ROOM_MAX_PARTICIPANTS", 8),
+			joinEarly:       time.Duration(env.GetInt("VIDEO_JOIN_EARLY_MINUTES", 10)) * time.Minute,
+			roomGrace:       time.Duration(env.GetInt("VIDEO_ROOM_GRACE_MINUTES", 30)) * time.Minute,
+			ice: video.ICEConfig{
+				STUNURLs:   env.GetList("VIDEO_STUN_URLS", []string{"stun:stun.l.google.com:19302"}),
+				TURNURLs:   env.GetList("VIDEO_TURN_URLS", []string{}),
+				TURNSecret: os.Getenv("VIDEO_TURN_SECRET"),
+				TURNTTL:    time.Duration(env.GetInt("VIDEO_TURN_TTL_MINUTES", 24*60)) * time.Minute,
+			},
+		},
+		recording: recordingConfig{
+			dir:       env.GetString("RECORDING_DIR", "recordings"),
+			retention: time.Duration(env.GetInt("RECORDING_RETENTION_DAYS", 30)) * 24 * time.Hour,
+		},
 	}
 
 	// logger
@@ -94,13 +132,50 @@ func main() {
 	}
 	jwtAuthenticator := auth.NewJWTAuthenticator(cfg.auth.token.secret, cfg.auth.token.iss, cfg.auth.token.iss)
 
+	var paymentProvider payments.Provider
+	switch cfg.payments.provider {
+	case "stripe":
+		paymentProvider = payments.NewStripeProvider(cfg.payments.stripeKey, cfg.payments.webhookSecret, cfg.payments.stripeAPIURL)
+	case "fake":
+		paymentProvider = payments.NewFake(cfg.payments.webhookSecret)
+	default:
+		logger.Fatalf("unknown payments provider %q", cfg.payments.provider)
+	}
+
+	var rooms video.Registry
+	switch cfg.video.registry {
+	case "postgres":
+		rooms = video.NewPostgres(db)
+	case "memory":
+		rooms = video.NewMemory()
+	default:
+		logger.Fatalf("unknown video room registry %q", cfg.video.registry)
+	}
+
+	// the SFU only needs STUN to learn its public address; TURN credentials
+	// are for browsers
+	hub := signaling.NewHub(cfg.video.maxParticipants)
+	sfuConfig := webrtc.Configuration{}
+	if len(cfg.video.ice.STUNURLs) > 0 {
+		sfuConfig.ICEServers = []webrtc.ICEServer{{URLs: cfg.video.ice.STUNURLs}}
+	}
+
 	app := application{
 		config:        cfg,
 		store:         store,
 		logger:        logger,
 		mailer:        mailtrap,
 		authenticator: jwtAuthenticator,
+		payments:      paymentProvider,
 		wsManager:     wsManager,
+		signaling:     hub,
+		rooms:         rooms,
+		availability:  availability.NewService(store),
+		earnings:      earnings.NewService(store),
+	}
+	app.sfu, err = sfu.New(hub, sfuConfig, app.recordParticipant)
+	if err != nil {
+		logger.Fatal(err)
 	}
 
 	expvar.NewString("version").Set(version)
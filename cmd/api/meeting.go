@@ -1,26 +1,54 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/Althaf66/Appointr/internal/availability"
+	"github.com/Althaf66/Appointr/internal/mailer"
 	"github.com/Althaf66/Appointr/internal/store"
 	chi "github.com/go-chi/chi/v5"
 )
 
+// RegisterMeetingPayload takes either an absolute start_at or the legacy
+// date/start_time/start_period triple read in the caller's timezone. The price
+// comes from gig_id, or the mentor's cheapest gig when it is left out. With
+// use_credit the meeting is paid with a package credit for the mentor instead.
 type RegisterMeetingPayload struct {
-	Mentorid    int64   `json:"mentorid"`
-	Day         string  `json:"day"`
-	Date        string  `json:"date"`
-	StartTime   string  `json:"start_time"`
-	StartPeriod string  `json:"start_period"`
-	Amount      float64 `json:"amount"`
-	Link        string `json:"link"`
+	Mentorid        int64      `json:"mentorid"`
+	GigID           *int64     `json:"gig_id"`
+	UseCredit       bool       `json:"use_credit"`
+	StartAt         *time.Time `json:"start_at"`
+	DurationMinutes int        `json:"duration_minutes" validate:"omitempty,min=15,max=480"`
+	Date            string     `json:"date"`
+	StartTime       string     `json:"start_time"`
+	StartPeriod     string     `json:"start_period"`
+	Link            string     `json:"link"`
 }
 
+type meetingKey string
+
+const meetingCtx meetingKey = "meeting"
+
+var ErrWithinCancellationWindow = errors.New("too close to the start of the session to change it")
+
+// UpdateMeetingPayload sets where the meeting takes place, for mentors who
+// meet somewhere other than the meeting's own video room.
 type UpdateMeetingPayload struct {
-	Link *string `json:"link"`
+	Link *string `json:"link" validate:"required,url,max=255"`
+}
+
+type CancelMeetingPayload struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+type UpdateMeetingStatusPayload struct {
+	Status store.MeetingStatus `json:"status" validate:"required,oneof=requested confirmed paid in_progress completed cancelled no_show refunded"`
+	Reason string              `json:"reason" validate:"max=500"`
 }
 
 // createMeetingHandler godoc
@@ -34,6 +62,7 @@ type UpdateMeetingPayload struct {
 //	@Success		201		{object}	store.Meetings
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		409		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/meetings/create [post]
@@ -44,27 +73,86 @@ func (app *application) createMeetingHandler(w http.ResponseWriter, r *http.Requ
 	err := ReadJSON(w, r, &payload)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
 	}
 
-	meeting := &store.Meetings{
-		Userid:      user.ID,
-		Mentorid:    payload.Mentorid,
-		Day:         payload.Day,
-		Date:        payload.Date,
-		StartTime:   payload.StartTime,
-		StartPeriod: payload.StartPeriod,
-		Isconfirm:   false,
-		Ispaid:      false,
-		Iscompleted: false,
-		Amount:      payload.Amount,
-		Link: payload.Link,
+	loc, err := viewerLocation(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
 	}
 
-	err = app.store.Meetings.CreateMeeting(r.Context(), meeting)
+	start, err := meetingStart(payload.StartAt, payload.Date, payload.StartTime, payload.StartPeriod, loc)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	duration := payload.DurationMinutes
+	if duration == 0 {
+		duration = int(availability.DefaultDuration / time.Minute)
+	}
+
+	meeting := &store.Meetings{
+		Userid:          user.ID,
+		Mentorid:        payload.Mentorid,
+		StartAt:         start.UTC(),
+		DurationMinutes: duration,
+		Status:          store.MeetingRequested,
+		Link:            payload.Link,
+	}
+
+	if payload.UseCredit {
+		purchase, err := app.store.Packages.GetCreditPurchase(r.Context(), user.ID, payload.Mentorid)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNoCredits):
+				app.conflictResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+		// the package already paid for it
+		meeting.PurchaseID = &purchase.ID
+		meeting.GigID = purchase.GigID
+	} else {
+		gig, err := app.meetingGig(r.Context(), payload.Mentorid, payload.GigID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.badRequestResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+		meeting.GigID = &gig.ID
+		meeting.Amount = gig.Amount
+	}
+
+	// checked under the mentor's lock, so the schedule can't change under it
+	err = app.store.Meetings.CreateMeeting(r.Context(), meeting, func(ctx context.Context) error {
+		return app.availability.CheckBookable(ctx, meeting.Mentorid, meeting.StartAt, meeting.EndAt().Sub(meeting.StartAt))
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, availability.ErrSlotUnavailable),
+			errors.Is(err, store.ErrMeetingConflict),
+			errors.Is(err, store.ErrNoCredits):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
 	}
 
+	meeting.Localize(loc)
 	err = JsonResponse(w, http.StatusCreated, meeting)
 	if err != nil {
 		app.internalServerError(w, r, err)
@@ -74,13 +162,14 @@ func (app *application) createMeetingHandler(w http.ResponseWriter, r *http.Requ
 // getAllMeetingsHandler godoc
 //
 //	@Summary		Get all meetings
-//	@Description	Get all meetings
+//	@Description	Get every meeting in the system; admins only
 //	@Tags			meetings
 //	@Accept			json
 //	@Produce		json
 //	@Success		200	{object}	[]store.Meetings
 //	@Failure		400	{object}	error
 //	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
 //	@Failure		500	{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/meetings [get]
@@ -91,12 +180,51 @@ func (app *application) getAllMeetingsHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	loc, err := viewerLocation(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	localizeMeetings(meetings, loc)
+
 	err = JsonResponse(w, http.StatusOK, meetings)
 	if err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
 
+// getMeetingHandler godoc
+//
+//	@Summary		Get a meeting
+//	@Description	Get a single meeting; only its participants and admins may see it
+//	@Tags			meetings
+//	@Accept			json
+//	@Produce		json
+//	@Param			meetingID	path		int64	true	"Meeting ID"
+//	@Success		200			{object}	store.Meetings
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meetings/{meetingID} [get]
+func (app *application) getMeetingHandler(w http.ResponseWriter, r *http.Request) {
+	meeting := getMeetingFromCtx(r)
+
+	loc, err := viewerLocation(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	meeting.Localize(loc)
+	err = JsonResponse(w, http.StatusOK, meeting)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
 // getMeetingByUserIDHandler godoc
 //
 //	@Summary		Get meetings by user ID
@@ -108,6 +236,7 @@ func (app *application) getAllMeetingsHandler(w http.ResponseWriter, r *http.Req
 //	@Success		200		{object}	[]store.Meetings
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/meetings/u/{userID} [get]
@@ -124,6 +253,13 @@ func (app *application) getMeetingByUserIDHandler(w http.ResponseWriter, r *http
 		return
 	}
 
+	loc, err := viewerLocation(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	localizeMeetings(meetings, loc)
+
 	err = JsonResponse(w, http.StatusOK, meetings)
 	if err != nil {
 		app.internalServerError(w, r, err)
@@ -133,7 +269,7 @@ func (app *application) getMeetingByUserIDHandler(w http.ResponseWriter, r *http
 // getMeetingMentorNotConfirmHandler godoc
 //
 //	@Summary		Get unconfirmed meetings by mentor ID
-//	@Description	Get meetings where isconfirm is false for a specific mentor
+//	@Description	Get requested meetings awaiting confirmation for a specific mentor
 //	@Tags			meetings
 //	@Accept			json
 //	@Produce		json
@@ -141,6 +277,7 @@ func (app *application) getMeetingByUserIDHandler(w http.ResponseWriter, r *http
 //	@Success		200			{object}	[]store.Meetings
 //	@Failure		400			{object}	error
 //	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
 //	@Failure		500			{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/meetings/mentor-not-confirm/{mentorID} [get]
@@ -157,6 +294,13 @@ func (app *application) getMeetingMentorNotConfirmHandler(w http.ResponseWriter,
 		return
 	}
 
+	loc, err := viewerLocation(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	localizeMeetings(meetings, loc)
+
 	err = JsonResponse(w, http.StatusOK, meetings)
 	if err != nil {
 		app.internalServerError(w, r, err)
@@ -166,7 +310,7 @@ func (app *application) getMeetingMentorNotConfirmHandler(w http.ResponseWriter,
 // getMeetingUserNotPaidHandler godoc
 //
 //	@Summary		Get unpaid meetings by user ID
-//	@Description	Get meetings where ispaid is false for a specific user
+//	@Description	Get confirmed meetings awaiting payment for a specific user
 //	@Tags			meetings
 //	@Accept			json
 //	@Produce		json
@@ -174,6 +318,7 @@ func (app *application) getMeetingMentorNotConfirmHandler(w http.ResponseWriter,
 //	@Success		200		{object}	[]store.Meetings
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/meetings/user-not-paid/{userID} [get]
@@ -190,6 +335,13 @@ func (app *application) getMeetingUserNotPaidHandler(w http.ResponseWriter, r *h
 		return
 	}
 
+	loc, err := viewerLocation(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	localizeMeetings(meetings, loc)
+
 	err = JsonResponse(w, http.StatusOK, meetings)
 	if err != nil {
 		app.internalServerError(w, r, err)
@@ -199,7 +351,7 @@ func (app *application) getMeetingUserNotPaidHandler(w http.ResponseWriter, r *h
 // getMeetingUserNotCompletedHandler godoc
 //
 //	@Summary		Get uncompleted meetings by user ID
-//	@Description	Get meetings where iscompleted is false but confirmed and paid for a specific user
+//	@Description	Get paid or in progress meetings for a specific user
 //	@Tags			meetings
 //	@Accept			json
 //	@Produce		json
@@ -207,6 +359,7 @@ func (app *application) getMeetingUserNotPaidHandler(w http.ResponseWriter, r *h
 //	@Success		200		{object}	[]store.Meetings
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/meetings/user-not-completed/{userID} [get]
@@ -223,6 +376,13 @@ func (app *application) getMeetingUserNotCompletedHandler(w http.ResponseWriter,
 		return
 	}
 
+	loc, err := viewerLocation(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	localizeMeetings(meetings, loc)
+
 	err = JsonResponse(w, http.StatusOK, meetings)
 	if err != nil {
 		app.internalServerError(w, r, err)
@@ -232,7 +392,7 @@ func (app *application) getMeetingUserNotCompletedHandler(w http.ResponseWriter,
 // getMeetingMentorNotCompletedHandler godoc
 //
 //	@Summary		Get uncompleted meetings by mentor ID
-//	@Description	Get meetings where iscompleted is false but confirmed and paid for a specific mentor
+//	@Description	Get paid or in progress meetings for a specific mentor
 //	@Tags			meetings
 //	@Accept			json
 //	@Produce		json
@@ -240,6 +400,7 @@ func (app *application) getMeetingUserNotCompletedHandler(w http.ResponseWriter,
 //	@Success		200			{object}	[]store.Meetings
 //	@Failure		400			{object}	error
 //	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
 //	@Failure		500			{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/meetings/mentor-not-completed/{mentorID} [get]
@@ -256,6 +417,13 @@ func (app *application) getMeetingMentorNotCompletedHandler(w http.ResponseWrite
 		return
 	}
 
+	loc, err := viewerLocation(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	localizeMeetings(meetings, loc)
+
 	err = JsonResponse(w, http.StatusOK, meetings)
 	if err != nil {
 		app.internalServerError(w, r, err)
@@ -265,7 +433,7 @@ func (app *application) getMeetingMentorNotCompletedHandler(w http.ResponseWrite
 // updateMeetingConfirmHandler godoc
 //
 //	@Summary		Confirm a meeting
-//	@Description	Update meeting confirmation status to true
+//	@Description	Move a requested meeting to confirmed. Meetings booked with a package credit are paid straight away.
 //	@Tags			meetings
 //	@Accept			json
 //	@Produce		json
@@ -273,127 +441,246 @@ func (app *application) getMeetingMentorNotCompletedHandler(w http.ResponseWrite
 //	@Success		200			{object}	store.Meetings
 //	@Failure		400			{object}	error
 //	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
 //	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
 //	@Failure		500			{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/meetings/confirm/{meetingID} [put]
 func (app *application) updateMeetingConfirmHandler(w http.ResponseWriter, r *http.Request) {
-	meetingID, err := strconv.ParseInt(chi.URLParam(r, "meetingID"), 10, 64)
+	app.changeMeetingStatus(w, r, store.MeetingConfirmed, "")
+}
+
+// updateMeetingPaidHandler godoc
+//
+//	@Summary		Mark meeting as paid
+//	@Description	Move a confirmed meeting to paid by hand; admins only. Stripe payments are recorded by the webhook.
+//	@Tags			meetings
+//	@Accept			json
+//	@Produce		json
+//	@Param			meetingID	path		int64	true	"Meeting ID"
+//	@Success		200			{object}	store.Meetings
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meetings/paid/{meetingID} [put]
+func (app *application) updateMeetingPaidHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeMeetingStatus(w, r, store.MeetingPaid, "")
+}
+
+// updateMeetingCompletedHandler godoc
+//
+//	@Summary		Mark meeting as completed
+//	@Description	Move a paid or in progress meeting to completed
+//	@Tags			meetings
+//	@Accept			json
+//	@Produce		json
+//	@Param			meetingID	path		int64	true	"Meeting ID"
+//	@Success		200			{object}	store.Meetings
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meetings/completed/{meetingID} [put]
+func (app *application) updateMeetingCompletedHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeMeetingStatus(w, r, store.MeetingCompleted, "")
+}
+
+// updateMeetingStatusHandler godoc
+//
+//	@Summary		Change meeting status
+//	@Description	Move a meeting to a new status; transitions the lifecycle does not allow are rejected. Cancelling has its own endpoint.
+//	@Tags			meetings
+//	@Accept			json
+//	@Produce		json
+//	@Param			meetingID	path		int64						true	"Meeting ID"
+//	@Param			payload		body		UpdateMeetingStatusPayload	true	"New status"
+//	@Success		200			{object}	store.Meetings
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meetings/status/{meetingID} [put]
+func (app *application) updateMeetingStatusHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateMeetingStatusPayload
+	err := ReadJSON(w, r, &payload)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	meeting, err := app.store.Meetings.GetMeetingByID(r.Context(), meetingID)
-	if err != nil {
-		switch {
-		case errors.Is(err, store.ErrNotFound):
-			app.notFoundResponse(w, r, err)
-		default:
-			app.internalServerError(w, r, err)
-		}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	err = app.store.Meetings.UpdateMeetingConfirm(r.Context(), meetingID)
-	if err != nil {
-		app.internalServerError(w, r, err)
+	if payload.Status == store.MeetingCancelled {
+		app.badRequestResponse(w, r, errors.New("meetings are cancelled through POST /meetings/{meetingID}/cancel"))
 		return
 	}
 
-	meeting.Isconfirm = true
-	err = JsonResponse(w, http.StatusOK, meeting)
-	if err != nil {
-		app.internalServerError(w, r, err)
+	if !canSetMeetingStatus(getUserfromCtx(r), getMeetingFromCtx(r), payload.Status) {
+		app.forbidden(w, r)
+		return
 	}
+
+	app.changeMeetingStatus(w, r, payload.Status, payload.Reason)
 }
 
-// updateMeetingPaidHandler godoc
+// getMeetingHistoryHandler godoc
 //
-//	@Summary		Mark meeting as paid
-//	@Description	Update meeting paid status to true
+//	@Summary		Get meeting status history
+//	@Description	Get every status change of a meeting, oldest first
 //	@Tags			meetings
 //	@Accept			json
 //	@Produce		json
 //	@Param			meetingID	path		int64	true	"Meeting ID"
-//	@Success		200			{object}	store.Meetings
+//	@Success		200			{object}	[]store.MeetingStatusChange
 //	@Failure		400			{object}	error
 //	@Failure		401			{object}	error
-//	@Failure		404			{object}	error
+//	@Failure		403			{object}	error
 //	@Failure		500			{object}	error
 //	@Security		ApiKeyAuth
-//	@Router			/meetings/paid/{meetingID} [put]
-func (app *application) updateMeetingPaidHandler(w http.ResponseWriter, r *http.Request) {
+//	@Router			/meetings/history/{meetingID} [get]
+func (app *application) getMeetingHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	meeting := getMeetingFromCtx(r)
+
+	history, err := app.store.Meetings.GetMeetingStatusHistory(r.Context(), meeting.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = JsonResponse(w, http.StatusOK, history)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// changeMeetingStatus applies a status transition to the meeting in the URL
+// on behalf of the caller and responds with the updated meeting.
+func (app *application) changeMeetingStatus(w http.ResponseWriter, r *http.Request, status store.MeetingStatus, reason string) {
+	loc, err := viewerLocation(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
 	meetingID, err := strconv.ParseInt(chi.URLParam(r, "meetingID"), 10, 64)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	meeting, err := app.store.Meetings.GetMeetingByID(r.Context(), meetingID)
+	var changedBy int64
+	if user := getUserfromCtx(r); user != nil {
+		changedBy = user.ID
+	}
+
+	err = app.store.Meetings.UpdateMeetingStatus(r.Context(), meetingID, status, changedBy, reason)
 	if err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
 			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrInvalidTransition):
+			app.conflictResponse(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
 		}
 		return
 	}
 
-	err = app.store.Meetings.UpdateMeetingPaid(r.Context(), meetingID)
+	meeting, err := app.store.Meetings.GetMeetingByID(r.Context(), meetingID)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
+	app.syncMeetingRoom(r.Context(), meeting)
 
-	meeting.Ispaid = true
+	meeting.Localize(loc)
 	err = JsonResponse(w, http.StatusOK, meeting)
 	if err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
 
-// updateMeetingCompletedHandler godoc
+// cancelMeetingHandler godoc
 //
-//	@Summary		Mark meeting as completed
-//	@Description	Update meeting completed status to true
+//	@Summary		Cancel a meeting
+//	@Description	Cancel a meeting as either participant. Mentees cancelling inside the mentor's cancellation window only get part of their payment back, and cannot cancel once it starts. Both parties are emailed.
 //	@Tags			meetings
 //	@Accept			json
 //	@Produce		json
-//	@Param			meetingID	path		int64	true	"Meeting ID"
+//	@Param			meetingID	path		int64					true	"Meeting ID"
+//	@Param			payload		body		CancelMeetingPayload	true	"Cancellation"
 //	@Success		200			{object}	store.Meetings
 //	@Failure		400			{object}	error
 //	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
 //	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
 //	@Failure		500			{object}	error
 //	@Security		ApiKeyAuth
-//	@Router			/meetings/completed/{meetingID} [put]
-func (app *application) updateMeetingCompletedHandler(w http.ResponseWriter, r *http.Request) {
-	meetingID, err := strconv.ParseInt(chi.URLParam(r, "meetingID"), 10, 64)
+//	@Router			/meetings/{meetingID}/cancel [post]
+func (app *application) cancelMeetingHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+	meeting := getMeetingFromCtx(r)
+
+	var payload CancelMeetingPayload
+	err := ReadJSON(w, r, &payload)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	meeting, err := app.store.Meetings.GetMeetingByID(r.Context(), meetingID)
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	loc, err := viewerLocation(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// inside the cancellation window mentees may still cancel, for only part
+	// of their money back; once the meeting starts, missing it is a no-show
+	if user.ID != meeting.Mentorid && !time.Now().Before(meeting.StartAt) {
+		app.conflictResponse(w, r, errors.New("the meeting has already started"))
+		return
+	}
+
+	err = app.store.Meetings.UpdateMeetingStatus(r.Context(), meeting.ID, store.MeetingCancelled, user.ID, payload.Reason)
 	if err != nil {
 		switch {
-		case errors.Is(err, store.ErrNotFound):
-			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrInvalidTransition):
+			app.conflictResponse(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
 		}
 		return
 	}
+	meeting.Status = store.MeetingCancelled
+	app.syncMeetingRoom(r.Context(), meeting)
 
-	err = app.store.Meetings.UpdateMeetingCompleted(r.Context(), meetingID)
-	if err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
+	app.notifyMeetingParticipants(r.Context(), meeting, meetingChange{
+		template: mailer.MeetingCancelledTemplate,
+		actorID:  user.ID,
+		reason:   payload.Reason,
+	})
 
-	meeting.Iscompleted = true
+	meeting.Localize(loc)
 	err = JsonResponse(w, http.StatusOK, meeting)
 	if err != nil {
 		app.internalServerError(w, r, err)
@@ -403,27 +690,42 @@ func (app *application) updateMeetingCompletedHandler(w http.ResponseWriter, r *
 // updateLinkHandler godoc
 //
 //	@Summary		Update link
-//	@Description	Update link
+//	@Description	Point the meeting at another place to meet instead of its video room. Its mentor only.
 //	@Tags			meetings
 //	@Accept			json
 //	@Produce		json
-//	@Param			meetings	path		int64					true	"Meeting ID"
-//	@Param			meetings	body		UpdateMeetingPayload	true	"Meetings"
+//	@Param			meetingID	path		int64					true	"Meeting ID"
+//	@Param			payload		body		UpdateMeetingPayload	true	"Link"
 //	@Success		200			{object}	store.Meetings
 //	@Failure		400			{object}	error
 //	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
 //	@Failure		404			{object}	error
 //	@Failure		500			{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/meetings/link/{meetingID} [put]
 func (app *application) updateLinkHandler(w http.ResponseWriter, r *http.Request) {
-	meetingID, err := strconv.ParseInt(chi.URLParam(r, "meetingID"), 10, 64)
+	meeting := getMeetingFromCtx(r)
+
+	var payload UpdateMeetingPayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	loc, err := viewerLocation(r)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	meeting, err := app.store.Meetings.GetMeetingByID(r.Context(), meetingID)
+	meeting.Link = *payload.Link
+	err = app.store.Meetings.UpdateLink(r.Context(), meeting)
 	if err != nil {
 		switch {
 		case errors.Is(err, store.ErrNotFound):
@@ -434,12 +736,7 @@ func (app *application) updateLinkHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = app.store.Meetings.UpdateLink(r.Context(), meeting)
-	if err != nil {
-		app.internalServerError(w, r, err)
-		return
-	}
-
+	meeting.Localize(loc)
 	err = JsonResponse(w, http.StatusOK, meeting)
 	if err != nil {
 		app.internalServerError(w, r, err)
@@ -457,6 +754,7 @@ func (app *application) updateLinkHandler(w http.ResponseWriter, r *http.Request
 //	@Success		200			{object}	nil
 //	@Failure		400			{object}	error
 //	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
 //	@Failure		404			{object}	error
 //	@Failure		500			{object}	error
 //	@Security		ApiKeyAuth
@@ -481,3 +779,90 @@ func (app *application) deleteMeetingHandler(w http.ResponseWriter, r *http.Requ
 
 	w.WriteHeader(http.StatusOK)
 }
+
+// checkCancellationWindow stops mentees from moving a session that starts
+// within the mentor's cancellation window. Mentors are not limited.
+func (app *application) checkCancellationWindow(ctx context.Context, meeting *store.Meetings, userID int64) error {
+	if userID == meeting.Mentorid {
+		return nil
+	}
+
+	hours := defaultCancellationWindowHours
+	if mentor, err := app.store.Mentor.GetMentorByUserID(ctx, meeting.Mentorid); err == nil {
+		hours = mentor.CancellationWindowHours
+	}
+
+	if time.Until(meeting.StartAt) < time.Duration(hours)*time.Hour {
+		return fmt.Errorf("%w: changes must be made at least %d hours ahead", ErrWithinCancellationWindow, hours)
+	}
+
+	return nil
+}
+
+// meetingStart reads a start given either as an instant or as a local
+// date/clock triple in loc.
+func meetingStart(startAt *time.Time, date, clock, period string, loc *time.Location) (time.Time, error) {
+	if startAt != nil {
+		return *startAt, nil
+	}
+	return availability.ParseLocal(date, clock, period, loc)
+}
+
+// meetingGig finds the gig a meeting is booked under, which sets its price.
+// Without gigID the mentor's cheapest gig is used.
+func (app *application) meetingGig(ctx context.Context, mentorID int64, gigID *int64) (*store.Gig, error) {
+	if gigID != nil {
+		gig, err := app.store.Gig.GetGigByID(ctx, *gigID)
+		if err != nil {
+			return nil, err
+		}
+		if gig.Userid != mentorID {
+			return nil, fmt.Errorf("%w: gig %d is not offered by this mentor", store.ErrNotFound, *gigID)
+		}
+		return gig, nil
+	}
+
+	gigs, err := app.store.Gig.GetGigsByUserID(ctx, mentorID)
+	if err != nil {
+		return nil, err
+	}
+	if len(gigs) == 0 {
+		return nil, fmt.Errorf("%w: mentor has no gigs to book", store.ErrNotFound)
+	}
+	return gigs[0], nil
+}
+
+func isMeetingParticipant(meeting *store.Meetings, userID int64) bool {
+	return meeting.Userid == userID || meeting.Mentorid == userID
+}
+
+func (app *application) meetingContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "meetingID"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+
+		meeting, err := app.store.Meetings.GetMeetingByID(ctx, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, meetingCtx, meeting)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getMeetingFromCtx(r *http.Request) *store.Meetings {
+	meeting, _ := r.Context().Value(meetingCtx).(*store.Meetings)
+	return meeting
+}
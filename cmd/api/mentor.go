@@ -14,10 +14,16 @@ type mentorKey string
 
 const mentorCtx mentorKey = "mentor"
 
+const defaultCancellationWindowHours = store.DefaultCancellationWindowHours
+
 type RegisterMentorPayload struct {
 	Name     string   `json:"name" validate:"required,max=40"`
 	Country  string   `json:"country" validate:"required"`
 	Language []string `json:"language" validate:"required"`
+	Timezone string   `json:"timezone" validate:"omitempty,timezone"`
+	// Hours before a session during which mentees can no longer cancel or
+	// reschedule, defaults to defaultCancellationWindowHours
+	CancellationWindowHours *int `json:"cancellation_window_hours" validate:"omitempty,min=0,max=168"`
 }
 
 // createMentorHandler godoc
@@ -48,11 +54,23 @@ func (app *application) createMentorHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	timezone := payload.Timezone
+	if timezone == "" {
+		timezone = user.Timezone
+	}
+
+	window := defaultCancellationWindowHours
+	if payload.CancellationWindowHours != nil {
+		window = *payload.CancellationWindowHours
+	}
+
 	mentor := &store.Mentor{
-		Userid:   user.ID,
-		Name:     payload.Name,
-		Country:  payload.Country,
-		Language: payload.Language,
+		Userid:                  user.ID,
+		Name:                    payload.Name,
+		Country:                 payload.Country,
+		Language:                payload.Language,
+		Timezone:                timezone,
+		CancellationWindowHours: window,
 	}
 
 	err = app.store.Mentor.CreateMentor(r.Context(), mentor)
@@ -278,15 +296,17 @@ func (app *application) getMentorByUserIDHandler(w http.ResponseWriter, r *http.
 }
 
 type UpdateMentorPayload struct {
-	Name     *string   `json:"name" validate:"omitempty,max=40"`
-	Country  *string   `json:"country" validate:"omitempty"`
-	Language *[]string `json:"language" validate:"omitempty"`
+	Name                    *string   `json:"name" validate:"omitempty,max=40"`
+	Country                 *string   `json:"country" validate:"omitempty"`
+	Language                *[]string `json:"language" validate:"omitempty"`
+	Timezone                *string   `json:"timezone" validate:"omitempty,timezone"`
+	CancellationWindowHours *int      `json:"cancellation_window_hours" validate:"omitempty,min=0,max=168"`
 }
 
 // updateMentorHandler godoc
 //
 //	@Summary		Update mentor
-//	@Description	Update mentor
+//	@Description	Update the mentor's profile; only the mentor themselves or an admin may
 //	@Tags			mentor
 //	@Accept			json
 //	@Produce		json
@@ -295,6 +315,7 @@ type UpdateMentorPayload struct {
 //	@Success		200			{object}	store.Mentor
 //	@Failure		400			{object}	error
 //	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
 //	@Failure		500			{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/mentors/{mentorID} [patch]
@@ -308,8 +329,13 @@ func (app *application) updateMentorHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
 	hasUpdates := payload.Name != nil || payload.Country != nil ||
-		payload.Language != nil
+		payload.Language != nil || payload.Timezone != nil || payload.CancellationWindowHours != nil
 	if !hasUpdates {
 		app.badRequestResponse(w, r, errors.New("no fields provided for update"))
 		return
@@ -324,6 +350,12 @@ func (app *application) updateMentorHandler(w http.ResponseWriter, r *http.Reque
 	if payload.Language != nil {
 		mentor.Language = *payload.Language
 	}
+	if payload.Timezone != nil {
+		mentor.Timezone = *payload.Timezone
+	}
+	if payload.CancellationWindowHours != nil {
+		mentor.CancellationWindowHours = *payload.CancellationWindowHours
+	}
 
 	err = app.store.Mentor.UpdateMentor(r.Context(), mentor)
 	if err != nil {
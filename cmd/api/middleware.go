@@ -3,12 +3,15 @@ package main
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/Althaf66/Appointr/internal/store"
+	"github.com/Althaf66/Appointr/internal/video"
+	chi "github.com/go-chi/chi/v5"
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -26,32 +29,80 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		token := parts[1]
-		jwtToken, err := app.authenticator.ValidateToken(token)
-		if err != nil {
-			app.unauthorizedErrorResponse(w, r, err)
+		app.serveAuthenticated(w, r, next, parts[1])
+	})
+}
+
+// WebSocketAuthMiddleware authenticates like AuthTokenMiddleware or, since
+// browsers can't set headers on a WebSocket handshake, with a one-time
+// ticket for the room in the ticket query parameter. Session tokens are
+// never taken from the URL, which request logs keep.
+func (app *application) WebSocketAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			app.AuthTokenMiddleware(next).ServeHTTP(w, r)
 			return
 		}
 
-		claims, _ := jwtToken.Claims.(jwt.MapClaims)
-		userid, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+		id := r.URL.Query().Get("ticket")
+		if id == "" {
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("authorization ticket is missing"))
+			return
+		}
+
+		ticket, err := app.rooms.RedeemTicket(r.Context(), id)
 		if err != nil {
-			app.unauthorizedErrorResponse(w, r, err)
+			switch {
+			case errors.Is(err, video.ErrTicketInvalid):
+				app.unauthorizedErrorResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+		if ticket.RoomID != chi.URLParam(r, "roomID") {
+			app.unauthorizedErrorResponse(w, r, fmt.Errorf("%w: it is for another room", video.ErrTicketInvalid))
 			return
 		}
 
-		ctx := r.Context()
-		user, err := app.getUser(ctx, userid)
+		user, err := app.getUser(r.Context(), ticket.UserID)
 		if err != nil {
 			app.internalServerError(w, r, err)
 			return
 		}
 
-		ctx = context.WithValue(ctx, userCtx, user)
+		ctx := context.WithValue(r.Context(), userCtx, user)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// serveAuthenticated validates token and serves next with its user in the
+// request context.
+func (app *application) serveAuthenticated(w http.ResponseWriter, r *http.Request, next http.Handler, token string) {
+	jwtToken, err := app.authenticator.ValidateToken(token)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	claims, _ := jwtToken.Claims.(jwt.MapClaims)
+	userid, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user, err := app.getUser(ctx, userid)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ctx = context.WithValue(ctx, userCtx, user)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
 func (app *application) BasicAuthMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
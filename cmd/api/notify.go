@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Althaf66/Appointr/internal/availability"
+	"github.com/Althaf66/Appointr/internal/store"
+)
+
+const emailTimeLayout = "Mon 02 Jan 2006 3:04 PM MST"
+
+// meetingChange describes what happened to a meeting for the emails sent to
+// both participants.
+type meetingChange struct {
+	template string
+	actorID  int64
+	reason   string
+	// previous start, when the meeting moved
+	from *time.Time
+	// proposed or new start
+	to *time.Time
+}
+
+// notifyMeetingParticipants emails the mentee and the mentor about change,
+// rendering times in each recipient's timezone. Failures are logged, not
+// returned: the change itself has already happened.
+func (app *application) notifyMeetingParticipants(ctx context.Context, meeting *store.Meetings, change meetingChange) {
+	mentee, err := app.store.Users.GetByID(ctx, meeting.Userid)
+	if err != nil {
+		app.logger.Errorw("error loading mentee for notification", "meeting", meeting.ID, "error", err)
+		return
+	}
+	mentor, err := app.store.Users.GetByID(ctx, meeting.Mentorid)
+	if err != nil {
+		app.logger.Errorw("error loading mentor for notification", "meeting", meeting.ID, "error", err)
+		return
+	}
+
+	actorName := mentee.Username
+	if change.actorID == mentor.ID {
+		actorName = mentor.Username
+	}
+
+	isProdenv := app.config.env == "production"
+
+	for _, pair := range [][2]*store.User{{mentee, mentor}, {mentor, mentee}} {
+		recipient, other := pair[0], pair[1]
+
+		loc, err := availability.Location(recipient.Timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+
+		when := meeting.StartAt
+		if change.from != nil {
+			when = *change.from
+		}
+
+		vars := struct {
+			Username    string
+			OtherName   string
+			ActorName   string
+			When        string
+			NewWhen     string
+			Reason      string
+			MeetingsURL string
+		}{
+			Username:    recipient.Username,
+			OtherName:   other.Username,
+			ActorName:   actorName,
+			When:        when.In(loc).Format(emailTimeLayout),
+			Reason:      change.reason,
+			MeetingsURL: fmt.Sprintf("%s/profile", app.config.frontendURL),
+		}
+		if change.to != nil {
+			vars.NewWhen = change.to.In(loc).Format(emailTimeLayout)
+		}
+
+		status, err := app.mailer.Send(change.template, recipient.Username, recipient.Email, vars, !isProdenv)
+		if err != nil {
+			app.logger.Errorw("error sending meeting email", "meeting", meeting.ID, "template", change.template, "error", err)
+			continue
+		}
+		app.logger.Infow("Email sent", "status code", status)
+	}
+}
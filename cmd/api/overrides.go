@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Althaf66/Appointr/internal/availability"
+	"github.com/Althaf66/Appointr/internal/store"
+	chi "github.com/go-chi/chi/v5"
+)
+
+type overrideKey string
+
+const overrideCtx overrideKey = "override"
+
+const maxTimeOffDays = 366
+
+type BlockTimePayload struct {
+	From     string `json:"from" validate:"required,datetime=2006-01-02"`
+	To       string `json:"to" validate:"required,datetime=2006-01-02"`
+	Timezone string `json:"timezone" validate:"omitempty,timezone"`
+	Reason   string `json:"reason" validate:"max=255"`
+}
+
+type ExtraHoursPayload struct {
+	Date        string `json:"date" validate:"required,datetime=2006-01-02"`
+	StartTime   string `json:"start_time" validate:"required"`
+	StartPeriod string `json:"start_period" validate:"required,oneof=AM PM"`
+	EndTime     string `json:"end_time" validate:"required"`
+	EndPeriod   string `json:"end_period" validate:"required,oneof=AM PM"`
+	Timezone    string `json:"timezone" validate:"omitempty,timezone"`
+	Reason      string `json:"reason" validate:"max=255"`
+}
+
+type HolidayCalendarsPayload struct {
+	Calendars []string `json:"calendars" validate:"dive,required,max=50"`
+}
+
+// blockTimeHandler godoc
+//
+//	@Summary		Take time off
+//	@Description	Block whole days (inclusive) from the authenticated mentor's schedule. Existing meetings are kept.
+//	@Tags			availability
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		BlockTimePayload	true	"Time off"
+//	@Success		201		{object}	store.AvailabilityOverride
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/overrides/block [post]
+func (app *application) blockTimeHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	var payload BlockTimePayload
+	err := ReadJSON(w, r, &payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	loc, err := app.overrideLocation(r.Context(), user, payload.Timezone)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	from, err := time.ParseInLocation(availabilityDateLayout, payload.From, loc)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	to, err := time.ParseInLocation(availabilityDateLayout, payload.To, loc)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if to.Before(from) {
+		app.badRequestResponse(w, r, errors.New("to must not be before from"))
+		return
+	}
+	if to.Sub(from) > maxTimeOffDays*24*time.Hour {
+		app.badRequestResponse(w, r, errors.New("date range is too large"))
+		return
+	}
+
+	override := &store.AvailabilityOverride{
+		UserID:  user.ID,
+		Kind:    store.OverrideBlocked,
+		StartAt: from,
+		EndAt:   to.AddDate(0, 0, 1),
+		Reason:  payload.Reason,
+	}
+
+	app.createOverride(w, r, override)
+}
+
+// addExtraHoursHandler godoc
+//
+//	@Summary		Add extra hours
+//	@Description	Open extra bookable hours on a single date for the authenticated mentor
+//	@Tags			availability
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ExtraHoursPayload	true	"Extra hours"
+//	@Success		201		{object}	store.AvailabilityOverride
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/overrides/extra [post]
+func (app *application) addExtraHoursHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	var payload ExtraHoursPayload
+	err := ReadJSON(w, r, &payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	loc, err := app.overrideLocation(r.Context(), user, payload.Timezone)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	day, err := time.ParseInLocation(availabilityDateLayout, payload.Date, loc)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	window, err := availability.OnDay(day, payload.StartTime, payload.StartPeriod, payload.EndTime, payload.EndPeriod)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	override := &store.AvailabilityOverride{
+		UserID:  user.ID,
+		Kind:    store.OverrideExtra,
+		StartAt: window.Start,
+		EndAt:   window.End,
+		Reason:  payload.Reason,
+	}
+
+	app.createOverride(w, r, override)
+}
+
+// getOverridesHandler godoc
+//
+//	@Summary		Get my overrides
+//	@Description	Get the authenticated mentor's time off and extra hours overlapping the range, upcoming ones by default
+//	@Tags			availability
+//	@Accept			json
+//	@Produce		json
+//	@Param			from	query		string	false	"First day (YYYY-MM-DD), defaults to today"
+//	@Param			to		query		string	false	"Last day (YYYY-MM-DD), defaults to a year after from"
+//	@Param			tz		query		string	false	"IANA timezone to render in, defaults to the caller's"
+//	@Success		200		{object}	[]store.AvailabilityOverride
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/overrides [get]
+func (app *application) getOverridesHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	loc, err := viewerLocation(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	query := r.URL.Query()
+
+	now := time.Now().In(loc)
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if v := query.Get("from"); v != "" {
+		t, err := time.ParseInLocation(availabilityDateLayout, v, loc)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		from = t
+	}
+
+	to := from.AddDate(1, 0, 0)
+	if v := query.Get("to"); v != "" {
+		t, err := time.ParseInLocation(availabilityDateLayout, v, loc)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		to = t
+	}
+
+	if to.Before(from) {
+		app.badRequestResponse(w, r, errors.New("to must not be before from"))
+		return
+	}
+
+	overrides, err := app.store.Overrides.GetOverridesByUserID(r.Context(), user.ID, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	for _, o := range overrides {
+		o.StartAt = o.StartAt.In(loc)
+		o.EndAt = o.EndAt.In(loc)
+	}
+
+	err = JsonResponse(w, http.StatusOK, overrides)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// deleteOverrideHandler godoc
+//
+//	@Summary		Delete an override
+//	@Description	Remove one of the authenticated mentor's time off or extra hours entries
+//	@Tags			availability
+//	@Accept			json
+//	@Produce		json
+//	@Param			overrideID	path		int64	true	"Override ID"
+//	@Success		200			{object}	nil
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/overrides/{overrideID} [delete]
+func (app *application) deleteOverrideHandler(w http.ResponseWriter, r *http.Request) {
+	override := getOverrideFromCtx(r)
+
+	err := app.store.Overrides.DeleteOverride(r.Context(), override.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// getHolidayCalendarsHandler godoc
+//
+//	@Summary		Get my holiday calendars
+//	@Description	Get the holiday calendars the authenticated mentor follows
+//	@Tags			availability
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]string
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/overrides/holiday-calendars [get]
+func (app *application) getHolidayCalendarsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	calendars, err := app.store.Holidays.GetCalendars(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = JsonResponse(w, http.StatusOK, calendars)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// setHolidayCalendarsHandler godoc
+//
+//	@Summary		Set my holiday calendars
+//	@Description	Replace the holiday calendars the authenticated mentor follows; their dates are not bookable
+//	@Tags			availability
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		HolidayCalendarsPayload	true	"Calendars"
+//	@Success		200		{object}	[]string
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/overrides/holiday-calendars [put]
+func (app *application) setHolidayCalendarsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	var payload HolidayCalendarsPayload
+	err := ReadJSON(w, r, &payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.store.Holidays.SetCalendars(r.Context(), user.ID, payload.Calendars)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.getHolidayCalendarsHandler(w, r)
+}
+
+func (app *application) createOverride(w http.ResponseWriter, r *http.Request, override *store.AvailabilityOverride) {
+	err := app.store.Overrides.CreateOverride(r.Context(), override)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = JsonResponse(w, http.StatusCreated, override)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// overrideLocation is the zone override dates are read in: the one given,
+// else the mentor's.
+func (app *application) overrideLocation(ctx context.Context, user *store.User, timezone string) (*time.Location, error) {
+	if timezone == "" {
+		timezone = app.slotTimezone(ctx, user)
+	}
+	return availability.Location(timezone)
+}
+
+func (app *application) overrideContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(r, "overrideID"), 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+
+		override, err := app.store.Overrides.GetOverrideByID(ctx, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		if override.UserID != getUserfromCtx(r).ID {
+			app.forbidden(w, r)
+			return
+		}
+
+		ctx = context.WithValue(ctx, overrideCtx, override)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getOverrideFromCtx(r *http.Request) *store.AvailabilityOverride {
+	override, _ := r.Context().Value(overrideCtx).(*store.AvailabilityOverride)
+	return override
+}
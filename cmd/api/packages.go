@@ -0,0 +1,292 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Althaf66/Appointr/internal/payments"
+	"github.com/Althaf66/Appointr/internal/store"
+	chi "github.com/go-chi/chi/v5"
+)
+
+// RegisterPackagePayload sells several sessions of one of the mentor's gigs
+// at once, in the gig's currency. valid_days makes the credits lapse, for
+// plans sold by the month.
+type RegisterPackagePayload struct {
+	GigID       int64   `json:"gig_id" validate:"required"`
+	Title       string  `json:"title" validate:"required,max=255"`
+	Description string  `json:"description" validate:"max=1000"`
+	Sessions    int     `json:"sessions" validate:"required,min=1,max=100"`
+	Amount      float64 `json:"amount" validate:"required,gt=0"`
+	ValidDays   *int    `json:"valid_days" validate:"omitempty,min=1,max=3650"`
+}
+
+// createPackageHandler godoc
+//
+//	@Summary		Create a package
+//	@Description	Sell several sessions of one of your gigs as a package
+//	@Tags			packages
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RegisterPackagePayload	true	"Package"
+//	@Success		201		{object}	store.Package
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/packages/create [post]
+func (app *application) createPackageHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	var payload RegisterPackagePayload
+	err := ReadJSON(w, r, &payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	gig, err := app.store.Gig.GetGigByID(r.Context(), payload.GigID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if gig.Userid != user.ID {
+		app.forbidden(w, r)
+		return
+	}
+
+	currency := gig.Currency
+	if currency == "" {
+		currency = app.config.payments.currency
+	}
+
+	pkg := &store.Package{
+		GigID:       gig.ID,
+		Userid:      user.ID,
+		Title:       payload.Title,
+		Description: payload.Description,
+		Sessions:    payload.Sessions,
+		Amount:      payload.Amount,
+		Currency:    currency,
+		ValidDays:   payload.ValidDays,
+	}
+
+	if err := app.store.Packages.CreatePackage(r.Context(), pkg); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := JsonResponse(w, http.StatusCreated, pkg); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getPackagesByUserIDHandler godoc
+//
+//	@Summary		Get a mentor's packages
+//	@Description	Get the packages a mentor currently sells
+//	@Tags			packages
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int64	true	"Mentor's user ID"
+//	@Success		200		{array}		store.Package
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/packages/u/{userID} [get]
+func (app *application) getPackagesByUserIDHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	packages, err := app.store.Packages.GetPackagesByUserID(r.Context(), userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := JsonResponse(w, http.StatusOK, packages); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// deactivatePackageHandler godoc
+//
+//	@Summary		Stop selling a package
+//	@Description	Take a package off sale; credits already bought stay usable. Its mentor or admins only.
+//	@Tags			packages
+//	@Accept			json
+//	@Produce		json
+//	@Param			packageID	path		int64	true	"Package ID"
+//	@Success		204			{object}	string
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/packages/{packageID} [delete]
+func (app *application) deactivatePackageHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	pkg, ok := app.loadPackage(w, r)
+	if !ok {
+		return
+	}
+
+	if pkg.Userid != user.ID && !user.IsAdmin {
+		app.forbidden(w, r)
+		return
+	}
+
+	if err := app.store.Packages.DeactivatePackage(r.Context(), pkg.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// packageCheckoutHandler godoc
+//
+//	@Summary		Buy a package
+//	@Description	Start a checkout for a package. Once paid, its sessions are credits to book the mentor with.
+//	@Tags			packages
+//	@Accept			json
+//	@Produce		json
+//	@Param			packageID	path		int64	true	"Package ID"
+//	@Success		200			{object}	map[string]string
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/packages/{packageID}/checkout [post]
+func (app *application) packageCheckoutHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	pkg, ok := app.loadPackage(w, r)
+	if !ok {
+		return
+	}
+
+	if !pkg.Active {
+		app.conflictResponse(w, r, errors.New("package is no longer sold"))
+		return
+	}
+	if pkg.Userid == user.ID {
+		app.badRequestResponse(w, r, errors.New("mentors can't buy their own packages"))
+		return
+	}
+
+	name := pkg.Title
+	if mentor, err := app.store.Mentor.GetMentorByUserID(r.Context(), pkg.Userid); err == nil {
+		name += " with " + mentor.Name
+	}
+	quote := app.config.payments.pricing.Quote(name, pkg.Amount, pkg.Currency)
+
+	checkout, err := app.payments.CreateCheckout(r.Context(), payments.CheckoutRequest{
+		Quote:            quote,
+		CustomerEmail:    user.Email,
+		AllowedCountries: app.config.payments.allowedCountries,
+		SuccessURL:       app.config.payments.successURL,
+		CancelURL:        app.config.payments.cancelURL,
+		Metadata: map[string]string{
+			"packageid": strconv.FormatInt(pkg.ID, 10),
+		},
+	})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	purchase := &store.Purchase{
+		PackageID:         pkg.ID,
+		BuyerID:           user.ID,
+		MentorID:          pkg.Userid,
+		GigID:             &pkg.GigID,
+		Sessions:          pkg.Sessions,
+		ValidDays:         pkg.ValidDays,
+		Provider:          app.payments.Name(),
+		CheckoutSessionID: checkout.SessionID,
+		Amount:            quote.Total,
+		SessionAmount:     quote.Price,
+		CommissionRate:    app.config.payments.commissionRate,
+		Currency:          quote.Currency,
+	}
+	if err := app.store.Packages.CreatePurchase(r.Context(), purchase); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	writeCheckoutURL(w, checkout.URL)
+}
+
+// getCreditsHandler godoc
+//
+//	@Summary		Get my credits
+//	@Description	Get the package sessions the authenticated user has left, per mentor
+//	@Tags			packages
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{array}		store.Credits
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/credits [get]
+func (app *application) getCreditsHandler(w http.ResponseWriter, r *http.Request) {
+	credits, err := app.store.Packages.GetCredits(r.Context(), getUserfromCtx(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := JsonResponse(w, http.StatusOK, credits); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// loadPackage reads the package named by the packageID URL parameter. On
+// false it has written the response itself.
+func (app *application) loadPackage(w http.ResponseWriter, r *http.Request) (*store.Package, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "packageID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	pkg, err := app.store.Packages.GetPackageByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+
+	return pkg, true
+}
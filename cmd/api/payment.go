@@ -1,80 +1,257 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"strconv"
 
-	"github.com/stripe/stripe-go/v75"
-	"github.com/stripe/stripe-go/v75/checkout/session"
-	"github.com/stripe/stripe-go/v75/webhook"
+	"github.com/Althaf66/Appointr/internal/payments"
+	"github.com/Althaf66/Appointr/internal/store"
 )
 
+// CheckoutPayload names the meeting to pay for and optionally a coupon to
+// apply to it. Everything else, the price included, is read from the store.
+type CheckoutPayload struct {
+	MeetingID  int64  `json:"meeting_id" validate:"required"`
+	CouponCode string `json:"coupon_code" validate:"omitempty,alphanum,max=32"`
+}
+
+// createCheckoutSession godoc
+//
+//	@Summary		Start a checkout
+//	@Description	Create a checkout session for a confirmed meeting, or return the one already open; only its mentee may pay. A coupon code discounts the meeting's amount before it is charged.
+//	@Tags			payments
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CheckoutPayload	true	"Meeting to pay for"
+//	@Success		200		{object}	map[string]string
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/payment/create-checkout-session [post]
 func (app *application) createCheckoutSession(w http.ResponseWriter, r *http.Request) {
-	var paymentData struct {
-		Price      float64 `json:"price"`
-		Currency   string  `json:"currency"`
-		Email      string  `json:"email"`
-		MenteeName string  `json:"menteeName"`
-		MentorName string  `json:"mentorName"`
-		ID         int     `json:"id"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&paymentData); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		log.Printf("json.Decode: %v", err)
+	user := getUserfromCtx(r)
+
+	var payload CheckoutPayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	stripe.Key = "sk_test_51NpubpSF2Iapc3CYMensOJgnQjo4anfwi9MNLOFIjNkOBYRxzEP8gMctadHwISPfAERy31iKNejTs50cRCu1bCxV00NycUfZ06"
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
 
-	domain := "http://localhost:5173/profile"
-	priceInRupees := int64(paymentData.Price * 100)
+	meeting, err := app.store.Meetings.GetMeetingByID(r.Context(), payload.MeetingID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
 
-	params := &stripe.CheckoutSessionParams{
-		CustomerEmail:            stripe.String(paymentData.Email),
-		SubmitType:               stripe.String("book"),
-		BillingAddressCollection: stripe.String("auto"),
-		ShippingAddressCollection: &stripe.CheckoutSessionShippingAddressCollectionParams{
-			AllowedCountries: stripe.StringSlice([]string{
-				"IN",
-			}),
-		},
-		LineItems: []*stripe.CheckoutSessionLineItemParams{
-			&stripe.CheckoutSessionLineItemParams{
-				PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
-					Currency: stripe.String("inr"),
-					ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
-						Name: stripe.String("Mentorship Session with " + paymentData.MentorName),
-					},
-					UnitAmount: stripe.Int64(priceInRupees),
-				},
-				Quantity: stripe.Int64(1),
-			},
-		},
-		Mode:       stripe.String(string(stripe.CheckoutSessionModePayment)),
-		SuccessURL: stripe.String(domain + "?success=true"),
-		CancelURL:  stripe.String(domain + "?canceled=true"),
+	if meeting.Userid != user.ID {
+		app.forbidden(w, r)
+		return
+	}
+
+	if meeting.Status != store.MeetingConfirmed {
+		app.conflictResponse(w, r, fmt.Errorf("meeting is %s, only confirmed meetings can be paid", meeting.Status))
+		return
+	}
+
+	name, currency := app.checkoutProduct(r.Context(), meeting)
+	if payload.CouponCode != "" {
+		coupon, err := app.store.Coupons.ApplyCoupon(r.Context(), meeting, payload.CouponCode, currency)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrCouponInvalid):
+				app.badRequestResponse(w, r, err)
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r, err)
+			case errors.Is(err, store.ErrInvalidTransition):
+				app.conflictResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+		name = couponProduct(name, coupon.Code)
+	} else if meeting.CouponID != nil {
+		if coupon, err := app.store.Coupons.GetCouponByID(r.Context(), *meeting.CouponID); err == nil {
+			name = couponProduct(name, coupon.Code)
+		}
+	}
+	quote := app.config.payments.pricing.Quote(name, meeting.Amount, currency)
+
+	checkout, ok := app.openCheckout(w, r, meeting, quote)
+	if !ok {
+		return
+	}
+	if checkout == nil {
+		checkout, err = app.newCheckout(r.Context(), user, meeting, quote)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+
+	writeCheckoutURL(w, checkout.URL)
+}
+
+// openCheckout looks for a checkout the mentee already started for the
+// meeting, so a second click doesn't open a second way to pay. It returns
+// the checkout when it is still open at the quoted price and nil when a new
+// one is needed, expiring one opened at another price first. On false it has
+// written the response itself.
+func (app *application) openCheckout(w http.ResponseWriter, r *http.Request, meeting *store.Meetings, quote payments.Quote) (*payments.Checkout, bool) {
+	pending, err := app.store.Payments.GetPendingPaymentByMeetingID(r.Context(), meeting.ID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, true
+		}
+		app.internalServerError(w, r, err)
+		return nil, false
+	}
+
+	checkout, err := app.payments.GetCheckout(r.Context(), pending.CheckoutSessionID)
+	if err != nil {
+		// a checkout the provider lost can't be paid; start over
+		app.logger.Warnw("error fetching checkout", "session", pending.CheckoutSessionID, "error", err)
+		return nil, true
+	}
+
+	switch checkout.Status {
+	case payments.CheckoutPaid:
+		// the webhook hasn't arrived yet
+		err := app.store.Payments.CompleteCheckout(r.Context(), meeting.ID, store.MeetingPayment{
+			SessionID:       checkout.SessionID,
+			PaymentIntentID: checkout.PaymentIntentID,
+			Amount:          checkout.Amount,
+			Currency:        checkout.Currency,
+		})
+		if err != nil && !errors.Is(err, store.ErrInvalidTransition) {
+			app.internalServerError(w, r, err)
+			return nil, false
+		}
+		if err == nil {
+			meeting.Status = store.MeetingPaid
+			app.syncMeetingRoom(r.Context(), meeting)
+			app.invoiceCheckout(r.Context(), checkout.SessionID)
+		}
+		app.conflictResponse(w, r, errors.New("meeting is already paid"))
+		return nil, false
+	case payments.CheckoutExpired:
+		if err := app.store.Payments.ExpireCheckout(r.Context(), checkout.SessionID); err != nil {
+			app.internalServerError(w, r, err)
+			return nil, false
+		}
+		return nil, true
+	}
+
+	if checkout.Amount != quote.Total || checkout.Currency != quote.Currency {
+		// the price changed since; close the old session so only the new
+		// one can be paid
+		if err := app.payments.ExpireCheckout(r.Context(), checkout.SessionID); err != nil {
+			switch {
+			case errors.Is(err, payments.ErrRejected):
+				// paid or expired in the meantime; the webhook settles it
+				app.conflictResponse(w, r, errors.New("the open checkout just closed, try again shortly"))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return nil, false
+		}
+		if err := app.store.Payments.ExpireCheckout(r.Context(), checkout.SessionID); err != nil {
+			app.internalServerError(w, r, err)
+			return nil, false
+		}
+		return nil, true
+	}
+	return checkout, true
+}
+
+// newCheckout starts a checkout for quote and records it in the ledger.
+func (app *application) newCheckout(ctx context.Context, user *store.User, meeting *store.Meetings, quote payments.Quote) (*payments.Checkout, error) {
+	checkout, err := app.payments.CreateCheckout(ctx, payments.CheckoutRequest{
+		Quote:            quote,
+		CustomerEmail:    user.Email,
+		AllowedCountries: app.config.payments.allowedCountries,
+		SuccessURL:       app.config.payments.successURL,
+		CancelURL:        app.config.payments.cancelURL,
 		Metadata: map[string]string{
-			"meetingid": strconv.Itoa(paymentData.ID),
+			"meetingid": strconv.FormatInt(meeting.ID, 10),
 		},
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	s, err := session.New(params)
-	if err != nil {
-		http.Error(w, "Failed to create checkout session", http.StatusInternalServerError)
-		log.Printf("session.New: %v", err)
-		return
+	payment := &store.Payment{
+		MeetingID:         meeting.ID,
+		PayerID:           meeting.Userid,
+		PayeeID:           meeting.Mentorid,
+		Provider:          app.payments.Name(),
+		CheckoutSessionID: checkout.SessionID,
+		Amount:            quote.Total,
+		SessionAmount:     quote.Price,
+		CommissionRate:    app.config.payments.commissionRate,
+		FeeAmount:         quote.Fee,
+		TaxAmount:         quote.Tax,
+		Currency:          quote.Currency,
 	}
+	if err := app.store.Payments.CreatePayment(ctx, payment); err != nil {
+		return nil, err
+	}
+
+	return checkout, nil
+}
 
+func writeCheckoutURL(w http.ResponseWriter, url string) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
-		"url": s.URL,
+		"url": url,
 	})
 }
 
+// checkoutProduct names the session line item after the mentor and, when
+// the meeting was booked under one, the gig, whose currency it is charged in.
+func (app *application) checkoutProduct(ctx context.Context, meeting *store.Meetings) (name, currency string) {
+	name = "Mentorship Session"
+	currency = app.config.payments.currency
+	if mentor, err := app.store.Mentor.GetMentorByUserID(ctx, meeting.Mentorid); err == nil {
+		name += " with " + mentor.Name
+	}
+	if meeting.GigID != nil {
+		if gig, err := app.store.Gig.GetGigByID(ctx, *meeting.GigID); err == nil {
+			name = gig.Title + " - " + name
+			if gig.Currency != "" {
+				currency = gig.Currency
+			}
+		}
+	}
+	return name, currency
+}
+
+// couponProduct notes the coupon on the line item, whose price is already
+// discounted.
+func couponProduct(name, code string) string {
+	return name + " (coupon " + code + ")"
+}
+
 func (app *application) handleWebhook(w http.ResponseWriter, r *http.Request) {
 	const MaxBodyBytes = int64(65536)
 	r.Body = http.MaxBytesReader(w, r.Body, MaxBodyBytes)
@@ -85,58 +262,330 @@ func (app *application) handleWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Verify webhook signature
-	event, err := webhook.ConstructEvent(payload, r.Header.Get("Stripe-Signature"), app.config.stripeWebhook)
+	event, err := app.payments.VerifyWebhook(payload, r.Header)
 	if err != nil {
 		http.Error(w, "Webhook signature verification failed", http.StatusBadRequest)
-		log.Printf("webhook.ConstructEvent: %v", err)
+		app.logger.Warnw("rejected webhook", "error", err)
 		return
 	}
 
-	// Handle the checkout.session.completed event
-	if event.Type == "checkout.session.completed" {
-		var checkoutSession stripe.CheckoutSession
-		err := json.Unmarshal(event.Data.Raw, &checkoutSession)
-		if err != nil {
-			http.Error(w, "Error parsing webhook JSON", http.StatusInternalServerError)
-			log.Printf("json.Unmarshal: %v", err)
-			return
+	record := &store.WebhookEvent{
+		Provider:  app.payments.Name(),
+		EventID:   event.ID,
+		EventType: string(event.Type),
+		Payload:   payload,
+	}
+	claimed, err := app.store.WebhookEvents.ClaimWebhookEvent(r.Context(), record)
+	if err != nil {
+		app.logger.Errorw("error recording webhook event", "event", event.ID, "error", err)
+		http.Error(w, "Failed to process webhook event", http.StatusInternalServerError)
+		return
+	}
+	if !claimed {
+		// a redelivery of an event that was handled or is being handled
+		app.logger.Debugw("duplicate webhook event", "event", event.ID)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if err := app.processWebhookEvent(r.Context(), record, event); err != nil {
+		// a 5xx makes the provider deliver the event again
+		http.Error(w, "Failed to process webhook event", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// processWebhookEvent acts on a claimed event and records the outcome on it.
+// It returns the errors worth a redelivery; unprocessable events are kept as
+// failed for an admin to look at and reprocess, and acknowledged.
+func (app *application) processWebhookEvent(ctx context.Context, record *store.WebhookEvent, event *payments.Event) error {
+	status := store.WebhookProcessed
+	var err error
+	switch event.Type {
+	case payments.EventCheckoutCompleted:
+		err = app.handleCheckoutCompleted(ctx, event)
+	case payments.EventCheckoutExpired:
+		err = app.handleCheckoutExpired(ctx, event)
+	case payments.EventCheckoutFailed:
+		err = app.handleCheckoutFailed(ctx, event)
+	case payments.EventChargeRefunded:
+		err = app.handleChargeRefunded(ctx, event)
+	case payments.EventDisputeCreated:
+		err = app.handleDisputeCreated(ctx, event)
+	default:
+		status = store.WebhookIgnored
+	}
+
+	var errMsg string
+	if err != nil {
+		status = store.WebhookFailed
+		errMsg = err.Error()
+	}
+	if ferr := app.store.WebhookEvents.FinishWebhookEvent(ctx, record.ID, status, errMsg); ferr != nil {
+		app.logger.Errorw("error recording webhook outcome", "event", record.EventID, "status", status, "error", ferr)
+	}
+
+	var unprocessable *unprocessableEventError
+	if errors.As(err, &unprocessable) {
+		return nil
+	}
+	return err
+}
+
+// unprocessableEventError is a webhook event that fails the same way however
+// often it is delivered, until something is fixed by hand.
+type unprocessableEventError struct {
+	err error
+}
+
+func (e *unprocessableEventError) Error() string {
+	return e.err.Error()
+}
+
+func (e *unprocessableEventError) Unwrap() error {
+	return e.err
+}
+
+func unprocessableEvent(err error) error {
+	return &unprocessableEventError{err: err}
+}
+
+// The webhook event handlers return plain errors for failures worth a
+// redelivery and unprocessableEvent ones for events a human has to look at.
+
+func (app *application) handleCheckoutCompleted(ctx context.Context, event *payments.Event) error {
+	if _, ok := event.Metadata["packageid"]; ok {
+		return app.handlePurchaseCompleted(ctx, event)
+	}
+
+	meetingID, err := strconv.ParseInt(event.Metadata["meetingid"], 10, 64)
+	if err != nil {
+		// the session wasn't created by us
+		app.logger.Warnw("checkout session without meeting id", "session", event.SessionID)
+		return unprocessableEvent(fmt.Errorf("checkout session %s has no meeting id", event.SessionID))
+	}
+
+	payment := store.MeetingPayment{
+		SessionID:       event.SessionID,
+		PaymentIntentID: event.PaymentIntentID,
+		Amount:          event.Amount,
+		Currency:        event.Currency,
+	}
+	err = app.store.Payments.CompleteCheckout(ctx, meetingID, payment)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidTransition):
+			// paid through another checkout, or cancelled while this one was open
+			app.logger.Warnw("checkout completed for a meeting that can't be paid", "meeting", meetingID, "session", event.SessionID, "error", err)
+			return app.refundUnappliedPayment(ctx, meetingID, payment)
+		case errors.Is(err, store.ErrNotFound):
+			app.logger.Errorw("could not mark meeting paid", "meeting", meetingID, "session", event.SessionID, "error", err)
+			return unprocessableEvent(err)
+		default:
+			app.logger.Errorw("error marking meeting paid", "meeting", meetingID, "session", event.SessionID, "error", err)
+			return err
 		}
+	}
 
-		// Extract mentorId from metadata
-		meetingID, ok := checkoutSession.Metadata["meetingid"]
-		if !ok {
-			log.Printf("mentorId not found in metadata")
-			http.Error(w, "mentorId not found", http.StatusInternalServerError)
-			return
+	app.logger.Infow("meeting paid", "meeting", meetingID, "session", event.SessionID)
+	if meeting, err := app.store.Meetings.GetMeetingByID(ctx, meetingID); err == nil {
+		app.syncMeetingRoom(ctx, meeting)
+	}
+	app.invoiceCheckout(ctx, event.SessionID)
+	return nil
+}
+
+// refundUnappliedPayment records a checkout the meeting couldn't be paid
+// with and gives all of it back. A refund the provider rejects leaves the
+// event failed, for an admin to settle.
+func (app *application) refundUnappliedPayment(ctx context.Context, meetingID int64, mp store.MeetingPayment) error {
+	payment, err := app.store.Payments.RecordUnappliedPayment(ctx, meetingID, mp)
+	if err != nil {
+		app.logger.Errorw("error recording unapplied payment", "meeting", meetingID, "session", mp.SessionID, "error", err)
+		if errors.Is(err, store.ErrNotFound) {
+			return unprocessableEvent(err)
 		}
-		log.Println("meetingid", meetingID)
-		// Call the API to mark the meeting as paid using PUT method
-		apiURL := "http://localhost:8080/v1/meetings/paid/" + meetingID
-		req, err := http.NewRequest(http.MethodPut, apiURL, nil)
-		if err != nil {
-			log.Printf("Failed to create PUT request: %v", err)
-			http.Error(w, "Failed to update payment status", http.StatusInternalServerError)
-			return
+		return err
+	}
+
+	amount := payment.Refundable()
+	if amount <= 0 {
+		return nil
+	}
+
+	re, err := app.payments.Refund(ctx, payments.RefundRequest{
+		PaymentIntentID: payment.PaymentIntentID,
+		Amount:          amount,
+		IdempotencyKey:  fmt.Sprintf("unapplied-%d-%d", payment.ID, payment.RefundedAmount),
+		Metadata: map[string]string{
+			"meetingid": strconv.FormatInt(meetingID, 10),
+			"paymentid": strconv.FormatInt(payment.ID, 10),
+		},
+	})
+	if err != nil {
+		app.logger.Errorw("error refunding unapplied payment", "meeting", meetingID, "payment", payment.ID, "error", err)
+		if errors.Is(err, payments.ErrRejected) {
+			return unprocessableEvent(err)
 		}
+		return err
+	}
 
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Printf("Failed to call paid API: %v", err)
-			http.Error(w, "Failed to update payment status", http.StatusInternalServerError)
-			return
+	err = app.store.Payments.RecordRefund(ctx, &store.Refund{
+		PaymentID:        payment.ID,
+		ProviderRefundID: re.ID,
+		Amount:           re.Amount,
+		Currency:         re.Currency,
+		Reason:           "the meeting could no longer be paid",
+	})
+	if err != nil {
+		// the refund went through; the charge.refunded webhook fixes the ledger
+		app.logger.Errorw("error recording refund", "meeting", meetingID, "refund", re.ID, "error", err)
+		return nil
+	}
+
+	app.logger.Infow("unapplied payment refunded", "meeting", meetingID, "payment", payment.ID, "refund", re.ID)
+	return nil
+}
+
+// handlePurchaseCompleted makes the credits of a paid package usable.
+func (app *application) handlePurchaseCompleted(ctx context.Context, event *payments.Event) error {
+	err := app.store.Packages.CompletePurchase(ctx, store.MeetingPayment{
+		SessionID:       event.SessionID,
+		PaymentIntentID: event.PaymentIntentID,
+		Amount:          event.Amount,
+		Currency:        event.Currency,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.logger.Errorw("checkout for unknown package purchase", "session", event.SessionID, "package", event.Metadata["packageid"])
+			return unprocessableEvent(err)
+		default:
+			app.logger.Errorw("error completing package purchase", "session", event.SessionID, "error", err)
+			return err
 		}
-		defer resp.Body.Close()
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			log.Printf("returned non-OK status: %v", resp.Status)
-			http.Error(w, "Failed to update payment status", http.StatusInternalServerError)
-			return
+	app.logger.Infow("package purchased", "package", event.Metadata["packageid"], "session", event.SessionID)
+	return nil
+}
+
+// handleCheckoutExpired closes out a session the mentee abandoned. The
+// meeting stays confirmed so they can check out again.
+func (app *application) handleCheckoutExpired(ctx context.Context, event *payments.Event) error {
+	if err := app.store.Payments.ExpireCheckout(ctx, event.SessionID); err != nil {
+		app.logger.Errorw("error expiring checkout", "session", event.SessionID, "error", err)
+		return err
+	}
+	if err := app.store.Packages.ExpirePurchase(ctx, event.SessionID); err != nil {
+		app.logger.Errorw("error expiring package purchase", "session", event.SessionID, "error", err)
+		return err
+	}
+
+	app.logger.Infow("checkout expired", "session", event.SessionID)
+	return nil
+}
+
+// handleCheckoutFailed closes out a session whose delayed payment didn't go
+// through. Like an expired one, it leaves the meeting confirmed and a
+// package purchase to be made again.
+func (app *application) handleCheckoutFailed(ctx context.Context, event *payments.Event) error {
+	if err := app.store.Payments.FailCheckout(ctx, event.SessionID); err != nil {
+		app.logger.Errorw("error failing checkout", "session", event.SessionID, "error", err)
+		return err
+	}
+	if err := app.store.Packages.ExpirePurchase(ctx, event.SessionID); err != nil {
+		app.logger.Errorw("error expiring package purchase", "session", event.SessionID, "error", err)
+		return err
+	}
+
+	app.logger.Infow("checkout payment failed", "session", event.SessionID)
+	return nil
+}
+
+// handleChargeRefunded catches the ledger up with refunds, including ones
+// made from the provider's dashboard.
+func (app *application) handleChargeRefunded(ctx context.Context, event *payments.Event) error {
+	if event.PaymentIntentID == "" {
+		app.logger.Warnw("refunded charge without payment intent", "event", event.ID)
+		return unprocessableEvent(errors.New("refunded charge has no payment intent"))
+	}
+
+	err := app.store.Payments.SyncRefundedAmount(ctx, event.PaymentIntentID, event.Amount)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.logger.Errorw("refund for unknown payment", "event", event.ID, "payment_intent", event.PaymentIntentID)
+			return unprocessableEvent(err)
+		default:
+			app.logger.Errorw("error recording refund", "event", event.ID, "error", err)
+			return err
 		}
+	}
 
-		log.Printf("Successfully marked meeting as paid for mentorId: %s", meetingID)
+	app.logger.Infow("charge refunded", "payment_intent", event.PaymentIntentID, "amount_refunded", event.Amount)
+	return nil
+}
+
+// handleDisputeCreated flags the payment so it is no longer refunded through
+// the API; the dispute itself is answered in the provider's dashboard.
+func (app *application) handleDisputeCreated(ctx context.Context, event *payments.Event) error {
+	if event.PaymentIntentID == "" {
+		app.logger.Warnw("dispute without payment intent", "event", event.ID)
+		return unprocessableEvent(errors.New("dispute has no payment intent"))
 	}
 
-	w.WriteHeader(http.StatusOK)
+	err := app.store.Payments.MarkPaymentDisputed(ctx, event.PaymentIntentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.logger.Errorw("dispute for unknown payment", "event", event.ID, "payment_intent", event.PaymentIntentID)
+			return unprocessableEvent(err)
+		default:
+			app.logger.Errorw("error recording dispute", "event", event.ID, "error", err)
+			return err
+		}
+	}
+
+	app.logger.Warnw("payment disputed", "event", event.ID, "payment_intent", event.PaymentIntentID, "reason", event.Reason)
+	return nil
+}
+
+// getPaymentsHandler godoc
+//
+//	@Summary		Get my payments
+//	@Description	Get the payments the authenticated user made as a mentee or received as a mentor, newest first
+//	@Tags			payments
+//	@Accept			json
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit"		default(20)
+//	@Param			offset	query		int	false	"Offset"	default(0)
+//	@Success		200		{array}		store.Payment
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/payments [get]
+func (app *application) getPaymentsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	payments, err := app.store.Payments.GetPaymentsByUserID(r.Context(), user.ID, limit, offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := JsonResponse(w, http.StatusOK, payments); err != nil {
+		app.internalServerError(w, r, err)
+	}
 }
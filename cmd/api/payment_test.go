@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Althaf66/Appointr/internal/store"
+	"github.com/stripe/stripe-go/v75"
+	"github.com/stripe/stripe-go/v75/webhook"
+)
+
+// stripeEvent builds a webhook delivery of typ about object, signed the
+// way Stripe signs them.
+func stripeEvent(t *testing.T, id, typ string, object map[string]any) *http.Request {
+	t.Helper()
+
+	payload, err := json.Marshal(map[string]any{
+		"id":          id,
+		"object":      "event",
+		"api_version": stripe.APIVersion,
+		"type":        typ,
+		"data":        map[string]any{"object": object},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{Payload: payload, Secret: testWebhookSecret})
+	req := httptest.NewRequest(http.MethodPost, "/v1/webhook", bytes.NewReader(payload))
+	req.Header.Set("Stripe-Signature", signed.Header)
+	return req
+}
+
+func TestHandleWebhook(t *testing.T) {
+	const (
+		menteeID  = 1
+		mentorID  = 2
+		meetingID = 10
+		paymentID = 100
+		intentID  = "pi_test"
+		sessionID = "cs_test"
+		paid      = 5000
+	)
+
+	tests := []struct {
+		name       string
+		status     store.MeetingStatus
+		eventType  string
+		object     map[string]any
+		wantCode   int
+		wantEvent  string
+		wantError  bool
+		wantPaid   store.Payment
+		wantStatus store.MeetingStatus
+		// wantStripeRefund is what the app should refund at Stripe
+		wantStripeRefund int64
+		wantExpired      string
+		wantFailed       string
+	}{
+		{
+			name:      "charge partly refunded from the dashboard",
+			status:    store.MeetingCompleted,
+			eventType: "charge.refunded",
+			object: map[string]any{
+				"id": "ch_test", "object": "charge", "amount_refunded": 2000, "currency": "usd",
+				"payment_intent": intentID,
+			},
+			wantCode:   http.StatusOK,
+			wantEvent:  store.WebhookProcessed,
+			wantPaid:   store.Payment{RefundedAmount: 2000, Status: store.PaymentPartiallyRefunded},
+			wantStatus: store.MeetingCompleted,
+		},
+		{
+			name:      "charge fully refunded",
+			status:    store.MeetingPaid,
+			eventType: "charge.refunded",
+			object: map[string]any{
+				"id": "ch_test", "object": "charge", "amount_refunded": paid, "currency": "usd",
+				"payment_intent": intentID,
+			},
+			wantCode:   http.StatusOK,
+			wantEvent:  store.WebhookProcessed,
+			wantPaid:   store.Payment{RefundedAmount: paid, Status: store.PaymentRefunded},
+			wantStatus: store.MeetingRefunded,
+		},
+		{
+			name:      "refund of a charge we don't know",
+			status:    store.MeetingPaid,
+			eventType: "charge.refunded",
+			object: map[string]any{
+				"id": "ch_other", "object": "charge", "amount_refunded": 100, "currency": "usd",
+				"payment_intent": "pi_other",
+			},
+			wantCode:   http.StatusOK,
+			wantEvent:  store.WebhookFailed,
+			wantError:  true,
+			wantPaid:   store.Payment{Status: store.PaymentSucceeded},
+			wantStatus: store.MeetingPaid,
+		},
+		{
+			name:      "dispute opened",
+			status:    store.MeetingCompleted,
+			eventType: "charge.dispute.created",
+			object: map[string]any{
+				"id": "dp_test", "object": "dispute", "amount": paid, "currency": "usd", "reason": "fraudulent",
+				"payment_intent": intentID,
+			},
+			wantCode:   http.StatusOK,
+			wantEvent:  store.WebhookProcessed,
+			wantPaid:   store.Payment{Status: store.PaymentDisputed},
+			wantStatus: store.MeetingCompleted,
+		},
+		{
+			name:      "dispute without a payment intent",
+			status:    store.MeetingCompleted,
+			eventType: "charge.dispute.created",
+			object: map[string]any{
+				"id": "dp_test", "object": "dispute", "amount": paid, "currency": "usd", "reason": "fraudulent",
+			},
+			wantCode:   http.StatusOK,
+			wantEvent:  store.WebhookFailed,
+			wantError:  true,
+			wantPaid:   store.Payment{Status: store.PaymentSucceeded},
+			wantStatus: store.MeetingCompleted,
+		},
+		{
+			name:      "checkout expired",
+			status:    store.MeetingPaid,
+			eventType: "checkout.session.expired",
+			object: map[string]any{
+				"id": "cs_stale", "object": "checkout.session", "amount_total": paid, "currency": "usd",
+				"metadata": map[string]string{"meetingid": "10"},
+			},
+			wantCode:    http.StatusOK,
+			wantEvent:   store.WebhookProcessed,
+			wantPaid:    store.Payment{Status: store.PaymentSucceeded},
+			wantStatus:  store.MeetingPaid,
+			wantExpired: "cs_stale",
+		},
+		{
+			name:      "second checkout completed for a paid meeting",
+			status:    store.MeetingPaid,
+			eventType: "checkout.session.completed",
+			object: map[string]any{
+				"id": "cs_second", "object": "checkout.session", "amount_total": paid, "currency": "usd",
+				"metadata": map[string]string{"meetingid": "10"}, "payment_intent": "pi_second",
+				"payment_status": "paid",
+			},
+			wantCode:         http.StatusOK,
+			wantEvent:        store.WebhookProcessed,
+			wantPaid:         store.Payment{Status: store.PaymentSucceeded},
+			wantStatus:       store.MeetingPaid,
+			wantStripeRefund: paid,
+		},
+		{
+			name:      "checkout completed while a bank debit is processing",
+			status:    store.MeetingConfirmed,
+			eventType: "checkout.session.completed",
+			object: map[string]any{
+				"id": "cs_debit", "object": "checkout.session", "amount_total": paid, "currency": "usd",
+				"metadata": map[string]string{"meetingid": "10"}, "payment_intent": "pi_debit",
+				"payment_status": "unpaid",
+			},
+			wantCode:   http.StatusOK,
+			wantEvent:  store.WebhookIgnored,
+			wantPaid:   store.Payment{Status: store.PaymentSucceeded},
+			wantStatus: store.MeetingConfirmed,
+		},
+		{
+			name:      "bank debit succeeded",
+			status:    store.MeetingConfirmed,
+			eventType: "checkout.session.async_payment_succeeded",
+			object: map[string]any{
+				"id": "cs_debit", "object": "checkout.session", "amount_total": paid, "currency": "usd",
+				"metadata": map[string]string{"meetingid": "10"}, "payment_intent": "pi_debit",
+				"payment_status": "paid",
+			},
+			wantCode:   http.StatusOK,
+			wantEvent:  store.WebhookProcessed,
+			wantPaid:   store.Payment{Status: store.PaymentSucceeded},
+			wantStatus: store.MeetingPaid,
+		},
+		{
+			name:      "bank debit failed",
+			status:    store.MeetingConfirmed,
+			eventType: "checkout.session.async_payment_failed",
+			object: map[string]any{
+				"id": "cs_debit", "object": "checkout.session", "amount_total": paid, "currency": "usd",
+				"metadata": map[string]string{"meetingid": "10"}, "payment_intent": "pi_debit",
+				"payment_status": "unpaid",
+			},
+			wantCode:   http.StatusOK,
+			wantEvent:  store.WebhookProcessed,
+			wantPaid:   store.Payment{Status: store.PaymentSucceeded},
+			wantStatus: store.MeetingConfirmed,
+			wantFailed: "cs_debit",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stripe := newStripeStub(t)
+			stripe.charge(intentID, paid)
+			stripe.charge("pi_second", paid)
+
+			app, ts := newTestApplication(t, stripe.URL)
+			startAt := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+			ts.addMeeting(&store.Meetings{
+				ID:              meetingID,
+				Userid:          menteeID,
+				Mentorid:        mentorID,
+				StartAt:         startAt,
+				DurationMinutes: 60,
+				Status:          tt.status,
+				MaxParticipants: 2,
+			})
+			paidAt := startAt.Add(-72 * time.Hour)
+			ts.addPayment(&store.Payment{
+				ID:                paymentID,
+				MeetingID:         meetingID,
+				PayerID:           menteeID,
+				PayeeID:           mentorID,
+				CheckoutSessionID: sessionID,
+				PaymentIntentID:   intentID,
+				Amount:            paid,
+				Currency:          "usd",
+				Status:            store.PaymentSucceeded,
+				PaidAt:            &paidAt,
+			})
+
+			rr := httptest.NewRecorder()
+			app.handleWebhook(rr, stripeEvent(t, "evt_test", tt.eventType, tt.object))
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("got status %d, want %d: %s", rr.Code, tt.wantCode, rr.Body)
+			}
+
+			event := ts.event("evt_test")
+			if event.Status != tt.wantEvent {
+				t.Errorf("event is %s, want %s (%s)", event.Status, tt.wantEvent, event.LastError)
+			}
+			if (event.LastError != "") != tt.wantError {
+				t.Errorf("event error %q, want one: %v", event.LastError, tt.wantError)
+			}
+
+			payment := ts.payment(paymentID)
+			if payment.RefundedAmount != tt.wantPaid.RefundedAmount || payment.Status != tt.wantPaid.Status {
+				t.Errorf("payment is %s with %d refunded, want %s with %d",
+					payment.Status, payment.RefundedAmount, tt.wantPaid.Status, tt.wantPaid.RefundedAmount)
+			}
+			if got := ts.meeting(meetingID).Status; got != tt.wantStatus {
+				t.Errorf("meeting is %s, want %s", got, tt.wantStatus)
+			}
+
+			var refunded int64
+			for _, req := range stripe.refundRequests() {
+				if req.Get("payment_intent") != "pi_second" {
+					t.Errorf("refunded payment intent %s", req.Get("payment_intent"))
+				}
+				refunded += mustParseInt(t, req.Get("amount"))
+			}
+			if refunded != tt.wantStripeRefund {
+				t.Errorf("refunded %d at Stripe, want %d", refunded, tt.wantStripeRefund)
+			}
+			if tt.wantStripeRefund > 0 {
+				second := ts.paymentBySession("cs_second")
+				if second == nil || !second.Unapplied || second.Status != store.PaymentRefunded {
+					t.Errorf("second checkout was not recorded as refunded and unapplied: %+v", second)
+				}
+			}
+
+			if tt.wantExpired != "" && (len(ts.expired) != 1 || ts.expired[0] != tt.wantExpired) {
+				t.Errorf("expired checkouts %v, want [%s]", ts.expired, tt.wantExpired)
+			}
+			if tt.wantFailed != "" && (len(ts.failed) != 1 || ts.failed[0] != tt.wantFailed) {
+				t.Errorf("failed checkouts %v, want [%s]", ts.failed, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestHandleWebhookRejectsBadSignatures(t *testing.T) {
+	app, ts := newTestApplication(t, newStripeStub(t).URL)
+
+	req := stripeEvent(t, "evt_test", "charge.refunded", map[string]any{"id": "ch_test", "object": "charge"})
+	req.Header.Set("Stripe-Signature", "t=1,v1=00")
+	rr := httptest.NewRecorder()
+	app.handleWebhook(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+	if len(ts.events) != 0 {
+		t.Errorf("recorded %d events from a forged delivery", len(ts.events))
+	}
+}
+
+func TestHandleWebhookIgnoresRedeliveries(t *testing.T) {
+	stripe := newStripeStub(t)
+	stripe.charge("pi_second", 5000)
+	app, ts := newTestApplication(t, stripe.URL)
+	ts.addMeeting(&store.Meetings{ID: 10, Userid: 1, Mentorid: 2, Status: store.MeetingCompleted, MaxParticipants: 2})
+
+	object := map[string]any{
+		"id": "cs_second", "object": "checkout.session", "amount_total": 5000, "currency": "usd",
+		"metadata": map[string]string{"meetingid": "10"}, "payment_intent": "pi_second",
+		"payment_status": "paid",
+	}
+	for range 2 {
+		rr := httptest.NewRecorder()
+		app.handleWebhook(rr, stripeEvent(t, "evt_test", "checkout.session.completed", object))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("got status %d, want %d: %s", rr.Code, http.StatusOK, rr.Body)
+		}
+	}
+
+	if got := len(stripe.refundRequests()); got != 1 {
+		t.Errorf("refunded %d times at Stripe, want once", got)
+	}
+}
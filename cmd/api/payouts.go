@@ -0,0 +1,288 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Althaf66/Appointr/internal/earnings"
+	"github.com/Althaf66/Appointr/internal/store"
+	chi "github.com/go-chi/chi/v5"
+)
+
+// MentorPayouts is a mentor's balances with the payouts they asked for.
+type MentorPayouts struct {
+	Balances []*earnings.Balance `json:"balances"`
+	Payouts  []*store.Payout     `json:"payouts"`
+}
+
+// CreatePayoutPayload asks for earnings to be paid out. Without an amount
+// everything withdrawable in the currency is requested, and the currency may
+// be left out when the mentor only earns in one.
+type CreatePayoutPayload struct {
+	// Amount is in the currency's minor unit
+	Amount   *int64 `json:"amount" validate:"omitempty,min=1"`
+	Currency string `json:"currency" validate:"omitempty,len=3,alpha"`
+	Note     string `json:"note" validate:"max=500"`
+}
+
+type DecidePayoutPayload struct {
+	Note string `json:"note" validate:"max=500"`
+}
+
+// getMentorPayoutsHandler godoc
+//
+//	@Summary		Get mentor payouts
+//	@Description	The mentor's balance per currency and every payout they requested, newest first; mentor or admins only
+//	@Tags			mentor
+//	@Accept			json
+//	@Produce		json
+//	@Param			mentorID	path		int64	true	"Mentor ID"
+//	@Success		200			{object}	MentorPayouts
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/mentors/{mentorID}/payouts [get]
+func (app *application) getMentorPayoutsHandler(w http.ResponseWriter, r *http.Request) {
+	mentor := getMentorFromCtx(r)
+
+	balances, err := app.earnings.Balances(r.Context(), mentor.Userid)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	payouts, err := app.store.Payouts.GetPayoutsByMentorID(r.Context(), mentor.Userid)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := JsonResponse(w, http.StatusOK, MentorPayouts{Balances: balances, Payouts: payouts}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// createPayoutHandler godoc
+//
+//	@Summary		Request a payout
+//	@Description	Ask for available earnings to be paid out. Only one request per currency may wait for review at a time.
+//	@Tags			mentor
+//	@Accept			json
+//	@Produce		json
+//	@Param			mentorID	path		int64				true	"Mentor ID"
+//	@Param			payload		body		CreatePayoutPayload	true	"Payout"
+//	@Success		201			{object}	store.Payout
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/mentors/{mentorID}/payouts [post]
+func (app *application) createPayoutHandler(w http.ResponseWriter, r *http.Request) {
+	mentor := getMentorFromCtx(r)
+
+	var payload CreatePayoutPayload
+	err := ReadJSON(w, r, &payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	balances, err := app.earnings.Balances(r.Context(), mentor.Userid)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	currency := strings.ToLower(payload.Currency)
+	if currency == "" {
+		if len(balances) != 1 {
+			app.badRequestResponse(w, r, errors.New("currency is required"))
+			return
+		}
+		currency = balances[0].Currency
+	}
+
+	balance := &earnings.Balance{Currency: currency}
+	for _, b := range balances {
+		if b.Currency == currency {
+			balance = b
+		}
+	}
+
+	amount := balance.Withdrawable
+	if payload.Amount != nil {
+		amount = *payload.Amount
+	}
+	if amount <= 0 {
+		app.conflictResponse(w, r, fmt.Errorf("%w: nothing to withdraw in %s", store.ErrInsufficientBalance, currency))
+		return
+	}
+
+	payout := &store.Payout{
+		MentorID: mentor.Userid,
+		Amount:   amount,
+		Currency: currency,
+		Note:     payload.Note,
+	}
+	if err := app.store.Payouts.CreatePayout(r.Context(), payout, balance.Available); err != nil {
+		switch {
+		case errors.Is(err, store.ErrInsufficientBalance), errors.Is(err, store.ErrPayoutPending):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := JsonResponse(w, http.StatusCreated, payout); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getPayoutsHandler godoc
+//
+//	@Summary		List payouts
+//	@Description	List payout requests of all mentors, oldest first; admins only
+//	@Tags			payments
+//	@Accept			json
+//	@Produce		json
+//	@Param			status	query		string	false	"Only payouts in this status"	Enums(requested, approved, rejected)
+//	@Param			limit	query		int		false	"Limit"							default(20)
+//	@Param			offset	query		int		false	"Offset"						default(0)
+//	@Success		200		{array}		store.Payout
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/payouts [get]
+func (app *application) getPayoutsHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", store.PayoutRequested, store.PayoutApproved, store.PayoutRejected:
+	default:
+		app.badRequestResponse(w, r, fmt.Errorf("unknown status %q", status))
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	payouts, err := app.store.Payouts.GetPayouts(r.Context(), status, limit, offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := JsonResponse(w, http.StatusOK, payouts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// approvePayoutHandler godoc
+//
+//	@Summary		Approve a payout
+//	@Description	Approve a requested payout once it has been paid to the mentor; admins only
+//	@Tags			payments
+//	@Accept			json
+//	@Produce		json
+//	@Param			payoutID	path		int64				true	"Payout ID"
+//	@Param			payload		body		DecidePayoutPayload	true	"Decision"
+//	@Success		200			{object}	store.Payout
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/payouts/{payoutID}/approve [post]
+func (app *application) approvePayoutHandler(w http.ResponseWriter, r *http.Request) {
+	app.decidePayout(w, r, store.PayoutApproved)
+}
+
+// rejectPayoutHandler godoc
+//
+//	@Summary		Reject a payout
+//	@Description	Turn down a requested payout, giving the amount back to the mentor's balance; admins only
+//	@Tags			payments
+//	@Accept			json
+//	@Produce		json
+//	@Param			payoutID	path		int64				true	"Payout ID"
+//	@Param			payload		body		DecidePayoutPayload	true	"Decision"
+//	@Success		200			{object}	store.Payout
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/payouts/{payoutID}/reject [post]
+func (app *application) rejectPayoutHandler(w http.ResponseWriter, r *http.Request) {
+	app.decidePayout(w, r, store.PayoutRejected)
+}
+
+func (app *application) decidePayout(w http.ResponseWriter, r *http.Request, status string) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "payoutID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var payload DecidePayoutPayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	payout, err := app.store.Payouts.GetPayoutByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	err = app.store.Payouts.DecidePayout(r.Context(), payout, status, getUserfromCtx(r).ID, payload.Note)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrPayoutDecided):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if err := JsonResponse(w, http.StatusOK, payout); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
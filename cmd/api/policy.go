@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Althaf66/Appointr/internal/store"
+	chi "github.com/go-chi/chi/v5"
+)
+
+// meetingPolicy decides whether user may act on meeting.
+type meetingPolicy func(user *store.User, meeting *store.Meetings) bool
+
+// meetingReader lets participants and admins see a meeting.
+func meetingReader(user *store.User, meeting *store.Meetings) bool {
+	return user.IsAdmin || isMeetingParticipant(meeting, user.ID)
+}
+
+// meetingParticipant lets only the mentee and the mentor act on a meeting.
+func meetingParticipant(user *store.User, meeting *store.Meetings) bool {
+	return isMeetingParticipant(meeting, user.ID)
+}
+
+// meetingMentor lets only the meeting's mentor manage it.
+func meetingMentor(user *store.User, meeting *store.Meetings) bool {
+	return meeting.Mentorid == user.ID
+}
+
+// canSetMeetingStatus decides who may move a meeting to status. Payment
+// states are left to admins; the payment flow sets them itself. Cancelling
+// only goes through cancelMeetingHandler, which enforces the cancellation
+// window and tells the other side.
+func canSetMeetingStatus(user *store.User, meeting *store.Meetings, status store.MeetingStatus) bool {
+	switch status {
+	case store.MeetingConfirmed, store.MeetingInProgress, store.MeetingCompleted, store.MeetingNoShow:
+		return meetingMentor(user, meeting)
+	case store.MeetingPaid, store.MeetingRefunded:
+		return user.IsAdmin
+	}
+	return false
+}
+
+// authorizeMeeting rejects the request unless policy allows the caller on
+// the meeting loaded by meetingContextMiddleware.
+func (app *application) authorizeMeeting(policy meetingPolicy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !policy(getUserfromCtx(r), getMeetingFromCtx(r)) {
+				app.forbidden(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireSelfOrAdmin only lets a user through to routes about themselves,
+// identified by the user ID in the param URL parameter.
+func (app *application) requireSelfOrAdmin(param string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, err := strconv.ParseInt(chi.URLParam(r, param), 10, 64)
+			if err != nil {
+				app.badRequestResponse(w, r, err)
+				return
+			}
+
+			user := getUserfromCtx(r)
+			if user.ID != id && !user.IsAdmin {
+				app.forbidden(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireMentorOrAdmin only lets the mentor loaded by mentorContextMiddleware,
+// or an admin, through.
+func (app *application) requireMentorOrAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := getUserfromCtx(r)
+		if getMentorFromCtx(r).Userid != user.ID && !user.IsAdmin {
+			app.forbidden(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !getUserfromCtx(r).IsAdmin {
+			app.forbidden(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
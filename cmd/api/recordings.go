@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Althaf66/Appointr/internal/recording"
+	"github.com/Althaf66/Appointr/internal/sfu"
+	"github.com/Althaf66/Appointr/internal/store"
+	chi "github.com/go-chi/chi/v5"
+)
+
+// SetRecordingConsentPayload opts the caller in to or out of recording
+// the meeting.
+type SetRecordingConsentPayload struct {
+	Consent *bool `json:"consent" validate:"required"`
+}
+
+// RecordingConsent is who agreed to a meeting being recorded. The mentor
+// and the mentee are recorded once both have; attendees of group meetings
+// never are.
+type RecordingConsent struct {
+	MentorConsented bool `json:"mentor_consented"`
+	MenteeConsented bool `json:"mentee_consented"`
+	Recording       bool `json:"recording"`
+}
+
+// getRecordingConsentHandler godoc
+//
+//	@Summary		Get a meeting's recording consent
+//	@Description	Get whether the mentor and the mentee agreed to the meeting being recorded
+//	@Tags			meetings
+//	@Accept			json
+//	@Produce		json
+//	@Param			meetingID	path		int64	true	"Meeting ID"
+//	@Success		200			{object}	RecordingConsent
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meetings/{meetingID}/recording [get]
+func (app *application) getRecordingConsentHandler(w http.ResponseWriter, r *http.Request) {
+	consent, err := app.recordingConsent(r.Context(), getMeetingFromCtx(r))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := JsonResponse(w, http.StatusOK, consent); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// setRecordingConsentHandler godoc
+//
+//	@Summary		Consent to recording a meeting
+//	@Description	Opt in to or out of recording the meeting, until its video room opens. The mentor and the mentee are recorded once both opt in; attendees never are.
+//	@Tags			meetings
+//	@Accept			json
+//	@Produce		json
+//	@Param			meetingID	path		int64						true	"Meeting ID"
+//	@Param			payload		body		SetRecordingConsentPayload	true	"Consent"
+//	@Success		200			{object}	RecordingConsent
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meetings/{meetingID}/recording [put]
+func (app *application) setRecordingConsentHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+	meeting := getMeetingFromCtx(r)
+
+	var payload SetRecordingConsentPayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !app.consentable(meeting) {
+		app.conflictResponse(w, r, errors.New("the meeting's room is open, its recording can no longer be changed"))
+		return
+	}
+
+	if err := app.store.Recordings.SetRecordingConsent(r.Context(), meeting.ID, user.ID, *payload.Consent); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.syncMeetingRoom(r.Context(), meeting)
+
+	consent, err := app.recordingConsent(r.Context(), meeting)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := JsonResponse(w, http.StatusOK, consent); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getRecordingsHandler godoc
+//
+//	@Summary		Get a meeting's recordings
+//	@Description	Get what was recorded in the meeting's video room: a file each time the mentor or the mentee was in it
+//	@Tags			meetings
+//	@Accept			json
+//	@Produce		json
+//	@Param			meetingID	path		int64	true	"Meeting ID"
+//	@Success		200			{array}		store.Recording
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meetings/{meetingID}/recordings [get]
+func (app *application) getRecordingsHandler(w http.ResponseWriter, r *http.Request) {
+	recordings, err := app.store.Recordings.GetRecordingsByMeetingID(r.Context(), getMeetingFromCtx(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := JsonResponse(w, http.StatusOK, recordings); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// downloadRecordingHandler godoc
+//
+//	@Summary		Download a recording
+//	@Description	Download a recording: WebM with the participant's VP8 video and Opus audio. Only once they have left the room.
+//	@Tags			meetings
+//	@Produce		octet-stream
+//	@Param			meetingID	path		int64	true	"Meeting ID"
+//	@Param			recordingID	path		int64	true	"Recording ID"
+//	@Success		200			{file}		file
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meetings/{meetingID}/recordings/{recordingID} [get]
+func (app *application) downloadRecordingHandler(w http.ResponseWriter, r *http.Request) {
+	meeting := getMeetingFromCtx(r)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "recordingID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	rec, err := app.store.Recordings.GetRecordingByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if rec.MeetingID != meeting.ID {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return
+	}
+	if rec.EndedAt == nil {
+		app.conflictResponse(w, r, errors.New("the participant is still being recorded"))
+		return
+	}
+
+	file, err := os.Open(rec.Path)
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	defer file.Close()
+
+	name := fmt.Sprintf("meeting-%d-%d-%d%s", meeting.ID, rec.UserID, rec.ID, filepath.Ext(rec.Path))
+	w.Header().Set("Content-Type", rec.MimeType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	http.ServeContent(w, r, name, *rec.EndedAt, file)
+}
+
+func (app *application) recordingConsent(ctx context.Context, meeting *store.Meetings) (*RecordingConsent, error) {
+	users, err := app.store.Recordings.GetRecordingConsents(ctx, meeting.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	consent := &RecordingConsent{}
+	for _, id := range users {
+		switch id {
+		case meeting.Mentorid:
+			consent.MentorConsented = true
+		case meeting.Userid:
+			consent.MenteeConsented = true
+		}
+	}
+	consent.Recording = consent.MentorConsented && consent.MenteeConsented
+	return consent, nil
+}
+
+// consentable reports whether recording meeting can still be agreed to or
+// withdrawn: only until its room opens, so that a session is recorded all
+// through or not at all.
+func (app *application) consentable(meeting *store.Meetings) bool {
+	return time.Now().Before(meeting.StartAt.Add(-app.config.video.joinEarly))
+}
+
+// recordParticipant is the SFU's recorder: in rooms being recorded it
+// saves the mentor's and the mentee's tracks to a WebM file of theirs under
+// the meeting's directory and keeps a store.Recording of it.
+func (app *application) recordParticipant(roomID string, userID int64) (sfu.Recording, error) {
+	// recordings outlive the request that joined the room
+	ctx := context.Background()
+
+	room, err := app.rooms.GetRoom(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	if !room.Recording {
+		return nil, nil
+	}
+
+	// only the two who agreed to it are recorded; attendees never are
+	meeting, err := app.store.Meetings.GetMeetingByID(ctx, room.MeetingID)
+	if err != nil {
+		return nil, err
+	}
+	if !isMeetingParticipant(meeting, userID) {
+		return nil, nil
+	}
+
+	path := filepath.Join(app.config.recording.dir, strconv.FormatInt(room.MeetingID, 10),
+		fmt.Sprintf("%s-%d-%d%s", room.ID, userID, time.Now().UnixNano(), recording.Extension))
+	webm, err := recording.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &store.Recording{
+		MeetingID: room.MeetingID,
+		RoomID:    room.ID,
+		UserID:    userID,
+		MimeType:  recording.ContentType,
+		Path:      path,
+	}
+	if err := app.store.Recordings.CreateRecording(ctx, rec); err != nil {
+		webm.Close()
+		os.Remove(path)
+		return nil, err
+	}
+
+	return &participantRecording{WebM: webm, app: app, recording: rec}, nil
+}
+
+// participantRecording finishes its store.Recording when the participant
+// leaves.
+type participantRecording struct {
+	*recording.WebM
+	app       *application
+	recording *store.Recording
+	once      sync.Once
+}
+
+func (p *participantRecording) Close() error {
+	err := p.WebM.Close()
+	p.once.Do(func() {
+		var size int64
+		if info, err := os.Stat(p.recording.Path); err == nil {
+			size = info.Size()
+		}
+		if err := p.app.store.Recordings.FinishRecording(context.Background(), p.recording.ID, size); err != nil {
+			p.app.logger.Errorw("error finishing recording", "recording", p.recording.ID, "error", err)
+		}
+	})
+	return err
+}
+
+// deleteExpiredRecordings deletes the recordings older than the retention
+// period, and their files, until ctx is done.
+func (app *application) deleteExpiredRecordings(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := app.deleteRecordingsBefore(ctx, time.Now().Add(-app.config.recording.retention))
+			if err != nil {
+				app.logger.Errorw("error deleting expired recordings", "error", err)
+				continue
+			}
+			if deleted > 0 {
+				app.logger.Infow("deleted expired recordings", "count", deleted)
+			}
+		}
+	}
+}
+
+// deleteRecordingsBefore deletes the recordings started before and returns
+// how many it did. A recording is only forgotten once its file is gone, so
+// a file that couldn't be removed is tried again next time rather than
+// left on disk for good.
+func (app *application) deleteRecordingsBefore(ctx context.Context, before time.Time) (int, error) {
+	expired, err := app.store.Recordings.GetRecordingsBefore(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int
+	for _, rec := range expired {
+		if err := os.Remove(rec.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			app.logger.Errorw("error deleting recording file", "recording", rec.ID, "path", rec.Path, "error", err)
+			continue
+		}
+		if err := app.store.Recordings.DeleteRecording(ctx, rec.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
+			app.logger.Errorw("error deleting recording", "recording", rec.ID, "error", err)
+			continue
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Althaf66/Appointr/internal/store"
+)
+
+func TestDeleteRecordingsBefore(t *testing.T) {
+	app, ts := newTestApplication(t, newStripeStub(t).URL)
+	dir := t.TempDir()
+	expiredAt := time.Now().Add(-48 * time.Hour)
+
+	removable := filepath.Join(dir, "removable.webm")
+	if err := os.WriteFile(removable, []byte("webm"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	// a directory with something in it can't be removed like a file
+	stuck := filepath.Join(dir, "stuck.webm")
+	if err := os.MkdirAll(filepath.Join(stuck, "inside"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	recent := filepath.Join(dir, "recent.webm")
+	if err := os.WriteFile(recent, []byte("webm"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	ts.recordings = []*store.Recording{
+		{ID: 1, Path: removable, StartedAt: expiredAt},
+		{ID: 2, Path: filepath.Join(dir, "gone.webm"), StartedAt: expiredAt},
+		{ID: 3, Path: stuck, StartedAt: expiredAt},
+		{ID: 4, Path: recent, StartedAt: time.Now()},
+	}
+
+	deleted, err := app.deleteRecordingsBefore(context.Background(), time.Now().Add(-24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Errorf("deleted %d recordings, want 2", deleted)
+	}
+
+	var left []int64
+	for _, rec := range ts.recordings {
+		left = append(left, rec.ID)
+	}
+	if len(left) != 2 || left[0] != 3 || left[1] != 4 {
+		t.Errorf("recordings %v are left, want the one whose file is stuck and the recent one", left)
+	}
+
+	if _, err := os.Stat(removable); !os.IsNotExist(err) {
+		t.Errorf("expired file is still there: %v", err)
+	}
+	if _, err := os.Stat(recent); err != nil {
+		t.Errorf("recent file was removed: %v", err)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Althaf66/Appointr/internal/payments"
+	"github.com/Althaf66/Appointr/internal/store"
+)
+
+var ErrNotRefundable = errors.New("meeting can't be refunded")
+
+// RefundMeetingPayload asks for part of the payment back. Without an amount
+// everything the caller is allowed to refund is refunded.
+type RefundMeetingPayload struct {
+	// Amount is in the currency's minor unit
+	Amount *int64 `json:"amount" validate:"omitempty,min=1"`
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+// refundMeetingHandler godoc
+//
+//	@Summary		Refund a meeting
+//	@Description	Refund a paid meeting in full or in part. Mentors and admins may refund what is left of the payment; mentees get a full refund when the mentor cancelled or they cancelled outside the cancellation window, and a partial one when they cancelled inside it or missed the meeting.
+//	@Tags			meetings
+//	@Accept			json
+//	@Produce		json
+//	@Param			meetingID	path		int64					true	"Meeting ID"
+//	@Param			payload		body		RefundMeetingPayload	true	"Refund"
+//	@Success		201			{object}	store.Refund
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meetings/{meetingID}/refund [post]
+func (app *application) refundMeetingHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+	meeting := getMeetingFromCtx(r)
+
+	var payload RefundMeetingPayload
+	err := ReadJSON(w, r, &payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	payment, err := app.store.Payments.GetPaidPaymentByMeetingID(r.Context(), meeting.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.conflictResponse(w, r, fmt.Errorf("%w: it has not been paid", ErrNotRefundable))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if payment.Status == store.PaymentDisputed {
+		app.conflictResponse(w, r, fmt.Errorf("%w: the payment is disputed", ErrNotRefundable))
+		return
+	}
+
+	allowed, err := app.refundAllowance(r.Context(), user, meeting, payment)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNotRefundable):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if allowed <= 0 {
+		app.conflictResponse(w, r, fmt.Errorf("%w: nothing left to refund", ErrNotRefundable))
+		return
+	}
+
+	amount := allowed
+	if payload.Amount != nil {
+		if *payload.Amount > allowed {
+			app.badRequestResponse(w, r, fmt.Errorf("at most %d can be refunded", allowed))
+			return
+		}
+		amount = *payload.Amount
+	}
+
+	re, err := app.payments.Refund(r.Context(), payments.RefundRequest{
+		PaymentIntentID: payment.PaymentIntentID,
+		Amount:          amount,
+		// a double submit against the same ledger state gets the same refund back
+		IdempotencyKey: fmt.Sprintf("refund-%d-%d-%d", payment.ID, payment.RefundedAmount, amount),
+		Metadata: map[string]string{
+			"meetingid": strconv.FormatInt(meeting.ID, 10),
+			"paymentid": strconv.FormatInt(payment.ID, 10),
+		},
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, payments.ErrRejected):
+			app.conflictResponse(w, r, fmt.Errorf("%w: %v", ErrNotRefundable, err))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	rec := &store.Refund{
+		PaymentID:        payment.ID,
+		ProviderRefundID: re.ID,
+		Amount:           re.Amount,
+		Currency:         re.Currency,
+		Reason:           payload.Reason,
+		RequestedBy:      &user.ID,
+	}
+	err = app.store.Payments.RecordRefund(r.Context(), rec)
+	if err != nil {
+		// the refund went through; the charge.refunded webhook fixes the ledger
+		app.logger.Errorw("error recording refund", "meeting", meeting.ID, "refund", re.ID, "error", err)
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if refreshed, err := app.store.Meetings.GetMeetingByID(r.Context(), meeting.ID); err == nil {
+		// a full refund ends the meeting, and its room with it
+		app.syncMeetingRoom(r.Context(), refreshed)
+	}
+
+	err = JsonResponse(w, http.StatusCreated, rec)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// refundAllowance is how much of payment user may refund. Mentors and admins
+// may refund everything left. Mentees only get money back for cancelled and
+// no-show meetings: all of it when the mentor cancelled or they cancelled
+// outside the cancellation window, the late-cancellation share when they
+// cancelled inside it or missed the meeting.
+func (app *application) refundAllowance(ctx context.Context, user *store.User, meeting *store.Meetings, payment *store.Payment) (int64, error) {
+	remaining := payment.Refundable()
+
+	if user.IsAdmin || meeting.Mentorid == user.ID {
+		return remaining, nil
+	}
+
+	switch meeting.Status {
+	case store.MeetingNoShow:
+		// missing the meeting counts as cancelling at the last minute
+		return app.lateCancelShare(payment), nil
+	case store.MeetingCancelled:
+	default:
+		return 0, fmt.Errorf("%w: only cancelled and no-show meetings are refunded to mentees", ErrNotRefundable)
+	}
+
+	cancelledAt, cancelledBy, err := app.meetingCancellation(ctx, meeting.ID)
+	if err != nil {
+		return 0, err
+	}
+	if cancelledBy != meeting.Userid {
+		return remaining, nil
+	}
+
+	hours := defaultCancellationWindowHours
+	if mentor, err := app.store.Mentor.GetMentorByUserID(ctx, meeting.Mentorid); err == nil {
+		hours = mentor.CancellationWindowHours
+	}
+	if meeting.StartAt.Sub(cancelledAt) >= time.Duration(hours)*time.Hour {
+		return remaining, nil
+	}
+
+	return app.lateCancelShare(payment), nil
+}
+
+// lateCancelShare is what is left to refund of the late-cancellation share
+// of payment.
+func (app *application) lateCancelShare(payment *store.Payment) int64 {
+	share := int64(math.Round(float64(payment.Amount) * app.config.payments.lateCancelRefundRate))
+	return min(share-payment.RefundedAmount, payment.Refundable())
+}
+
+// meetingCancellation finds when and by whom the meeting was cancelled. A
+// system cancellation is reported as user 0.
+func (app *application) meetingCancellation(ctx context.Context, meetingID int64) (time.Time, int64, error) {
+	history, err := app.store.Meetings.GetMeetingStatusHistory(ctx, meetingID)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	for i := len(history) - 1; i >= 0; i-- {
+		change := history[i]
+		if change.ToStatus != store.MeetingCancelled {
+			continue
+		}
+		var by int64
+		if change.ChangedBy != nil {
+			by = *change.ChangedBy
+		}
+		return change.CreatedAt, by, nil
+	}
+
+	return time.Time{}, 0, fmt.Errorf("%w: no cancellation on record", ErrNotRefundable)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Althaf66/Appointr/internal/store"
+)
+
+func TestRefundMeetingHandler(t *testing.T) {
+	const (
+		menteeID  = 1
+		mentorID  = 2
+		meetingID = 10
+		paymentID = 100
+		intentID  = "pi_test"
+		paid      = 5000
+	)
+	mentee := &store.User{ID: menteeID}
+	mentor := &store.User{ID: mentorID}
+	startAt := time.Now().Add(12 * time.Hour).Truncate(time.Second)
+
+	cancelled := func(by int64, before time.Duration) *store.MeetingStatusChange {
+		return &store.MeetingStatusChange{
+			MeetingID: meetingID,
+			ToStatus:  store.MeetingCancelled,
+			ChangedBy: &by,
+			CreatedAt: startAt.Add(-before),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		user    *store.User
+		status  store.MeetingStatus
+		history []*store.MeetingStatusChange
+		// charged is what the stub lets be refunded, when not all of it
+		charged     int64
+		body        string
+		wantCode    int
+		wantRefund  int64
+		wantPayment string
+		wantMeeting store.MeetingStatus
+	}{
+		{
+			name:        "mentor refunds in full",
+			user:        mentor,
+			status:      store.MeetingPaid,
+			body:        `{"reason": "can't make it"}`,
+			wantCode:    http.StatusCreated,
+			wantRefund:  paid,
+			wantPayment: store.PaymentRefunded,
+			wantMeeting: store.MeetingRefunded,
+		},
+		{
+			name:        "mentor refunds in part",
+			user:        mentor,
+			status:      store.MeetingCompleted,
+			body:        `{"amount": 2000}`,
+			wantCode:    http.StatusCreated,
+			wantRefund:  2000,
+			wantPayment: store.PaymentPartiallyRefunded,
+			wantMeeting: store.MeetingCompleted,
+		},
+		{
+			name:        "more than was paid",
+			user:        mentor,
+			status:      store.MeetingPaid,
+			body:        `{"amount": 6000}`,
+			wantCode:    http.StatusBadRequest,
+			wantPayment: store.PaymentSucceeded,
+			wantMeeting: store.MeetingPaid,
+		},
+		{
+			name:        "mentee cancelled in time",
+			user:        mentee,
+			status:      store.MeetingCancelled,
+			history:     []*store.MeetingStatusChange{cancelled(menteeID, 48*time.Hour)},
+			body:        `{}`,
+			wantCode:    http.StatusCreated,
+			wantRefund:  paid,
+			wantPayment: store.PaymentRefunded,
+			wantMeeting: store.MeetingRefunded,
+		},
+		{
+			name:        "mentee cancelled late",
+			user:        mentee,
+			status:      store.MeetingCancelled,
+			history:     []*store.MeetingStatusChange{cancelled(menteeID, time.Hour)},
+			body:        `{}`,
+			wantCode:    http.StatusCreated,
+			wantRefund:  paid / 2,
+			wantPayment: store.PaymentPartiallyRefunded,
+			wantMeeting: store.MeetingCancelled,
+		},
+		{
+			name:        "mentee cancelled late asks for more than their share",
+			user:        mentee,
+			status:      store.MeetingCancelled,
+			history:     []*store.MeetingStatusChange{cancelled(menteeID, time.Hour)},
+			body:        `{"amount": 3000}`,
+			wantCode:    http.StatusBadRequest,
+			wantPayment: store.PaymentSucceeded,
+			wantMeeting: store.MeetingCancelled,
+		},
+		{
+			name:        "mentor cancelled late",
+			user:        mentee,
+			status:      store.MeetingCancelled,
+			history:     []*store.MeetingStatusChange{cancelled(mentorID, time.Hour)},
+			body:        `{}`,
+			wantCode:    http.StatusCreated,
+			wantRefund:  paid,
+			wantPayment: store.PaymentRefunded,
+			wantMeeting: store.MeetingRefunded,
+		},
+		{
+			name:        "mentee missed the meeting",
+			user:        mentee,
+			status:      store.MeetingNoShow,
+			body:        `{}`,
+			wantCode:    http.StatusCreated,
+			wantRefund:  paid / 2,
+			wantPayment: store.PaymentPartiallyRefunded,
+			wantMeeting: store.MeetingNoShow,
+		},
+		{
+			name:        "mentee of a meeting still on",
+			user:        mentee,
+			status:      store.MeetingPaid,
+			body:        `{}`,
+			wantCode:    http.StatusConflict,
+			wantPayment: store.PaymentSucceeded,
+			wantMeeting: store.MeetingPaid,
+		},
+		{
+			name:        "refunded outside the API already",
+			user:        mentor,
+			status:      store.MeetingPaid,
+			charged:     1000,
+			body:        `{"amount": 2000}`,
+			wantCode:    http.StatusConflict,
+			wantPayment: store.PaymentSucceeded,
+			wantMeeting: store.MeetingPaid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stripe := newStripeStub(t)
+			charged := int64(paid)
+			if tt.charged != 0 {
+				charged = tt.charged
+			}
+			stripe.charge(intentID, charged)
+
+			app, ts := newTestApplication(t, stripe.URL)
+			meeting := &store.Meetings{
+				ID:              meetingID,
+				Userid:          menteeID,
+				Mentorid:        mentorID,
+				StartAt:         startAt,
+				DurationMinutes: 60,
+				Status:          tt.status,
+				MaxParticipants: 2,
+			}
+			ts.addMeeting(meeting, tt.history...)
+			paidAt := startAt.Add(-72 * time.Hour)
+			ts.addPayment(&store.Payment{
+				ID:              paymentID,
+				MeetingID:       meetingID,
+				PayerID:         menteeID,
+				PayeeID:         mentorID,
+				PaymentIntentID: intentID,
+				Amount:          paid,
+				Currency:        "usd",
+				Status:          store.PaymentSucceeded,
+				PaidAt:          &paidAt,
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/meetings/10/refund", strings.NewReader(tt.body))
+			req = withMeeting(withUser(req, tt.user), meeting)
+			rr := httptest.NewRecorder()
+			app.refundMeetingHandler(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("got status %d, want %d: %s", rr.Code, tt.wantCode, rr.Body)
+			}
+
+			requests := stripe.refundRequests()
+			if tt.wantRefund == 0 {
+				if len(requests) != 0 {
+					t.Fatalf("got %d refunds at Stripe, want none", len(requests))
+				}
+			} else {
+				if len(requests) != 1 {
+					t.Fatalf("got %d refunds at Stripe, want 1", len(requests))
+				}
+				if got := requests[0].Get("payment_intent"); got != intentID {
+					t.Errorf("refunded payment intent %q, want %q", got, intentID)
+				}
+				if got := requests[0].Get("metadata[paymentid]"); got != "100" {
+					t.Errorf("refund metadata paymentid %q, want 100", got)
+				}
+				if stripe.keys[0] == "" {
+					t.Error("refund was sent without an idempotency key")
+				}
+
+				var resp struct {
+					Data store.Refund `json:"data"`
+				}
+				if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+					t.Fatal(err)
+				}
+				if resp.Data.Amount != tt.wantRefund {
+					t.Errorf("refunded %d, want %d", resp.Data.Amount, tt.wantRefund)
+				}
+				if resp.Data.ProviderRefundID == "" {
+					t.Error("refund has no provider id")
+				}
+			}
+
+			payment := ts.payment(paymentID)
+			if payment.RefundedAmount != tt.wantRefund {
+				t.Errorf("ledger refunded %d, want %d", payment.RefundedAmount, tt.wantRefund)
+			}
+			if payment.Status != tt.wantPayment {
+				t.Errorf("payment is %s, want %s", payment.Status, tt.wantPayment)
+			}
+			if got := ts.meeting(meetingID).Status; got != tt.wantMeeting {
+				t.Errorf("meeting is %s, want %s", got, tt.wantMeeting)
+			}
+		})
+	}
+}
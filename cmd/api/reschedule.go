@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Althaf66/Appointr/internal/availability"
+	"github.com/Althaf66/Appointr/internal/mailer"
+	"github.com/Althaf66/Appointr/internal/store"
+	chi "github.com/go-chi/chi/v5"
+)
+
+// RescheduleMeetingPayload takes either an absolute start_at or a
+// date/start_time/start_period triple read in the caller's timezone.
+type RescheduleMeetingPayload struct {
+	StartAt         *time.Time `json:"start_at"`
+	DurationMinutes int        `json:"duration_minutes" validate:"omitempty,min=15,max=480"`
+	Date            string     `json:"date"`
+	StartTime       string     `json:"start_time"`
+	StartPeriod     string     `json:"start_period"`
+	Reason          string     `json:"reason" validate:"max=500"`
+}
+
+// proposeRescheduleHandler godoc
+//
+//	@Summary		Propose a new time
+//	@Description	Ask the other participant to move the meeting. The meeting keeps its time until they accept.
+//	@Tags			meetings
+//	@Accept			json
+//	@Produce		json
+//	@Param			meetingID	path		int64						true	"Meeting ID"
+//	@Param			payload		body		RescheduleMeetingPayload	true	"Proposed time"
+//	@Success		201			{object}	store.RescheduleRequest
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meetings/{meetingID}/reschedule [post]
+func (app *application) proposeRescheduleHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+	meeting := getMeetingFromCtx(r)
+
+	var payload RescheduleMeetingPayload
+	err := ReadJSON(w, r, &payload)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	loc, err := viewerLocation(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !reschedulable(meeting.Status) {
+		app.conflictResponse(w, r, errors.New("meeting can no longer be rescheduled"))
+		return
+	}
+
+	if err := app.checkCancellationWindow(r.Context(), meeting, user.ID); err != nil {
+		app.conflictResponse(w, r, err)
+		return
+	}
+
+	start, err := meetingStart(payload.StartAt, payload.Date, payload.StartTime, payload.StartPeriod, loc)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	duration := payload.DurationMinutes
+	if duration == 0 {
+		duration = meeting.DurationMinutes
+	}
+
+	req := &store.RescheduleRequest{
+		MeetingID:       meeting.ID,
+		ProposedBy:      user.ID,
+		StartAt:         start.UTC(),
+		DurationMinutes: duration,
+		Reason:          payload.Reason,
+	}
+
+	if err := app.checkRescheduleBookable(r.Context(), meeting, req); err != nil {
+		app.rescheduleError(w, r, err)
+		return
+	}
+
+	err = app.store.Reschedules.CreateRescheduleRequest(r.Context(), req)
+	if err != nil {
+		app.rescheduleError(w, r, err)
+		return
+	}
+
+	app.notifyMeetingParticipants(r.Context(), meeting, meetingChange{
+		template: mailer.MeetingRescheduleProposedTemplate,
+		actorID:  user.ID,
+		reason:   req.Reason,
+		to:       &req.StartAt,
+	})
+
+	req.StartAt = req.StartAt.In(loc)
+	err = JsonResponse(w, http.StatusCreated, req)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getReschedulesHandler godoc
+//
+//	@Summary		Get reschedule requests
+//	@Description	Get every reschedule request of a meeting, newest first
+//	@Tags			meetings
+//	@Accept			json
+//	@Produce		json
+//	@Param			meetingID	path		int64	true	"Meeting ID"
+//	@Success		200			{object}	[]store.RescheduleRequest
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meetings/{meetingID}/reschedule [get]
+func (app *application) getReschedulesHandler(w http.ResponseWriter, r *http.Request) {
+	meeting := getMeetingFromCtx(r)
+
+	loc, err := viewerLocation(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	requests, err := app.store.Reschedules.GetRescheduleRequestsByMeetingID(r.Context(), meeting.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	for _, req := range requests {
+		req.StartAt = req.StartAt.In(loc)
+	}
+
+	err = JsonResponse(w, http.StatusOK, requests)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// acceptRescheduleHandler godoc
+//
+//	@Summary		Accept a new time
+//	@Description	Accept the other participant's proposal and move the meeting
+//	@Tags			meetings
+//	@Accept			json
+//	@Produce		json
+//	@Param			meetingID		path		int64	true	"Meeting ID"
+//	@Param			rescheduleID	path		int64	true	"Reschedule request ID"
+//	@Success		200				{object}	store.Meetings
+//	@Failure		400				{object}	error
+//	@Failure		401				{object}	error
+//	@Failure		403				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		409				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meetings/{meetingID}/reschedule/{rescheduleID}/accept [post]
+func (app *application) acceptRescheduleHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+	meeting := getMeetingFromCtx(r)
+
+	loc, err := viewerLocation(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	req, ok := app.rescheduleForResponse(w, r, meeting, user)
+	if !ok {
+		return
+	}
+
+	if !reschedulable(meeting.Status) {
+		app.conflictResponse(w, r, errors.New("meeting can no longer be rescheduled"))
+		return
+	}
+
+	err = app.store.Reschedules.AcceptRescheduleRequest(r.Context(), req, user.ID, func(ctx context.Context) error {
+		return app.checkRescheduleBookable(ctx, meeting, req)
+	})
+	if err != nil {
+		app.rescheduleError(w, r, err)
+		return
+	}
+
+	previous := meeting.StartAt
+	meeting.StartAt = req.StartAt
+	meeting.DurationMinutes = req.DurationMinutes
+	app.syncMeetingRoom(r.Context(), meeting)
+
+	app.notifyMeetingParticipants(r.Context(), meeting, meetingChange{
+		template: mailer.MeetingRescheduledTemplate,
+		actorID:  user.ID,
+		from:     &previous,
+		to:       &req.StartAt,
+	})
+
+	meeting.Localize(loc)
+	err = JsonResponse(w, http.StatusOK, meeting)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// declineRescheduleHandler godoc
+//
+//	@Summary		Decline a new time
+//	@Description	Decline the other participant's proposal; the meeting keeps its time
+//	@Tags			meetings
+//	@Accept			json
+//	@Produce		json
+//	@Param			meetingID		path		int64	true	"Meeting ID"
+//	@Param			rescheduleID	path		int64	true	"Reschedule request ID"
+//	@Success		200				{object}	store.RescheduleRequest
+//	@Failure		400				{object}	error
+//	@Failure		401				{object}	error
+//	@Failure		403				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		409				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meetings/{meetingID}/reschedule/{rescheduleID}/decline [post]
+func (app *application) declineRescheduleHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+	meeting := getMeetingFromCtx(r)
+
+	loc, err := viewerLocation(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	req, ok := app.rescheduleForResponse(w, r, meeting, user)
+	if !ok {
+		return
+	}
+
+	err = app.store.Reschedules.DeclineRescheduleRequest(r.Context(), req, user.ID)
+	if err != nil {
+		app.rescheduleError(w, r, err)
+		return
+	}
+
+	app.notifyMeetingParticipants(r.Context(), meeting, meetingChange{
+		template: mailer.MeetingRescheduleDeclinedTemplate,
+		actorID:  user.ID,
+		to:       &req.StartAt,
+	})
+
+	req.StartAt = req.StartAt.In(loc)
+	err = JsonResponse(w, http.StatusOK, req)
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// rescheduleForResponse loads the request in the URL and makes sure the
+// caller is the participant who has to answer it. It writes the error
+// response itself and reports false on failure.
+func (app *application) rescheduleForResponse(w http.ResponseWriter, r *http.Request, meeting *store.Meetings, user *store.User) (*store.RescheduleRequest, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "rescheduleID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	req, err := app.store.Reschedules.GetRescheduleRequestByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+
+	if req.MeetingID != meeting.ID {
+		app.notFoundResponse(w, r, store.ErrNotFound)
+		return nil, false
+	}
+
+	// the proposer can't answer their own request
+	if req.ProposedBy == user.ID {
+		app.forbidden(w, r)
+		return nil, false
+	}
+
+	return req, true
+}
+
+// checkRescheduleBookable holds a mentee's proposal to the mentor's
+// availability. Mentors may move their own sessions outside it.
+func (app *application) checkRescheduleBookable(ctx context.Context, meeting *store.Meetings, req *store.RescheduleRequest) error {
+	if req.ProposedBy == meeting.Mentorid {
+		if req.StartAt.Before(time.Now()) {
+			return availability.ErrSlotUnavailable
+		}
+		return nil
+	}
+
+	return app.availability.CheckBookable(ctx, meeting.Mentorid, req.StartAt, req.EndAt().Sub(req.StartAt))
+}
+
+func (app *application) rescheduleError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, availability.ErrSlotUnavailable),
+		errors.Is(err, store.ErrMeetingConflict),
+		errors.Is(err, store.ErrReschedulePending),
+		errors.Is(err, store.ErrRescheduleNotPending):
+		app.conflictResponse(w, r, err)
+	case errors.Is(err, store.ErrNotFound):
+		app.notFoundResponse(w, r, err)
+	default:
+		app.internalServerError(w, r, err)
+	}
+}
+
+// reschedulable reports whether a meeting in status can still be moved.
+func reschedulable(status store.MeetingStatus) bool {
+	switch status {
+	case store.MeetingRequested, store.MeetingConfirmed, store.MeetingPaid:
+		return true
+	}
+	return false
+}
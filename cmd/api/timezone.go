@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Althaf66/Appointr/internal/availability"
+	"github.com/Althaf66/Appointr/internal/store"
+)
+
+// viewerLocation picks the timezone times are rendered in: the ?tz= query
+// override first, then the caller's profile, then UTC.
+func viewerLocation(r *http.Request) (*time.Location, error) {
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		return time.LoadLocation(tz)
+	}
+	if user := getUserfromCtx(r); user != nil {
+		return availability.Location(user.Timezone)
+	}
+	return time.UTC, nil
+}
+
+func localizeMeetings(meetings []*store.Meetings, loc *time.Location) {
+	for _, m := range meetings {
+		m.Localize(loc)
+	}
+}
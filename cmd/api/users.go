@@ -81,6 +81,66 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+type UpdateTimezonePayload struct {
+	Timezone string `json:"timezone" validate:"required,timezone"`
+}
+
+// updateUserTimezoneHandler godoc
+//
+//	@Summary		Updates a user's timezone
+//	@Description	Sets the IANA timezone used to render times for the user
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"User ID"
+//	@Param			payload	body		UpdateTimezonePayload	true	"Timezone"
+//	@Success		200		{object}	store.User
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{id}/timezone [patch]
+func (app *application) updateUserTimezoneHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if userID != user.ID {
+		app.forbidden(w, r)
+		return
+	}
+
+	var payload UpdateTimezonePayload
+	if err := ReadJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.store.Users.UpdateTimezone(r.Context(), user.ID, payload.Timezone)
+	if err != nil {
+		switch err {
+		case store.ErrUserNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	user.Timezone = payload.Timezone
+	if err := JsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
 func (app *application) userContextMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		id, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
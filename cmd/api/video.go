@@ -1,409 +1,434 @@
 package main
 
 import (
-	"encoding/json"
-	"log"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"net/http"
-	"sync"
+	"time"
 
+	"github.com/Althaf66/Appointr/internal/signaling"
+	"github.com/Althaf66/Appointr/internal/store"
+	"github.com/Althaf66/Appointr/internal/video"
 	"github.com/go-chi/chi/v5"
-	"github.com/pion/webrtc/v3"
 )
 
-type PeerConnection struct {
-	ID          string
-	PC          *webrtc.PeerConnection
-	DataChannel *webrtc.DataChannel
-	RoomID      string
-	IsInitiator bool
-}
-
-// Room represents a meeting room with participants
-type Room struct {
-	ID          string
-	Connections map[string]*PeerConnection
-	mutex       sync.Mutex
-}
-
-// SignalMessage represents a WebRTC signaling message
-type SignalMessage struct {
-	Type      string                   `json:"type"`
-	SDP       string                   `json:"sdp,omitempty"`
-	Candidate *webrtc.ICECandidateInit `json:"candidate,omitempty"`
-	UserID    string                   `json:"userId"`
-	RoomID    string                   `json:"roomId"`
-	To        string                   `json:"to,omitempty"`
-	From      string                   `json:"from,omitempty"` // Add this field
-}
-
-// Global state
 var (
-	rooms    = make(map[string]*Room)
-	roomLock sync.Mutex
+	errNotRoomParticipant = errors.New("only the meeting's mentor, mentee and attendees can join its room")
+	errRoomNotStarted     = errors.New("the meeting's room isn't open yet")
 )
 
-func createRoomHandler(w http.ResponseWriter, r *http.Request) {
-	var roomRequest struct {
-		RoomID string `json:"roomId"`
-		UserID string `json:"userId"`
+// roomTicketTTL is how long a ticket to a room's signaling socket can be
+// used for, which only has to cover opening the socket.
+const roomTicketTTL = 30 * time.Second
+
+// getMeetingRoomHandler godoc
+//
+//	@Summary		Get a meeting's video room
+//	@Description	Get the video room of a paid meeting, opening it if it isn't yet. Its link is also set on the meeting. Its participants and attendees only.
+//	@Tags			meetings
+//	@Accept			json
+//	@Produce		json
+//	@Param			meetingID	path		int64	true	"Meeting ID"
+//	@Success		200			{object}	video.Room
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/meetings/{meetingID}/room [get]
+func (app *application) getMeetingRoomHandler(w http.ResponseWriter, r *http.Request) {
+	meeting := getMeetingFromCtx(r)
+
+	ok, err := app.attendsMeeting(r.Context(), meeting, getUserfromCtx(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
 	}
-
-	if err := json.NewDecoder(r.Body).Decode(&roomRequest); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !ok {
+		app.forbidden(w, r)
 		return
 	}
 
-	roomLock.Lock()
-	defer roomLock.Unlock()
-
-	if _, exists := rooms[roomRequest.RoomID]; exists {
-		http.Error(w, "Room already exists", http.StatusBadRequest)
+	if !hasRoom(meeting.Status) {
+		app.conflictResponse(w, r, fmt.Errorf("meeting is %s, only paid meetings have a room", meeting.Status))
 		return
 	}
 
-	newRoom := &Room{
-		ID:          roomRequest.RoomID,
-		Connections: make(map[string]*PeerConnection),
+	app.syncMeetingRoom(r.Context(), meeting)
+
+	room, err := app.rooms.GetRoomByMeetingID(r.Context(), meeting.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, video.ErrRoomNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
 	}
-	rooms[roomRequest.RoomID] = newRoom
 
-	// Create peer connection for the room creator
-	config := webrtc.Configuration{
-		ICEServers: []webrtc.ICEServer{
-			{
-				URLs: []string{"stun:stun.l.google.com:19302"},
-			},
-		},
+	if err := JsonResponse(w, http.StatusOK, room); err != nil {
+		app.internalServerError(w, r, err)
 	}
+}
 
-	peerConnection, err := webrtc.NewPeerConnection(config)
+// roomStatusHandler godoc
+//
+//	@Summary		Get a video room
+//	@Description	Get a video room with the participants currently in it. Its meeting's participants, attendees or admins only.
+//	@Tags			video
+//	@Accept			json
+//	@Produce		json
+//	@Param			roomID	path		string	true	"Room ID"
+//	@Success		200		{object}	video.Room
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/video/room-status/{roomID} [get]
+func (app *application) roomStatusHandler(w http.ResponseWriter, r *http.Request) {
+	room, err := app.rooms.GetRoom(r.Context(), chi.URLParam(r, "roomID"))
 	if err != nil {
-		http.Error(w, "Failed to create peer connection", http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, video.ErrRoomNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
-	// Create connection object
-	connection := &PeerConnection{
-		ID:          roomRequest.UserID,
-		PC:          peerConnection,
-		RoomID:      roomRequest.RoomID,
-		IsInitiator: true,
+	meeting, err := app.store.Meetings.GetMeetingByID(r.Context(), room.MeetingID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
 	}
 
-	// Configure ICE handlers
-	peerConnection.OnICECandidate(func(candidate *webrtc.ICECandidate) {
-		if candidate == nil {
+	user := getUserfromCtx(r)
+	if !user.IsAdmin {
+		ok, err := app.attendsMeeting(r.Context(), meeting, user.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
 			return
 		}
-
-		candidateJSON, err := json.Marshal(candidate.ToJSON())
-		if err != nil {
-			log.Println("Failed to marshal ICE candidate:", err)
+		if !ok {
+			app.forbidden(w, r)
 			return
 		}
+	}
 
-		log.Println("ICE candidate found:", string(candidateJSON))
-		// We would usually send this to the client
-	})
-
-	// Add to room
-	newRoom.Connections[roomRequest.UserID] = connection
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"roomId": roomRequest.RoomID,
-		"userId": roomRequest.UserID,
-		"status": "created",
-	})
+	if err := JsonResponse(w, http.StatusOK, room); err != nil {
+		app.internalServerError(w, r, err)
+	}
 }
 
-func joinRoomHandler(w http.ResponseWriter, r *http.Request) {
-	roomID := chi.URLParam(r, "roomID")
-
-	var joinRequest struct {
-		UserID string `json:"userId"`
+// createRoomTicketHandler godoc
+//
+//	@Summary		Get a video room ticket
+//	@Description	Get a one-time ticket to open the room's signaling socket with, as /ws/video/{roomID}?ticket=. It expires after a short while. Its meeting's participants and attendees only.
+//	@Tags			video
+//	@Accept			json
+//	@Produce		json
+//	@Param			roomID	path		string	true	"Room ID"
+//	@Success		201		{object}	video.Ticket
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/video/ticket/{roomID} [post]
+func (app *application) createRoomTicketHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+
+	room, err := app.rooms.GetRoom(r.Context(), chi.URLParam(r, "roomID"))
+	if err != nil {
+		switch {
+		case errors.Is(err, video.ErrRoomNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&joinRequest); err != nil {
-		http.Error(w, "Invalid request format: "+err.Error(), http.StatusBadRequest)
+	meeting, err := app.store.Meetings.GetMeetingByID(r.Context(), room.MeetingID)
+	if err != nil {
+		app.internalServerError(w, r, err)
 		return
 	}
 
-	roomLock.Lock()
-	defer roomLock.Unlock()
-
-	room, exists := rooms[roomID]
-	if !exists {
-		http.Error(w, "Room not found: "+roomID, http.StatusNotFound)
+	ok, err := app.attendsMeeting(r.Context(), meeting, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
 		return
 	}
-
-	// Check if user is already in the room
-	if _, userExists := room.Connections[joinRequest.UserID]; userExists {
-		// User is already in the room, return success with the other peer ID
-		var otherPeerID string
-		for id := range room.Connections {
-			if id != joinRequest.UserID {
-				otherPeerID = id
-				break
-			}
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{
-			"roomId":      roomID,
-			"userId":      joinRequest.UserID,
-			"otherPeerId": otherPeerID,
-			"status":      "rejoined",
-		})
+	if !ok {
+		app.forbidden(w, r)
 		return
 	}
 
-	if len(room.Connections) >= 2 {
-		http.Error(w, "Room is full (maximum 2 participants allowed)", http.StatusForbidden)
+	id, err := newRoomID()
+	if err != nil {
+		app.internalServerError(w, r, err)
 		return
 	}
-
-	// Create peer connection for joining user
-	config := webrtc.Configuration{
-		ICEServers: []webrtc.ICEServer{
-			{
-				URLs: []string{"stun:stun.l.google.com:19302"},
-			},
-		},
+	ticket := &video.Ticket{
+		ID:        id,
+		RoomID:    room.ID,
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(roomTicketTTL).Truncate(time.Second),
 	}
-
-	peerConnection, err := webrtc.NewPeerConnection(config)
-	if err != nil {
-		http.Error(w, "Failed to create peer connection", http.StatusInternalServerError)
+	if err := app.rooms.CreateTicket(r.Context(), ticket); err != nil {
+		switch {
+		case errors.Is(err, video.ErrRoomNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
 		return
 	}
 
-	// Create connection object
-	connection := &PeerConnection{
-		ID:          joinRequest.UserID,
-		PC:          peerConnection,
-		RoomID:      roomID,
-		IsInitiator: false,
+	if err := JsonResponse(w, http.StatusCreated, ticket); err != nil {
+		app.internalServerError(w, r, err)
 	}
+}
 
-	// Configure ICE handlers
-	peerConnection.OnICECandidate(func(candidate *webrtc.ICECandidate) {
-		if candidate == nil {
-			return
-		}
-
-		candidateJSON, err := json.Marshal(candidate.ToJSON())
-		if err != nil {
-			log.Println("Failed to marshal ICE candidate:", err)
-			return
-		}
+// getICEServersHandler godoc
+//
+//	@Summary		Get ICE servers
+//	@Description	Get the STUN and TURN servers to connect video calls through. TURN credentials are issued for the caller and expire at expires_at.
+//	@Tags			video
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	ICEServersResponse
+//	@Failure		401	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/video/ice-servers [get]
+func (app *application) getICEServersHandler(w http.ResponseWriter, r *http.Request) {
+	servers, expiresAt := app.config.video.ice.Servers(getUserfromCtx(r).ID, time.Now())
+
+	if err := JsonResponse(w, http.StatusOK, ICEServersResponse{
+		ICEServers: servers,
+		ExpiresAt:  expiresAt,
+	}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
 
-		log.Println("ICE candidate found:", string(candidateJSON))
-		// We would usually send this to the client
-	})
+type ICEServersResponse struct {
+	ICEServers []video.ICEServer `json:"ice_servers"`
+	ExpiresAt  time.Time         `json:"expires_at"`
+}
 
-	// Add to room
-	room.Connections[joinRequest.UserID] = connection
+// videoSignalingHandler upgrades to the signaling socket of a video room.
+// Peers are identified by their authenticated user ID. The server sends
+// "peers" with who is already in the room, then "join" and "leave" as
+// others come and go, and relays "offer", "answer" and "candidate"
+// messages to the peer named in "to", stamped with "from". In SFU rooms
+// each peer instead connects to the server, which sends the offers;
+// answers and candidates for it leave "to" out. Only the meeting's
+// mentor, mentee and attendees get in, from a while before it starts.
+func (app *application) videoSignalingHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserfromCtx(r)
+	roomID := chi.URLParam(r, "roomID")
 
-	// Get other peer's ID
-	var otherPeerID string
-	for id := range room.Connections {
-		if id != joinRequest.UserID {
-			otherPeerID = id
-			break
+	room, participant, err := app.joinRoom(r.Context(), roomID, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, video.ErrRoomNotFound):
+			app.notFoundResponse(w, r, err)
+		case errors.Is(err, errNotRoomParticipant):
+			app.forbidden(w, r)
+		case errors.Is(err, errRoomNotStarted), errors.Is(err, video.ErrRoomClosed), errors.Is(err, video.ErrRoomFull):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
 		}
+		return
 	}
+	defer func() {
+		// the request context is gone by the time the socket closes
+		if err := app.rooms.Leave(context.Background(), participant); err != nil {
+			app.logger.Errorw("error leaving video room", "room", roomID, "user", user.ID, "error", err)
+		}
+	}()
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"roomId":      roomID,
-		"userId":      joinRequest.UserID,
-		"otherPeerId": otherPeerID,
-		"status":      "joined",
-	})
-}
-
-func handleOffer(room *Room, signal SignalMessage, w http.ResponseWriter) {
-	// Find the connection for the recipient
-	recipientConn, exists := room.Connections[signal.To]
-	if !exists {
-		http.Error(w, "Recipient not found in room", http.StatusNotFound)
+	conn, err := app.signaling.Upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already answered with an error
+		app.logger.Warnw("error upgrading signaling socket", "room", roomID, "error", err)
 		return
 	}
 
-	// Create a session description from the offer
-	offer := webrtc.SessionDescription{
-		Type: webrtc.SDPTypeOffer,
-		SDP:  signal.SDP,
+	var server signaling.Server
+	if room.Mode == video.ModeSFU {
+		server = app.sfu
 	}
+	app.signaling.Serve(roomID, user.ID, conn, server)
+}
 
-	// Set the remote description
-	if err := recipientConn.PC.SetRemoteDescription(offer); err != nil {
-		http.Error(w, "Failed to set remote description", http.StatusInternalServerError)
-		return
+// joinRoom lets user into the room if they attend its meeting, the
+// meeting is paid and it is within the join window.
+func (app *application) joinRoom(ctx context.Context, roomID string, user *store.User) (*video.Room, *video.Participant, error) {
+	room, err := app.rooms.GetRoom(ctx, roomID)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Create answer
-	answer, err := recipientConn.PC.CreateAnswer(nil)
+	meeting, err := app.store.Meetings.GetMeetingByID(ctx, room.MeetingID)
 	if err != nil {
-		http.Error(w, "Failed to create answer", http.StatusInternalServerError)
-		return
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, nil, video.ErrRoomNotFound
+		}
+		return nil, nil, err
 	}
 
-	// Set local description
-	if err = recipientConn.PC.SetLocalDescription(answer); err != nil {
-		http.Error(w, "Failed to set local description", http.StatusInternalServerError)
-		return
+	ok, err := app.attendsMeeting(ctx, meeting, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, errNotRoomParticipant
+	}
+	if !hasRoom(meeting.Status) {
+		return nil, nil, video.ErrRoomClosed
+	}
+	if time.Now().Before(meeting.StartAt.Add(-app.config.video.joinEarly)) {
+		return nil, nil, errRoomNotStarted
 	}
 
-	// Return the answer to the client
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"type": "answer",
-		"sdp":  answer.SDP,
-		"from": signal.To,
-		"to":   signal.UserID,
-	})
+	participant, err := app.rooms.Join(ctx, roomID, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return room, participant, nil
 }
 
-func handleAnswer(room *Room, signal SignalMessage, w http.ResponseWriter) {
-	// Find the connection for the recipient
-	recipientConn, exists := room.Connections[signal.To]
-	if !exists {
-		http.Error(w, "Recipient not found in room", http.StatusNotFound)
+// syncMeetingRoom keeps the meeting's video room in step with the meeting:
+// a paid meeting gets a room, linked from the meeting, open until a while
+// after it ends and recorded if both parties agreed, and other meetings
+// lose theirs. Failures are logged, not returned: the room is opened again
+// when a participant asks for it.
+func (app *application) syncMeetingRoom(ctx context.Context, meeting *store.Meetings) {
+	room, err := app.rooms.GetRoomByMeetingID(ctx, meeting.ID)
+	if err != nil && !errors.Is(err, video.ErrRoomNotFound) {
+		app.logger.Errorw("error loading meeting room", "meeting", meeting.ID, "error", err)
 		return
 	}
 
-	// Create a session description from the answer
-	answer := webrtc.SessionDescription{
-		Type: webrtc.SDPTypeAnswer,
-		SDP:  signal.SDP,
+	if !hasRoom(meeting.Status) {
+		if room == nil {
+			return
+		}
+		if err := app.rooms.CloseRoom(ctx, room.ID); err != nil {
+			app.logger.Errorw("error closing meeting room", "meeting", meeting.ID, "room", room.ID, "error", err)
+			return
+		}
+		app.signaling.CloseRoom(room.ID)
+		return
 	}
 
-	// Set the remote description
-	if err := recipientConn.PC.SetRemoteDescription(answer); err != nil {
-		http.Error(w, "Failed to set remote description", http.StatusInternalServerError)
+	consent, err := app.recordingConsent(ctx, meeting)
+	if err != nil {
+		app.logger.Errorw("error loading recording consent", "meeting", meeting.ID, "error", err)
 		return
 	}
+	mode := roomMode(meeting, consent.Recording)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
-}
-
-func handleCandidate(room *Room, signal SignalMessage, w http.ResponseWriter) {
-	// Find the connection for the recipient
-	recipientConn, exists := room.Connections[signal.To]
-	if !exists {
-		http.Error(w, "Recipient not found in room", http.StatusNotFound)
+	expiresAt := meeting.EndAt().Add(app.config.video.roomGrace).Truncate(time.Second)
+	if room != nil {
+		if !room.ExpiresAt.Equal(expiresAt) {
+			if err := app.rooms.SetRoomExpiry(ctx, room.ID, expiresAt); err != nil {
+				app.logger.Errorw("error moving meeting room", "meeting", meeting.ID, "room", room.ID, "error", err)
+			}
+		}
+		if room.Mode != mode || room.Recording != consent.Recording {
+			if err := app.rooms.SetRoomMode(ctx, room.ID, mode, consent.Recording); err != nil {
+				app.logger.Errorw("error switching meeting room", "meeting", meeting.ID, "room", room.ID, "error", err)
+				return
+			}
+			// whoever is in already reconnects in the new mode
+			app.signaling.CloseRoom(room.ID)
+		}
 		return
 	}
 
-	// Add the ICE candidate
-	if err := recipientConn.PC.AddICECandidate(*signal.Candidate); err != nil {
-		http.Error(w, "Failed to add ICE candidate", http.StatusInternalServerError)
+	id, err := newRoomID()
+	if err != nil {
+		app.logger.Errorw("error creating meeting room", "meeting", meeting.ID, "error", err)
 		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
-}
-
-var pendingSignals = make(map[string][]SignalMessage)
-var pendingSignalsLock sync.Mutex
-
-func roomStatusHandler(w http.ResponseWriter, r *http.Request) {
-	roomID := chi.URLParam(r, "roomID")
-
-	roomLock.Lock()
-	defer roomLock.Unlock()
-
-	room, exists := rooms[roomID]
-	if !exists {
-		http.Error(w, "Room not found", http.StatusNotFound)
+	room = &video.Room{
+		ID:              id,
+		MeetingID:       meeting.ID,
+		MaxParticipants: meeting.MaxParticipants,
+		Mode:            mode,
+		Recording:       consent.Recording,
+		ExpiresAt:       expiresAt,
+	}
+	if err := app.rooms.CreateRoom(ctx, room); err != nil {
+		if !errors.Is(err, video.ErrRoomExists) {
+			app.logger.Errorw("error creating meeting room", "meeting", meeting.ID, "error", err)
+		}
 		return
 	}
 
-	// Get all connection IDs in the room
-	var connectionIDs []string
-	for id := range room.Connections {
-		connectionIDs = append(connectionIDs, id)
+	meeting.Link = fmt.Sprintf("%s/room/%s", app.config.frontendURL, room.ID)
+	if err := app.store.Meetings.UpdateLink(ctx, meeting); err != nil {
+		app.logger.Errorw("error linking meeting room", "meeting", meeting.ID, "room", room.ID, "error", err)
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"roomId":      roomID,
-		"connections": connectionIDs,
-	})
 }
 
-func pendingSignalsHandler(w http.ResponseWriter, r *http.Request) {
-	roomID := chi.URLParam(r, "roomID")
-	userID := chi.URLParam(r, "userID")
-
-	pendingSignalsLock.Lock()
-	defer pendingSignalsLock.Unlock()
-
-	// Get key for this room and user
-	key := roomID + "_" + userID
-
-	// Get all pending signals for this user
-	signals := pendingSignals[key]
-
-	// Clear the signals after retrieving them
-	delete(pendingSignals, key)
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(signals)
+// roomMode picks how media flows in the meeting's room: two people call
+// each other directly, while groups and recorded meetings go through the
+// SFU.
+func roomMode(meeting *store.Meetings, recording bool) string {
+	if meeting.MaxParticipants > 2 || recording {
+		return video.ModeSFU
+	}
+	return video.ModeP2P
 }
 
-// Modify the signal handler to store signals for polling
-func signalHandler(w http.ResponseWriter, r *http.Request) {
-	var signal SignalMessage
-	if err := json.NewDecoder(r.Body).Decode(&signal); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
+// hasRoom reports whether meetings in status have a video room.
+func hasRoom(status store.MeetingStatus) bool {
+	return status == store.MeetingPaid || status == store.MeetingInProgress
+}
 
-	roomLock.Lock()
-	room, exists := rooms[signal.RoomID]
-	roomLock.Unlock()
+// closeExpiredVideoRooms closes rooms whose time is up, disconnecting
+// anyone still in them, until ctx is done.
+func (app *application) closeExpiredVideoRooms(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
 
-	if !exists {
-		http.Error(w, "Room not found", http.StatusNotFound)
-		return
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			closed, err := app.rooms.CloseExpiredRooms(ctx)
+			if err != nil {
+				app.logger.Errorw("error closing expired video rooms", "error", err)
+				continue
+			}
+			for _, id := range closed {
+				app.signaling.CloseRoom(id)
+			}
+			if len(closed) > 0 {
+				app.logger.Infow("closed expired video rooms", "count", len(closed))
+			}
+		}
 	}
+}
 
-	// Add the "from" field to the signal
-	signal.From = signal.UserID
-
-	// Process the signal based on its type
-	switch signal.Type {
-	case "offer":
-		// Store the offer for the recipient to poll
-		pendingSignalsLock.Lock()
-		key := signal.RoomID + "_" + signal.To
-		pendingSignals[key] = append(pendingSignals[key], signal)
-		pendingSignalsLock.Unlock()
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"status": "offer_queued"})
-
-	case "answer":
-		// For answers, we can respond directly since the initiator is waiting
-		handleAnswer(room, signal, w)
-
-	case "candidate":
-		// Store the ICE candidate for the recipient to poll
-		pendingSignalsLock.Lock()
-		key := signal.RoomID + "_" + signal.To
-		pendingSignals[key] = append(pendingSignals[key], signal)
-		pendingSignalsLock.Unlock()
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"status": "candidate_queued"})
-
-	default:
-		http.Error(w, "Unknown signal type", http.StatusBadRequest)
+func newRoomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
 	}
+	return hex.EncodeToString(b), nil
 }
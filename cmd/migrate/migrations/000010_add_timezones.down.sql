@@ -0,0 +1,25 @@
+DROP INDEX IF EXISTS idx_meetings_mentorid_start_at;
+
+ALTER TABLE meetings
+ADD COLUMN day VARCHAR(50),
+ADD COLUMN date VARCHAR(50),
+ADD COLUMN start_time VARCHAR(5),
+ADD COLUMN start_period VARCHAR(2) CHECK (start_period IN ('AM', 'PM'));
+
+UPDATE meetings
+SET day = to_char(start_at AT TIME ZONE 'UTC', 'FMDay'),
+    date = to_char(start_at AT TIME ZONE 'UTC', 'DD Mon YYYY'),
+    start_time = to_char(start_at AT TIME ZONE 'UTC', 'FMHH12:MI'),
+    start_period = to_char(start_at AT TIME ZONE 'UTC', 'AM');
+
+ALTER TABLE meetings
+ALTER COLUMN day SET NOT NULL,
+ALTER COLUMN date SET NOT NULL,
+ALTER COLUMN start_time SET NOT NULL,
+ALTER COLUMN start_period SET NOT NULL,
+DROP COLUMN start_at,
+DROP COLUMN duration_minutes;
+
+ALTER TABLE bookingslots DROP COLUMN timezone;
+ALTER TABLE mentors DROP COLUMN timezone;
+ALTER TABLE users DROP COLUMN timezone;
@@ -0,0 +1,27 @@
+ALTER TABLE users
+ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+ALTER TABLE mentors
+ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+-- Weekly rules stay as wall-clock times, interpreted in the slot's timezone
+ALTER TABLE bookingslots
+ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+ALTER TABLE meetings
+ADD COLUMN start_at TIMESTAMP(0) WITH TIME ZONE,
+ADD COLUMN duration_minutes INTEGER NOT NULL DEFAULT 30 CHECK (duration_minutes > 0);
+
+-- Existing rows were written by the frontend as "02 Jan 2006" + "9:30" + "AM"
+-- with no zone attached, so treat them as UTC.
+UPDATE meetings
+SET start_at = (to_timestamp(date || ' ' || start_time || ' ' || start_period, 'DD Mon YYYY HH12:MI AM')::timestamp) AT TIME ZONE 'UTC';
+
+ALTER TABLE meetings
+ALTER COLUMN start_at SET NOT NULL,
+DROP COLUMN day,
+DROP COLUMN date,
+DROP COLUMN start_time,
+DROP COLUMN start_period;
+
+CREATE INDEX idx_meetings_mentorid_start_at ON meetings(mentorid, start_at);
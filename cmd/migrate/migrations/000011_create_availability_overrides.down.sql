@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS mentor_holiday_calendars;
+DROP TABLE IF EXISTS holidays;
+DROP TABLE IF EXISTS availability_overrides;
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS availability_overrides (
+    id bigserial PRIMARY KEY,
+    userid BIGINT NOT NULL,
+    kind VARCHAR(16) NOT NULL CHECK (kind IN ('blocked', 'extra')),
+    start_at TIMESTAMP(0) WITH TIME ZONE NOT NULL,
+    end_at TIMESTAMP(0) WITH TIME ZONE NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT override_range CHECK (end_at > start_at)
+);
+
+CREATE INDEX idx_availability_overrides_userid ON availability_overrides(userid, start_at);
+
+CREATE TABLE IF NOT EXISTS holidays (
+    id bigserial PRIMARY KEY,
+    calendar VARCHAR(64) NOT NULL,
+    date DATE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    UNIQUE (calendar, date)
+);
+
+-- Calendars a mentor follows; their holidays are days off
+CREATE TABLE IF NOT EXISTS mentor_holiday_calendars (
+    userid BIGINT NOT NULL,
+    calendar VARCHAR(64) NOT NULL,
+    PRIMARY KEY (userid, calendar)
+);
@@ -0,0 +1,14 @@
+DROP TABLE IF EXISTS meeting_status_history;
+
+ALTER TABLE meetings
+ADD COLUMN isconfirm BOOLEAN DEFAULT FALSE,
+ADD COLUMN ispaid BOOLEAN DEFAULT FALSE,
+ADD COLUMN iscompleted BOOLEAN DEFAULT FALSE;
+
+UPDATE meetings
+SET isconfirm = status IN ('confirmed', 'paid', 'in_progress', 'completed', 'no_show', 'refunded'),
+    ispaid = status IN ('paid', 'in_progress', 'completed', 'no_show', 'refunded'),
+    iscompleted = status = 'completed';
+
+ALTER TABLE meetings
+DROP COLUMN status;
@@ -0,0 +1,30 @@
+ALTER TABLE meetings
+ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'requested'
+    CHECK (status IN ('requested', 'confirmed', 'paid', 'in_progress', 'completed', 'cancelled', 'no_show', 'refunded'));
+
+-- The most advanced flag wins
+UPDATE meetings
+SET status = CASE
+    WHEN iscompleted THEN 'completed'
+    WHEN ispaid THEN 'paid'
+    WHEN isconfirm THEN 'confirmed'
+    ELSE 'requested'
+END;
+
+ALTER TABLE meetings
+DROP COLUMN isconfirm,
+DROP COLUMN ispaid,
+DROP COLUMN iscompleted;
+
+CREATE TABLE IF NOT EXISTS meeting_status_history (
+    id bigserial PRIMARY KEY,
+    meeting_id INTEGER NOT NULL REFERENCES meetings(id) ON DELETE CASCADE,
+    from_status VARCHAR(16),
+    to_status VARCHAR(16) NOT NULL,
+    -- NULL when the change came from the system, e.g. a payment webhook
+    changed_by BIGINT,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_meeting_status_history_meeting_id ON meeting_status_history(meeting_id, created_at);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS meeting_reschedules;
+
+ALTER TABLE mentors
+DROP COLUMN cancellation_window_hours;
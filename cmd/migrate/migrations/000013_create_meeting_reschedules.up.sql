@@ -0,0 +1,18 @@
+ALTER TABLE mentors
+ADD COLUMN cancellation_window_hours INTEGER NOT NULL DEFAULT 24 CHECK (cancellation_window_hours >= 0);
+
+CREATE TABLE IF NOT EXISTS meeting_reschedules (
+    id bigserial PRIMARY KEY,
+    meeting_id INTEGER NOT NULL REFERENCES meetings(id) ON DELETE CASCADE,
+    proposed_by BIGINT NOT NULL,
+    start_at TIMESTAMP(0) WITH TIME ZONE NOT NULL,
+    duration_minutes INTEGER NOT NULL CHECK (duration_minutes > 0),
+    reason TEXT NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'declined')),
+    responded_by BIGINT,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    responded_at TIMESTAMP(0) WITH TIME ZONE
+);
+
+-- At most one open proposal per meeting
+CREATE UNIQUE INDEX idx_meeting_reschedules_pending ON meeting_reschedules(meeting_id) WHERE status = 'pending';
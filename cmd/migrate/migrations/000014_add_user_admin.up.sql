@@ -0,0 +1,3 @@
+-- Admins can see and manage every meeting
+ALTER TABLE users
+ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT FALSE;
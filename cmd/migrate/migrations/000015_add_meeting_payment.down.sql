@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_meetings_stripe_session_id;
+
+ALTER TABLE meetings
+DROP COLUMN stripe_session_id,
+DROP COLUMN stripe_payment_intent_id,
+DROP COLUMN paid_amount,
+DROP COLUMN paid_currency,
+DROP COLUMN paid_at;
@@ -0,0 +1,9 @@
+-- Filled by the Stripe webhook when checkout completes
+ALTER TABLE meetings
+ADD COLUMN stripe_session_id VARCHAR(255),
+ADD COLUMN stripe_payment_intent_id VARCHAR(255),
+ADD COLUMN paid_amount BIGINT, -- in the currency's minor unit
+ADD COLUMN paid_currency VARCHAR(3),
+ADD COLUMN paid_at TIMESTAMP(0) WITH TIME ZONE;
+
+CREATE UNIQUE INDEX idx_meetings_stripe_session_id ON meetings(stripe_session_id);
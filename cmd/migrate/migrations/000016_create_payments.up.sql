@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS payments (
+    id bigserial PRIMARY KEY,
+    meeting_id INTEGER NOT NULL REFERENCES meetings(id),
+    payer_id BIGINT NOT NULL,
+    payee_id BIGINT NOT NULL,
+    provider VARCHAR(32) NOT NULL DEFAULT 'stripe',
+    checkout_session_id VARCHAR(255) NOT NULL UNIQUE,
+    payment_intent_id VARCHAR(255),
+    amount BIGINT NOT NULL CHECK (amount >= 0), -- in the currency's minor unit
+    currency VARCHAR(3) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'succeeded', 'failed', 'expired', 'refunded')),
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    paid_at TIMESTAMP(0) WITH TIME ZONE
+);
+
+CREATE INDEX idx_payments_meeting_id ON payments(meeting_id);
+CREATE INDEX idx_payments_payer_id ON payments(payer_id, created_at);
+CREATE INDEX idx_payments_payee_id ON payments(payee_id, created_at);
+
+-- Meetings paid before the ledger existed
+INSERT INTO payments (meeting_id, payer_id, payee_id, checkout_session_id, payment_intent_id, amount, currency, status, created_at, updated_at, paid_at)
+SELECT id, userid::BIGINT, mentorid::BIGINT, stripe_session_id, stripe_payment_intent_id, paid_amount, paid_currency, 'succeeded', paid_at, paid_at, paid_at
+FROM meetings
+WHERE stripe_session_id IS NOT NULL;
@@ -0,0 +1,3 @@
+-- The gig a meeting was booked for; its price is copied to amount at booking
+ALTER TABLE meetings
+ADD COLUMN gig_id BIGINT REFERENCES gigs(id) ON DELETE SET NULL;
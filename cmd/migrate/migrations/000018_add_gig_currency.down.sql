@@ -0,0 +1,2 @@
+ALTER TABLE gigs
+DROP COLUMN IF EXISTS currency;
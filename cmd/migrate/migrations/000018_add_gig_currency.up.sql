@@ -0,0 +1,3 @@
+-- ISO 4217 code, lowercase as Stripe expects; existing gigs were all charged in rupees
+ALTER TABLE gigs
+ADD COLUMN currency VARCHAR(3) NOT NULL DEFAULT 'inr';
@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS refunds;
+
+DROP INDEX IF EXISTS idx_payments_payment_intent_id;
+
+UPDATE payments SET status = 'succeeded' WHERE status = 'partially_refunded';
+UPDATE payments SET status = 'succeeded' WHERE status = 'disputed';
+
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_status_check;
+ALTER TABLE payments ALTER COLUMN status TYPE VARCHAR(16);
+ALTER TABLE payments ADD CONSTRAINT payments_status_check
+    CHECK (status IN ('pending', 'succeeded', 'failed', 'expired', 'refunded'));
+
+ALTER TABLE payments
+DROP COLUMN refunded_amount,
+DROP COLUMN disputed_at;
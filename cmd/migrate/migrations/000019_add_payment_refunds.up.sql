@@ -0,0 +1,25 @@
+ALTER TABLE payments
+ADD COLUMN refunded_amount BIGINT NOT NULL DEFAULT 0, -- in the currency's minor unit
+ADD COLUMN disputed_at TIMESTAMP(0) WITH TIME ZONE;
+
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_status_check;
+ALTER TABLE payments ALTER COLUMN status TYPE VARCHAR(32);
+ALTER TABLE payments ADD CONSTRAINT payments_status_check
+    CHECK (status IN ('pending', 'succeeded', 'failed', 'expired', 'partially_refunded', 'refunded', 'disputed'));
+
+CREATE UNIQUE INDEX idx_payments_payment_intent_id ON payments(payment_intent_id);
+
+-- Refunds issued through the API; refunds made in the Stripe dashboard only
+-- show up in payments.refunded_amount
+CREATE TABLE IF NOT EXISTS refunds (
+    id bigserial PRIMARY KEY,
+    payment_id BIGINT NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+    provider_refund_id VARCHAR(255) NOT NULL UNIQUE,
+    amount BIGINT NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    requested_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_refunds_payment_id ON refunds(payment_id);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS webhook_events (
+    id bigserial PRIMARY KEY,
+    provider VARCHAR(32) NOT NULL,
+    event_id VARCHAR(255) NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    payload BYTEA NOT NULL, -- as delivered, for replays
+    status VARCHAR(16) NOT NULL DEFAULT 'processing'
+        CHECK (status IN ('processing', 'processed', 'ignored', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 1,
+    last_error TEXT NOT NULL DEFAULT '',
+    received_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    processed_at TIMESTAMP(0) WITH TIME ZONE,
+    UNIQUE (provider, event_id)
+);
+
+CREATE INDEX idx_webhook_events_status ON webhook_events(status, received_at);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS payouts;
+
+ALTER TABLE payments
+DROP COLUMN session_amount,
+DROP COLUMN commission_rate;
@@ -0,0 +1,23 @@
+-- What the mentor earns out of a payment: their own price, before the fee
+-- and tax the mentee paid on top, and the commission the platform keeps
+ALTER TABLE payments
+ADD COLUMN session_amount BIGINT, -- in the currency's minor unit; NULL means all of amount
+ADD COLUMN commission_rate NUMERIC(5, 4) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS payouts (
+    id bigserial PRIMARY KEY,
+    mentor_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    amount BIGINT NOT NULL CHECK (amount > 0), -- in the currency's minor unit
+    currency VARCHAR(3) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'requested'
+        CHECK (status IN ('requested', 'approved', 'rejected')),
+    note TEXT NOT NULL DEFAULT '',
+    decided_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    decided_at TIMESTAMP(0) WITH TIME ZONE,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- one open request per mentor and currency
+CREATE UNIQUE INDEX idx_payouts_requested ON payouts(mentor_id, currency) WHERE status = 'requested';
+CREATE INDEX idx_payouts_mentor_id ON payouts(mentor_id, created_at);
+CREATE INDEX idx_payouts_status ON payouts(status, created_at);
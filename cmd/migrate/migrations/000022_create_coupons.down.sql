@@ -0,0 +1,5 @@
+ALTER TABLE meetings
+DROP COLUMN coupon_id,
+DROP COLUMN discount;
+
+DROP TABLE IF EXISTS coupons;
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS coupons (
+    id bigserial PRIMARY KEY,
+    code VARCHAR(32) NOT NULL,
+    -- the mentor whose sessions it discounts; NULL for every mentor
+    mentor_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(8) NOT NULL CHECK (kind IN ('percent', 'fixed')),
+    percent_off INT CHECK (percent_off BETWEEN 1 AND 100),
+    amount_off DECIMAL(10, 2) CHECK (amount_off > 0),
+    currency VARCHAR(3),
+    max_redemptions INT CHECK (max_redemptions > 0),
+    max_per_user INT CHECK (max_per_user > 0),
+    expires_at TIMESTAMP(0) WITH TIME ZONE,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK (
+        (kind = 'percent' AND percent_off IS NOT NULL AND amount_off IS NULL)
+        OR (kind = 'fixed' AND amount_off IS NOT NULL AND currency IS NOT NULL AND percent_off IS NULL)
+    )
+);
+
+CREATE UNIQUE INDEX idx_coupons_code ON coupons(LOWER(code));
+CREATE INDEX idx_coupons_mentor_id ON coupons(mentor_id);
+
+-- A meeting's amount is what the mentee pays after discount; amount plus
+-- discount is the gig price it was booked at
+ALTER TABLE meetings
+ADD COLUMN coupon_id BIGINT REFERENCES coupons(id) ON DELETE SET NULL,
+ADD COLUMN discount DECIMAL(10, 2) NOT NULL DEFAULT 0.00;
+
+CREATE INDEX idx_meetings_coupon_id ON meetings(coupon_id) WHERE coupon_id IS NOT NULL;
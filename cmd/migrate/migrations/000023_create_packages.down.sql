@@ -0,0 +1,5 @@
+ALTER TABLE meetings
+DROP COLUMN purchase_id;
+
+DROP TABLE IF EXISTS package_purchases;
+DROP TABLE IF EXISTS packages;
@@ -0,0 +1,50 @@
+-- A package sells several sessions of a gig up front. With valid_days set
+-- the credits lapse that long after purchase, which is how monthly plans
+-- are sold.
+CREATE TABLE IF NOT EXISTS packages (
+    id bigserial PRIMARY KEY,
+    gig_id BIGINT NOT NULL REFERENCES gigs(id) ON DELETE CASCADE,
+    userid BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    sessions INT NOT NULL CHECK (sessions > 0),
+    amount DECIMAL(10, 2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(3) NOT NULL,
+    valid_days INT CHECK (valid_days > 0),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_packages_userid ON packages(userid);
+
+-- One purchase of a package; its unused sessions are the mentee's credits
+-- with the mentor
+CREATE TABLE IF NOT EXISTS package_purchases (
+    id bigserial PRIMARY KEY,
+    package_id BIGINT NOT NULL REFERENCES packages(id),
+    buyer_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    mentor_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    gig_id BIGINT REFERENCES gigs(id) ON DELETE SET NULL,
+    sessions INT NOT NULL CHECK (sessions > 0),
+    credits_used INT NOT NULL DEFAULT 0 CHECK (credits_used >= 0 AND credits_used <= sessions),
+    valid_days INT,
+    provider VARCHAR(32) NOT NULL,
+    checkout_session_id VARCHAR(255) NOT NULL UNIQUE,
+    payment_intent_id VARCHAR(255),
+    amount BIGINT NOT NULL, -- in the currency's minor unit, like the rest below
+    session_amount BIGINT NOT NULL,
+    commission_rate NUMERIC(5, 4) NOT NULL DEFAULT 0,
+    currency VARCHAR(3) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'paid', 'expired')),
+    expires_at TIMESTAMP(0) WITH TIME ZONE,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    paid_at TIMESTAMP(0) WITH TIME ZONE
+);
+
+CREATE INDEX idx_package_purchases_credits ON package_purchases(buyer_id, mentor_id) WHERE status = 'paid';
+CREATE INDEX idx_package_purchases_mentor_id ON package_purchases(mentor_id, paid_at);
+
+-- Meetings booked with a credit instead of being paid for
+ALTER TABLE meetings
+ADD COLUMN purchase_id BIGINT REFERENCES package_purchases(id) ON DELETE SET NULL;
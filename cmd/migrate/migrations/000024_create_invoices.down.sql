@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS invoices;
+DROP TABLE IF EXISTS invoice_counters;
+
+ALTER TABLE payments
+DROP COLUMN fee_amount,
+DROP COLUMN tax_amount;
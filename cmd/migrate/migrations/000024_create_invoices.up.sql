@@ -0,0 +1,32 @@
+-- What a payment added on top of the mentor's price, for invoices
+ALTER TABLE payments
+ADD COLUMN fee_amount BIGINT NOT NULL DEFAULT 0, -- in the currency's minor unit
+ADD COLUMN tax_amount BIGINT NOT NULL DEFAULT 0;
+
+-- The last invoice number handed out each year. Numbers are taken in the
+-- transaction that writes the invoice, so a failed write gives its number
+-- back and the series has no gaps.
+CREATE TABLE IF NOT EXISTS invoice_counters (
+    year INT PRIMARY KEY,
+    last_number BIGINT NOT NULL
+);
+
+-- An invoice keeps the details as they were when it was issued
+CREATE TABLE IF NOT EXISTS invoices (
+    id bigserial PRIMARY KEY,
+    number VARCHAR(32) NOT NULL UNIQUE,
+    payment_id BIGINT NOT NULL UNIQUE REFERENCES payments(id),
+    mentor_name VARCHAR(255) NOT NULL,
+    mentor_email VARCHAR(255) NOT NULL,
+    mentee_name VARCHAR(255) NOT NULL,
+    mentee_email VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    subtotal BIGINT NOT NULL, -- in the currency's minor unit, like the rest below
+    fee BIGINT NOT NULL,
+    tax BIGINT NOT NULL,
+    total BIGINT NOT NULL,
+    paid_at TIMESTAMP(0) WITH TIME ZONE NOT NULL,
+    issued_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    emailed_at TIMESTAMP(0) WITH TIME ZONE
+);
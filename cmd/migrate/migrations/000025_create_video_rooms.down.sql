@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS video_room_participants;
+DROP TABLE IF EXISTS video_rooms;
@@ -0,0 +1,25 @@
+-- A video room for a meeting. It can be joined until expires_at, a while
+-- after the meeting ends, and closed_at is set once it is cleaned up.
+CREATE TABLE IF NOT EXISTS video_rooms (
+    id VARCHAR(64) PRIMARY KEY,
+    meeting_id BIGINT NOT NULL REFERENCES meetings(id) ON DELETE CASCADE,
+    max_participants INT NOT NULL CHECK (max_participants > 0),
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP(0) WITH TIME ZONE NOT NULL,
+    closed_at TIMESTAMP(0) WITH TIME ZONE
+);
+
+-- a meeting has at most one open room
+CREATE UNIQUE INDEX idx_video_rooms_meeting_id ON video_rooms(meeting_id) WHERE closed_at IS NULL;
+CREATE INDEX idx_video_rooms_expires_at ON video_rooms(expires_at) WHERE closed_at IS NULL;
+
+-- One connection of a user to a room; left_at is unset while it lasts
+CREATE TABLE IF NOT EXISTS video_room_participants (
+    id bigserial PRIMARY KEY,
+    room_id VARCHAR(64) NOT NULL REFERENCES video_rooms(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    joined_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    left_at TIMESTAMP(0) WITH TIME ZONE
+);
+
+CREATE INDEX idx_video_room_participants_room_id ON video_room_participants(room_id) WHERE left_at IS NULL;
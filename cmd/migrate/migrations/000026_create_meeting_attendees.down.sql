@@ -0,0 +1,7 @@
+ALTER TABLE video_rooms
+DROP COLUMN mode;
+
+DROP TABLE IF EXISTS meeting_attendees;
+
+ALTER TABLE meetings
+DROP COLUMN max_participants;
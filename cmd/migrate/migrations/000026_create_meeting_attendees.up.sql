@@ -0,0 +1,19 @@
+-- How many people a meeting's video room holds. Past two the room runs
+-- through the server's SFU and the mentor invites the extra attendees.
+ALTER TABLE meetings
+ADD COLUMN max_participants INT NOT NULL DEFAULT 2 CHECK (max_participants >= 2);
+
+-- Users invited to a group meeting besides its mentee
+CREATE TABLE IF NOT EXISTS meeting_attendees (
+    meeting_id BIGINT NOT NULL REFERENCES meetings(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    added_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (meeting_id, user_id)
+);
+
+CREATE INDEX idx_meeting_attendees_user_id ON meeting_attendees(user_id);
+
+-- p2p rooms relay signaling between two browsers; sfu rooms forward media
+-- through the server
+ALTER TABLE video_rooms
+ADD COLUMN mode VARCHAR(8) NOT NULL DEFAULT 'p2p' CHECK (mode IN ('p2p', 'sfu'));
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS recordings;
+
+ALTER TABLE video_rooms
+DROP COLUMN recording;
+
+DROP TABLE IF EXISTS recording_consents;
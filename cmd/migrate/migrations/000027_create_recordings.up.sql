@@ -0,0 +1,30 @@
+-- The mentor and the mentee each opt in to recording a meeting; it is only
+-- recorded once both have
+CREATE TABLE IF NOT EXISTS recording_consents (
+    meeting_id BIGINT NOT NULL REFERENCES meetings(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    consented_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (meeting_id, user_id)
+);
+
+-- Recorded rooms run through the SFU, which writes the tracks to disk
+ALTER TABLE video_rooms
+ADD COLUMN recording BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- One track a participant published in a recorded room, saved at path.
+-- ended_at and size are set once the track ends.
+CREATE TABLE IF NOT EXISTS recordings (
+    id bigserial PRIMARY KEY,
+    meeting_id BIGINT NOT NULL REFERENCES meetings(id) ON DELETE CASCADE,
+    room_id VARCHAR(64) NOT NULL,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(8) NOT NULL,
+    mime_type VARCHAR(32) NOT NULL,
+    path TEXT NOT NULL,
+    size BIGINT NOT NULL DEFAULT 0,
+    started_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    ended_at TIMESTAMP(0) WITH TIME ZONE
+);
+
+CREATE INDEX idx_recordings_meeting_id ON recordings(meeting_id);
+CREATE INDEX idx_recordings_started_at ON recordings(started_at);
@@ -0,0 +1,5 @@
+-- A checkout that completed after its meeting was paid through another
+-- one, or could no longer be paid. The money is refunded in full and never
+-- counts as the meeting's payment.
+ALTER TABLE payments
+ADD COLUMN unapplied BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,10 @@
+-- A one-time ticket to open a room's signaling socket, which browsers
+-- can't send the session token to in a header
+CREATE TABLE IF NOT EXISTS video_room_tickets (
+    id VARCHAR(64) PRIMARY KEY,
+    room_id VARCHAR(64) NOT NULL REFERENCES video_rooms(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP(0) WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX idx_video_room_tickets_expires_at ON video_room_tickets(expires_at);
@@ -0,0 +1,2 @@
+ALTER TABLE recordings
+ADD COLUMN kind VARCHAR(8) NOT NULL DEFAULT 'video';
@@ -0,0 +1,4 @@
+-- A recording holds everything a participant published, muxed into one
+-- WebM file, so it no longer has a kind
+ALTER TABLE recordings
+DROP COLUMN kind;
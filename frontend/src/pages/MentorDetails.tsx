@@ -24,7 +24,7 @@ interface MentorDetails {
   name: string;
   country: string;
   language: string[];
-  gigs: { title: string; amount: number; description: string; expertise: string; discipline: string[] }[];
+  gigs: { id: number; title: string; amount: number; description: string; expertise: string; discipline: string[] }[];
   education: { degree: string; field: string; institute: string; year_from: string; year_to: string }[];
   experience: { title: string; company: string; description: string; year_from: string; year_to: string }[];
   workingat: { title: string; company: string; totalyear: number; linkedin: string; github: string; instagram: string };
@@ -223,7 +223,7 @@ export const MentorDetails: React.FC = () => {
       date: selectedDate,
       start_time: selectedTimeSlot.time,
       start_period: selectedTimeSlot.period,
-      amount: mentor.gigs && mentor.gigs.length > 0 ? mentor.gigs[0].amount : 0, // Use amount from first gig or default to 0
+      gig_id: mentor.gigs && mentor.gigs.length > 0 ? mentor.gigs[0].id : undefined, // The server prices the booking from this gig
       link: 'http://localhost:8082/room/create'
     };
 
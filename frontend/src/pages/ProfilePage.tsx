@@ -20,9 +20,7 @@ interface Meeting {
   date: string;
   start_time: string;
   start_period: string;
-  isconfirm: boolean;
-  ispaid: boolean;
-  iscompleted: boolean;
+  status: 'requested' | 'confirmed' | 'paid' | 'in_progress' | 'completed' | 'cancelled' | 'no_show' | 'refunded';
   amount: number;
   link: string;
   menteeName?: string;
@@ -37,6 +35,7 @@ export const ProfilePage = () => {
   const [unpaidLoading, setUnpaidLoading] = useState(true);
   const [error, setError] = useState<string | null>(null);
   const [unpaidError, setUnpaidError] = useState<string | null>(null);
+  const [couponCodes, setCouponCodes] = useState<Record<number, string>>({});
   const [paidMeetings, setPaidMeetings] = useState<Meeting[]>([]);
   const [paidLoading, setPaidLoading] = useState(true);
   const [paidError, setPaidError] = useState<string | null>(null);
@@ -196,13 +195,10 @@ export const ProfilePage = () => {
         }
       };
 
+      const couponCode = couponCodes[meeting.id]?.trim();
       const paymentData = {
-        price: meeting.amount,
-        currency: 'inr',
-        email: user.email,
-        menteeName: meeting.menteeName,
-        mentorName: meeting.mentorName,
-        id: meeting.id,
+        meeting_id: meeting.id,
+        ...(couponCode ? { coupon_code: couponCode } : {}),
       };
 
       const response = await axios.post(
@@ -216,7 +212,9 @@ export const ProfilePage = () => {
       }
 
     } catch (err: any) {
-      if (err.response && err.response.status === 0) {
+      if (err.response?.status === 400 && err.response.data?.error) {
+        setUnpaidError(err.response.data.error);
+      } else if (err.response && err.response.status === 0) {
         setUnpaidError('CORS error: The server is not allowing cross-origin requests. Please contact your administrator.');
       } else {
         setUnpaidError(err.message || 'Failed to process payment');
@@ -342,6 +340,13 @@ export const ProfilePage = () => {
                         </div>
                         <div className="flex flex-col items-end">
                           <p className="mb-2 text-lg font-semibold text-gray-900 dark:text-white">₹{meeting.amount}</p>
+                          <input
+                            type="text"
+                            placeholder="Coupon code"
+                            value={couponCodes[meeting.id] || ''}
+                            onChange={(e) => setCouponCodes({ ...couponCodes, [meeting.id]: e.target.value })}
+                            className="mb-2 px-3 py-2 w-40 border border-gray-300 dark:border-gray-600 rounded-md text-sm dark:bg-gray-700 dark:text-white"
+                          />
                           <button
                             onClick={() => handlePayment(meeting.id)}
                             className="px-4 py-2 bg-green-600 hover:bg-green-700 text-white rounded-md focus:outline-none focus:ring-2 focus:ring-green-500"
@@ -8,11 +8,26 @@ export const Room = () => {
     const [isConnected, setIsConnected] = useState(false);
     const [isInitiator, setIsInitiator] = useState(false);
     const [remotePeerId, setRemotePeerId] = useState<string | null>(null);
+    // Group rooms go through the server's SFU, which sends everyone else's
+    // streams down our one connection to it
+    const [remoteStreams, setRemoteStreams] = useState<MediaStream[]>([]);
+    const modeRef = useRef<'p2p' | 'sfu'>('p2p');
+    // Both parties agreed to record the session; the server is saving it
+    const [isRecording, setIsRecording] = useState(false);
     const localVideoRef = useRef<HTMLVideoElement>(null);
     const remoteVideoRef = useRef<HTMLVideoElement>(null);
     const peerConnectionRef = useRef<RTCPeerConnection | null>(null);
     const localStreamRef = useRef<MediaStream | null>(null);
     
+    const socketRef = useRef<WebSocket | null>(null);
+    const iceServersRef = useRef<RTCIceServer[]>([]);
+
+    const sendSignal = (message: Record<string, unknown>) => {
+      if (socketRef.current && socketRef.current.readyState === WebSocket.OPEN) {
+        socketRef.current.send(JSON.stringify(message));
+      }
+    };
+
     // Initialize the meeting room
     useEffect(() => {
       const initRoom = async () => {
@@ -29,52 +44,57 @@ export const Room = () => {
           if (localVideoRef.current) {
             localVideoRef.current.srcObject = stream;
           }
-          
-          // Either create or join a room
-          const userId = localStorage.getItem('userId') || 'user-' + Math.random().toString(36).substring(2, 7);
-          localStorage.setItem('userId', userId);
-  
-          // Try to join the room first
-          const joinResponse = await fetch(`${API_URL}/video/join-room/${roomId}`, {
+
+          // The signaling socket authenticates with the JWT; browsers can't
+          // send headers on a WebSocket so it goes in the query string
+          const token = localStorage.getItem('token');
+
+          // Only the meeting's mentor, mentee and attendees may get in
+          const statusResponse = await fetch(`${API_URL}/video/room-status/${roomId}`, {
+            headers: {
+              Authorization: `Bearer ${token}`,
+            },
+          });
+          if (!statusResponse.ok) {
+            const errorData = await statusResponse.json().catch(() => null);
+            throw new Error(errorData?.error || 'Room not available');
+          }
+          const statusData = await statusResponse.json();
+          modeRef.current = statusData.data.mode === 'sfu' ? 'sfu' : 'p2p';
+          setIsRecording(Boolean(statusData.data.recording));
+
+          // STUN and TURN servers, with TURN credentials issued for us
+          const iceResponse = await fetch(`${API_URL}/video/ice-servers`, {
+            headers: {
+              Authorization: `Bearer ${token}`,
+            },
+          });
+          if (iceResponse.ok) {
+            const iceData = await iceResponse.json();
+            iceServersRef.current = iceData.data.ice_servers;
+          }
+
+          // The socket can't carry our token in a header, and it doesn't
+          // belong in a URL; a one-time ticket stands in for it
+          const ticketResponse = await fetch(`${API_URL}/video/ticket/${roomId}`, {
             method: 'POST',
             headers: {
-              'Content-Type': 'application/json',
+              Authorization: `Bearer ${token}`,
             },
-            body: JSON.stringify({ userId }),
           });
-          
-          if (joinResponse.ok) {
-            const joinData = await joinResponse.json();
-            setIsInitiator(false);
-            setRemotePeerId(joinData.otherPeerId);
-            await setupPeerConnection(userId, joinData.otherPeerId, false);
-            setIsConnected(true);
-          } else if (joinResponse.status === 404) {
-            // Room doesn't exist, create it
-            const createResponse = await fetch(`${API_URL}/video/create-room`, {
-              method: 'POST',
-              headers: {
-                'Content-Type': 'application/json',
-              },
-              body: JSON.stringify({ roomId, userId }),
-            });
-            
-            if (createResponse.ok) {
-              setIsInitiator(true);
-              setIsConnected(true);
-              await setupPeerConnection(userId, null, true);
-              
-              // Poll for peer join events when you're the initiator
-              startPollingForPeers(userId);
-            } else {
-              const errorData = await createResponse.text();
-              throw new Error(`Failed to create room: ${errorData}`);
-            }
-          } else {
-            // Add this block to handle other error status codes
-            const errorData = await joinResponse.text();
-            throw new Error(`Failed to join room: ${errorData}`);
+          if (!ticketResponse.ok) {
+            const errorData = await ticketResponse.json().catch(() => null);
+            throw new Error(errorData?.error || 'Room not available');
           }
+          const ticketData = await ticketResponse.json();
+
+          const wsUrl = `${API_URL.replace(/^http/, 'ws')}/ws/video/${roomId}?ticket=${encodeURIComponent(ticketData.data.ticket)}`;
+          const socket = new WebSocket(wsUrl);
+          socketRef.current = socket;
+
+          socket.onopen = () => setIsConnected(true);
+          socket.onclose = () => setIsConnected(false);
+          socket.onmessage = (event) => handleSignal(JSON.parse(event.data));
         } catch (error) {
           console.error('Error initializing room:', error);
           alert('Error connecting to room: ' + (error instanceof Error ? error.message : 'Unknown error'));
@@ -85,6 +105,9 @@ export const Room = () => {
   
       // Cleanup
       return () => {
+        if (socketRef.current) {
+          socketRef.current.close();
+        }
         if (localStreamRef.current) {
           localStreamRef.current.getTracks().forEach(track => track.stop());
         }
@@ -94,47 +117,108 @@ export const Room = () => {
       };
     }, [roomId]);
 
-    // Poll for new peers joining the room
-    const startPollingForPeers = (userId: string) => {
-      // In a real implementation, you would use WebSockets instead of polling
-      const intervalId = setInterval(async () => {
-        try {
-          const response = await fetch(`${API_URL}/video/room-status/${roomId}`, {
-            method: 'GET',
-            headers: {
-              'Content-Type': 'application/json',
+    // Messages from the signaling server: who is in the room, who joined or
+    // left, and the offers, answers and candidates other peers sent us. In
+    // SFU rooms the offers and candidates come from the server itself, with
+    // no "from", and peers coming and going only changes its offers.
+    const handleSignal = async (signal: any) => {
+      if (modeRef.current === 'sfu') {
+        await handleServerSignal(signal);
+        return;
+      }
+      switch (signal.type) {
+        case 'peers':
+          // We joined; whoever is already here calls us
+          setIsInitiator(false);
+          break;
+        case 'join':
+          // Someone joined after us; call them
+          setIsInitiator(true);
+          setRemotePeerId(String(signal.from));
+          setupPeerConnection(signal.from);
+          await createOffer(signal.from);
+          break;
+        case 'leave':
+          setRemotePeerId(null);
+          if (peerConnectionRef.current) {
+            peerConnectionRef.current.close();
+            peerConnectionRef.current = null;
+          }
+          if (remoteVideoRef.current) {
+            remoteVideoRef.current.srcObject = null;
+          }
+          break;
+        case 'offer':
+          setRemotePeerId(String(signal.from));
+          setupPeerConnection(signal.from);
+          await handleRemoteOffer(signal.from, signal.sdp);
+          break;
+        case 'answer':
+          if (peerConnectionRef.current) {
+            await peerConnectionRef.current.setRemoteDescription(new RTCSessionDescription({
+              type: 'answer',
+              sdp: signal.sdp,
+            }));
+          }
+          break;
+        case 'candidate':
+          if (peerConnectionRef.current && signal.candidate) {
+            try {
+              await peerConnectionRef.current.addIceCandidate(new RTCIceCandidate(signal.candidate));
+            } catch (err) {
+              console.error('Error adding received ICE candidate', err);
             }
-          });
-          
-          if (response.ok) {
-            const data = await response.json();
-            // If we found a new peer that isn't us and we don't already have a remotePeerId
-            const otherPeers = data.connections.filter((id: string) => id !== userId);
-            if (otherPeers.length > 0 && !remotePeerId) {
-              const newPeerId = otherPeers[0];
-              setRemotePeerId(newPeerId);
-              await createOffer(userId, newPeerId);
+          }
+          break;
+        case 'error':
+          console.error('Signaling error:', signal.error);
+          break;
+      }
+    };
+  
+    const handleServerSignal = async (signal: any) => {
+      switch (signal.type) {
+        case 'offer':
+          // The server renegotiates on the same connection as streams come and go
+          if (!peerConnectionRef.current) {
+            setupPeerConnection(0);
+          }
+          await handleRemoteOffer(0, signal.sdp);
+          break;
+        case 'candidate':
+          if (peerConnectionRef.current && signal.candidate) {
+            try {
+              await peerConnectionRef.current.addIceCandidate(new RTCIceCandidate(signal.candidate));
+            } catch (err) {
+              console.error('Error adding received ICE candidate', err);
             }
           }
-        } catch (error) {
-          console.error('Error polling for peers:', error);
+          break;
+        case 'error':
+          console.error('Signaling error:', signal.error);
+          break;
+      }
+    };
+
+    // Keep one tile per remote stream; the server names each stream after
+    // the user publishing it and drops its tracks when they leave
+    const addRemoteStream = (stream: MediaStream) => {
+      setRemoteStreams(streams => streams.some(s => s.id === stream.id) ? streams : [...streams, stream]);
+      stream.onremovetrack = () => {
+        if (stream.getTracks().length === 0) {
+          setRemoteStreams(streams => streams.filter(s => s.id !== stream.id));
         }
-      }, 2000);
-      
-      // Return function to clean up interval
-      return () => clearInterval(intervalId);
+      };
     };
-  
-    const setupPeerConnection = async (userId: string, remotePeerId: string | null, isInitiator: boolean) => {
-      // Create RTCPeerConnection with STUN server
+
+    const setupPeerConnection = (remotePeerId: number) => {
       const configuration: RTCConfiguration = {
-        iceServers: [
-          {
-            urls: 'stun:stun.l.google.com:19302',
-          },
-        ],
+        iceServers: iceServersRef.current,
       };
-  
+
+      if (peerConnectionRef.current) {
+        peerConnectionRef.current.close();
+      }
       const peerConnection = new RTCPeerConnection(configuration);
       peerConnectionRef.current = peerConnection;
   
@@ -150,6 +234,12 @@ export const Room = () => {
       // Set up event handlers for the connection
       peerConnection.ontrack = (event) => {
         console.log('Got remote track:', event.streams[0]);
+        if (modeRef.current === 'sfu') {
+          if (event.streams && event.streams[0]) {
+            addRemoteStream(event.streams[0]);
+          }
+          return;
+        }
         if (remoteVideoRef.current && event.streams && event.streams[0]) {
           // Important fix: Set remote video stream and ensure it plays
           remoteVideoRef.current.srcObject = event.streams[0];
@@ -157,86 +247,25 @@ export const Room = () => {
         }
       };
   
-      peerConnection.onicecandidate = async (event) => {
-        if (event.candidate && remotePeerId) {
-          // Send ICE candidate to the server
-          try {
-            await fetch(`${API_URL}/video/signal`, {
-              method: 'POST',
-              headers: {
-                'Content-Type': 'application/json',
-              },
-              body: JSON.stringify({
-                type: 'candidate',
-                candidate: event.candidate.toJSON(),
-                userId,
-                roomId,
-                to: remotePeerId,
-              }),
-            });
-          } catch (error) {
-            console.error('Error sending ICE candidate:', error);
-          }
+      peerConnection.onicecandidate = (event) => {
+        if (event.candidate) {
+          sendSignal({
+            type: 'candidate',
+            candidate: event.candidate.toJSON(),
+            to: remotePeerId || undefined,
+          });
         }
       };
   
       peerConnection.oniceconnectionstatechange = () => {
         console.log('ICE connection state:', peerConnection.iceConnectionState);
-        if (peerConnection.iceConnectionState === 'connected' || 
-            peerConnection.iceConnectionState === 'completed') {
-          setIsConnected(true);
-        }
       };
 
-      // For non-initiators, we need to prepare to receive an offer
-      if (!isInitiator && remotePeerId) {
-        // Set up polling for signaling data (offers, answers, candidates)
-        startPollingForSignals(userId, remotePeerId);
-      }
-
       return peerConnection;
     };
 
-    // Poll for signaling messages (offers, answers, candidates)
-    const startPollingForSignals = (userId: string, otherPeerId: string) => {
-      const pollInterval = setInterval(async () => {
-        try {
-          const response = await fetch(`${API_URL}/video/pending-signals/${roomId}/${userId}`, {
-            method: 'GET',
-            headers: {
-              'Content-Type': 'application/json',
-            }
-          });
-
-          if (response.ok) {
-            const signals = await response.json();
-            
-            for (const signal of signals) {
-              if (signal.type === 'offer') {
-                await handleRemoteOffer(userId, otherPeerId, signal.sdp);
-              } else if (signal.type === 'candidate' && signal.candidate) {
-                // Handle ice candidates
-                if (peerConnectionRef.current) {
-                  try {
-                    await peerConnectionRef.current.addIceCandidate(new RTCIceCandidate(signal.candidate));
-                    console.log('Added ICE candidate');
-                  } catch (err) {
-                    console.error('Error adding received ICE candidate', err);
-                  }
-                }
-              }
-            }
-          }
-        } catch (error) {
-          console.error('Error checking for signals:', error);
-        }
-      }, 1000);
-
-      return () => clearInterval(pollInterval);
-    };
-
-    // Handle incoming offer when joining a room where someone is waiting
-    const handleRemoteOffer = async (userId: string, remotePeerId: string, offerSdp: string) => {
+    // Handle incoming offer from a peer that was in the room before us
+    const handleRemoteOffer = async (remotePeerId: number, offerSdp: string) => {
       if (!peerConnectionRef.current) return;
 
       try {
@@ -248,102 +277,38 @@ export const Room = () => {
         const answer = await peerConnectionRef.current.createAnswer();
         await peerConnectionRef.current.setLocalDescription(answer);
 
-        // Send the answer back
-        await fetch(`${API_URL}/video/signal`, {
-          method: 'POST',
-          headers: {
-            'Content-Type': 'application/json',
-          },
-          body: JSON.stringify({
-            type: 'answer',
-            sdp: answer.sdp,
-            userId,
-            roomId,
-            to: remotePeerId,
-          }),
+        sendSignal({
+          type: 'answer',
+          sdp: answer.sdp,
+          to: remotePeerId || undefined,
         });
       } catch (error) {
         console.error('Error handling offer:', error);
       }
     };
   
-    const createOffer = async (userId: string, remotePeerId: string) => {
+    const createOffer = async (remotePeerId: number) => {
       if (!peerConnectionRef.current) return;
   
       try {
         const offer = await peerConnectionRef.current.createOffer();
         await peerConnectionRef.current.setLocalDescription(offer);
   
-        // Send the offer to the server
-        await fetch(`${API_URL}/video/signal`, {
-          method: 'POST',
-          headers: {
-            'Content-Type': 'application/json',
-          },
-          body: JSON.stringify({
-            type: 'offer',
-            sdp: offer.sdp,
-            userId,
-            roomId,
-            to: remotePeerId,
-          }),
+        sendSignal({
+          type: 'offer',
+          sdp: offer.sdp,
+          to: remotePeerId || undefined,
         });
-        
-        // After sending the offer, start polling for the answer
-        const checkForAnswer = async () => {
-          try {
-            const response = await fetch(`${API_URL}/video/pending-signals/${roomId}/${userId}`, {
-              method: 'GET',
-              headers: {
-                'Content-Type': 'application/json',
-              }
-            });
-            
-            if (response.ok) {
-              const signals = await response.json();
-              
-              for (const signal of signals) {
-                if (signal.type === 'answer' && peerConnectionRef.current) {
-                  const answer = new RTCSessionDescription({
-                    type: 'answer',
-                    sdp: signal.sdp,
-                  });
-                  await peerConnectionRef.current.setRemoteDescription(answer);
-                  console.log('Successfully set remote description (answer)');
-                  return true; // Successfully processed answer
-                } else if (signal.type === 'candidate' && signal.candidate && peerConnectionRef.current) {
-                  try {
-                    await peerConnectionRef.current.addIceCandidate(new RTCIceCandidate(signal.candidate));
-                    console.log('Added ICE candidate from poll');
-                  } catch (err) {
-                    console.error('Error adding received ICE candidate', err);
-                  }
-                }
-              }
-            }
-            return false; // No answer processed
-          } catch (error) {
-            console.error('Error checking for answer:', error);
-            return false;
-          }
-        };
-        
-        // Poll for answer a few times
-        let attempts = 0;
-        const maxAttempts = 10;
-        const pollForAnswer = setInterval(async () => {
-          attempts++;
-          const gotAnswer = await checkForAnswer();
-          if (gotAnswer || attempts >= maxAttempts) {
-            clearInterval(pollForAnswer);
-          }
-        }, 1000);
       } catch (error) {
         console.error('Error creating offer:', error);
       }
     };
   
     const leaveRoom = () => {
+      if (socketRef.current) {
+        socketRef.current.close();
+      }
+
       // Stop all tracks
       if (localStreamRef.current) {
         localStreamRef.current.getTracks().forEach(track => track.stop());
@@ -382,7 +347,14 @@ export const Room = () => {
       <div className="flex flex-col items-center min-h-screen bg-gray-100 p-4">
         <div className="w-full max-w-4xl bg-white rounded-lg shadow-md p-4 mb-4">
           <div className="flex justify-between items-center mb-4">
-            <h1 className="text-xl font-bold">Room: {roomId}</h1>
+            <h1 className="text-xl font-bold">
+              Room: {roomId}
+              {isRecording && (
+                <span className="ml-3 align-middle text-sm bg-red-600 text-white py-0.5 px-2 rounded-full">
+                  Recording
+                </span>
+              )}
+            </h1>
             <div>
               <span className="mr-4">
                 Status: {isConnected ? 'Connected' : 'Connecting...'}
@@ -410,6 +382,32 @@ export const Room = () => {
               </div>
             </div>
   
+            {modeRef.current === 'sfu' ? (
+            <div className="flex-1">
+              <h2 className="text-lg font-semibold mb-2">Participants</h2>
+              <div className="grid grid-cols-2 gap-2">
+                {remoteStreams.map(stream => (
+                  <div key={stream.id} className="bg-black rounded-lg overflow-hidden aspect-video">
+                    <video
+                      ref={video => {
+                        if (video && video.srcObject !== stream) {
+                          video.srcObject = stream;
+                        }
+                      }}
+                      autoPlay
+                      playsInline
+                      className="w-full h-full object-cover"
+                    />
+                  </div>
+                ))}
+              </div>
+              {remoteStreams.length === 0 && (
+                <div className="bg-black rounded-lg aspect-video flex items-center justify-center text-white">
+                  Waiting for others to join...
+                </div>
+              )}
+            </div>
+            ) : (
             <div className="flex-1">
               <h2 className="text-lg font-semibold mb-2">Remote Video</h2>
               <div className="bg-black rounded-lg overflow-hidden aspect-video relative">
@@ -426,6 +424,7 @@ export const Room = () => {
                 )}
               </div>
             </div>
+            )}
           </div>
         </div>
   
@@ -457,7 +456,6 @@ export const Room = () => {
           <h2 className="text-lg font-semibold mb-2">Connection Status</h2>
           <div className="grid grid-cols-2 gap-4">
             <div>
-              <p><strong>Your ID:</strong> {localStorage.getItem('userId')}</p>
               <p><strong>Room ID:</strong> {roomId}</p>
               <p><strong>You are:</strong> {isInitiator ? 'Host' : 'Participant'}</p>
             </div>
@@ -0,0 +1,416 @@
+package availability
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Althaf66/Appointr/internal/store"
+)
+
+// DefaultDuration is the length of a session when none is given
+const DefaultDuration = 30 * time.Minute
+
+var (
+	ErrInvalidTime      = errors.New("invalid time")
+	ErrSlotUnavailable  = errors.New("requested time is outside the mentor's availability")
+	ErrInvalidSlot      = errors.New("booking slot must end after it starts")
+	ErrOverlappingSlots = errors.New("booking slots overlap")
+)
+
+// Slot is a concrete bookable time instance
+type Slot struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+func (s Slot) overlaps(o Slot) bool {
+	return s.Start.Before(o.End) && o.Start.Before(s.End)
+}
+
+// In renders the slot in loc.
+func (s Slot) In(loc *time.Location) Slot {
+	return Slot{Start: s.Start.In(loc), End: s.End.In(loc)}
+}
+
+type Service struct {
+	store store.Storage
+	now   func() time.Time
+}
+
+func NewService(store store.Storage) *Service {
+	return &Service{
+		store: store,
+		now:   time.Now,
+	}
+}
+
+// schedule is when a mentor can be booked around some range: open windows
+// a session must fit in, and blocked time that always wins.
+type schedule struct {
+	open    []Slot
+	blocked []Slot
+}
+
+func (s *Service) loadSchedule(ctx context.Context, mentorUserID int64, from, to time.Time) (*schedule, error) {
+	rules, err := s.store.BookingSlot.GetBookingSlotsByUserID(ctx, mentorUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// rule days are local to each rule's zone, and overnight windows start
+	// the day before, so pad the holiday lookup
+	holidays, err := s.store.Holidays.GetHolidaysForUser(ctx, mentorUserID,
+		from.AddDate(0, 0, -2).UTC().Format(store.HolidayDateLayout),
+		to.AddDate(0, 0, 1).UTC().Format(store.HolidayDateLayout))
+	if err != nil {
+		return nil, err
+	}
+	daysOff := make(map[string]bool, len(holidays))
+	for _, h := range holidays {
+		daysOff[h.Date] = true
+	}
+
+	overrides, err := s.store.Overrides.GetOverridesByUserID(ctx, mentorUserID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	sched := &schedule{}
+	for _, rule := range rules {
+		windows, err := ruleWindows(rule, from, to, daysOff)
+		if err != nil {
+			return nil, err
+		}
+		sched.open = append(sched.open, windows...)
+	}
+
+	for _, o := range overrides {
+		window := Slot{Start: o.StartAt, End: o.EndAt}
+		switch o.Kind {
+		case store.OverrideExtra:
+			sched.open = append(sched.open, window)
+		case store.OverrideBlocked:
+			sched.blocked = append(sched.blocked, window)
+		}
+	}
+
+	return sched, nil
+}
+
+// FreeSlots expands the mentor's schedule into instants within [from, to)
+// and removes the ones already taken by meetings or time off.
+func (s *Service) FreeSlots(ctx context.Context, mentorUserID int64, from, to time.Time, duration time.Duration) ([]Slot, error) {
+	sched, err := s.loadSchedule(ctx, mentorUserID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	meetings, err := s.store.Meetings.GetMeetingsByMentorID(ctx, mentorUserID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	slots, err := Expand(sched.open, from, to, duration)
+	if err != nil {
+		return nil, err
+	}
+
+	busy := append([]Slot{}, sched.blocked...)
+	for _, m := range meetings {
+		busy = append(busy, Slot{Start: m.StartAt, End: m.EndAt()})
+	}
+
+	now := s.now()
+	free := []Slot{}
+	for _, slot := range Subtract(slots, busy) {
+		if slot.Start.Before(now) {
+			continue
+		}
+		free = append(free, slot)
+	}
+
+	return free, nil
+}
+
+// CheckBookable reports whether a session starting at start fits entirely
+// inside the mentor's schedule without touching any time off.
+func (s *Service) CheckBookable(ctx context.Context, mentorUserID int64, start time.Time, duration time.Duration) error {
+	if start.Before(s.now()) {
+		return ErrSlotUnavailable
+	}
+
+	want := Slot{Start: start, End: start.Add(duration)}
+
+	// the session may straddle midnight in a rule's zone, so look at the
+	// windows on both sides of it
+	sched, err := s.loadSchedule(ctx, mentorUserID, want.Start.Add(-24*time.Hour), want.End.Add(24*time.Hour))
+	if err != nil {
+		return err
+	}
+
+	for _, b := range sched.blocked {
+		if want.overlaps(b) {
+			return ErrSlotUnavailable
+		}
+	}
+
+	for _, w := range sched.open {
+		if !want.Start.Before(w.Start) && !want.End.After(w.End) {
+			return nil
+		}
+	}
+
+	return ErrSlotUnavailable
+}
+
+// Expand cuts windows into slots of the given duration starting within
+// [from, to).
+func Expand(windows []Slot, from, to time.Time, duration time.Duration) ([]Slot, error) {
+	if duration <= 0 {
+		return nil, fmt.Errorf("duration must be positive")
+	}
+
+	slots := []Slot{}
+	for _, w := range windows {
+		for t := w.Start; !t.Add(duration).After(w.End); t = t.Add(duration) {
+			if t.Before(from) || !t.Before(to) {
+				continue
+			}
+			slots = append(slots, Slot{Start: t.UTC(), End: t.Add(duration).UTC()})
+		}
+	}
+
+	sort.Slice(slots, func(i, j int) bool {
+		return slots[i].Start.Before(slots[j].Start)
+	})
+
+	return dedupe(slots), nil
+}
+
+// ValidateRules checks every rule is well formed and that no two rules
+// overlap. Rules are compared as the instants their windows fall on over the
+// coming year, so rules in different timezones clash too, even if only on
+// one side of a DST change.
+func ValidateRules(rules []*store.BookingSlot) error {
+	type window struct {
+		Slot
+		loc *time.Location
+	}
+
+	from := time.Now().UTC().Truncate(24 * time.Hour)
+	to := from.AddDate(1, 0, 7)
+
+	windows := []window{}
+	for _, rule := range rules {
+		loc, err := Location(rule.Timezone)
+		if err != nil {
+			return err
+		}
+
+		start, end, err := ruleClock(rule)
+		if err != nil {
+			return err
+		}
+		if end-start >= 24*time.Hour {
+			return ErrInvalidSlot
+		}
+
+		slots, err := ruleWindows(rule, from, to, nil)
+		if err != nil {
+			return err
+		}
+		for _, slot := range slots {
+			windows = append(windows, window{slot, loc})
+		}
+	}
+
+	sort.Slice(windows, func(i, j int) bool {
+		return windows[i].Start.Before(windows[j].Start)
+	})
+
+	// a rule's own windows never overlap, so any overlap is between two
+	var latest time.Time
+	for _, w := range windows {
+		if w.Start.Before(latest) {
+			return fmt.Errorf("%w on %s", ErrOverlappingSlots, w.Start.In(w.loc).Weekday())
+		}
+		if w.End.After(latest) {
+			latest = w.End
+		}
+	}
+
+	return nil
+}
+
+// Subtract drops every slot that overlaps a busy interval.
+func Subtract(slots, busy []Slot) []Slot {
+	free := []Slot{}
+	for _, slot := range slots {
+		taken := false
+		for _, b := range busy {
+			if slot.overlaps(b) {
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			free = append(free, slot)
+		}
+	}
+	return free
+}
+
+// ParseClock converts a "9:30" + "AM" pair into the offset from midnight.
+func ParseClock(clock, period string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(clock), ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidTime, clock)
+	}
+
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil || hour < 1 || hour > 12 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidTime, clock)
+	}
+	minute, err := strconv.Atoi(parts[1])
+	if err != nil || minute < 0 || minute > 59 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidTime, clock)
+	}
+
+	switch strings.ToUpper(period) {
+	case "AM":
+		if hour == 12 {
+			hour = 0
+		}
+	case "PM":
+		if hour != 12 {
+			hour += 12
+		}
+	default:
+		return 0, fmt.Errorf("%w: period %q", ErrInvalidTime, period)
+	}
+
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute, nil
+}
+
+// ParseLocal reads a "02 Jan 2006" date with a "9:30" + "AM" clock as wall
+// time in loc.
+func ParseLocal(date, clock, period string, loc *time.Location) (time.Time, error) {
+	day, err := time.ParseInLocation(store.MeetingDateLayout, date, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q", ErrInvalidTime, date)
+	}
+
+	offset, err := ParseClock(clock, period)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return atClock(day, offset), nil
+}
+
+// OnDay places a "9:30" + "AM" clock range on the date of day, in day's
+// location.
+func OnDay(day time.Time, startClock, startPeriod, endClock, endPeriod string) (Slot, error) {
+	start, err := ParseClock(startClock, startPeriod)
+	if err != nil {
+		return Slot{}, err
+	}
+	end, err := ParseClock(endClock, endPeriod)
+	if err != nil {
+		return Slot{}, err
+	}
+	if end <= start {
+		return Slot{}, ErrInvalidSlot
+	}
+
+	return Slot{Start: atClock(day, start), End: atClock(day, end)}, nil
+}
+
+// Location resolves an IANA name, treating an empty name as UTC.
+func Location(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// ruleClock is when a rule's window starts and ends as offsets from the
+// midnight of its day. A window ending at or before its start runs overnight,
+// ending the next day.
+func ruleClock(rule *store.BookingSlot) (start, end time.Duration, err error) {
+	start, err = ParseClock(rule.StartTime, rule.StartPeriod)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err = ParseClock(rule.EndTime, rule.EndPeriod)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end <= start {
+		end += 24 * time.Hour
+	}
+	return start, end, nil
+}
+
+// ruleWindows returns the concrete windows of a weekly rule for every day in
+// the rule's timezone that touches [from, to), skipping days off. A day off
+// drops the overnight window starting on it.
+func ruleWindows(rule *store.BookingSlot, from, to time.Time, daysOff map[string]bool) ([]Slot, error) {
+	loc, err := Location(rule.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end, err := ruleClock(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	// the day before may run overnight into the range
+	first := from.In(loc)
+	first = time.Date(first.Year(), first.Month(), first.Day()-1, 0, 0, 0, 0, loc)
+	last := to.In(loc)
+
+	windows := []Slot{}
+	for day := first; day.Before(last); day = day.AddDate(0, 0, 1) {
+		if !hasDay(rule.Days, day.Weekday()) || daysOff[day.Format(store.HolidayDateLayout)] {
+			continue
+		}
+		windows = append(windows, Slot{Start: atClock(day, start), End: atClock(day, end)})
+	}
+
+	return windows, nil
+}
+
+// atClock places a wall-clock offset on day, letting time.Date deal with DST
+// and offsets past midnight.
+func atClock(day time.Time, offset time.Duration) time.Time {
+	hour := int(offset / time.Hour)
+	minute := int(offset % time.Hour / time.Minute)
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+}
+
+func hasDay(days []string, weekday time.Weekday) bool {
+	for _, d := range days {
+		if strings.EqualFold(d, weekday.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+func dedupe(slots []Slot) []Slot {
+	out := slots[:0]
+	for i, slot := range slots {
+		if i > 0 && slot.Start.Equal(out[len(out)-1].Start) {
+			continue
+		}
+		out = append(out, slot)
+	}
+	return out
+}
@@ -0,0 +1,144 @@
+package availability
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Althaf66/Appointr/internal/store"
+)
+
+func rule(timezone, start, startPeriod, end, endPeriod string, days ...string) *store.BookingSlot {
+	return &store.BookingSlot{
+		Days:        days,
+		StartTime:   start,
+		StartPeriod: startPeriod,
+		EndTime:     end,
+		EndPeriod:   endPeriod,
+		Timezone:    timezone,
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []*store.BookingSlot
+		wantErr error
+	}{
+		{
+			name: "apart on the same day",
+			rules: []*store.BookingSlot{
+				rule("UTC", "9:00", "AM", "12:00", "PM", "Monday"),
+				rule("UTC", "1:00", "PM", "5:00", "PM", "Monday"),
+			},
+		},
+		{
+			name: "overlapping in one timezone",
+			rules: []*store.BookingSlot{
+				rule("UTC", "9:00", "AM", "12:00", "PM", "Monday"),
+				rule("UTC", "11:00", "AM", "1:00", "PM", "Monday", "Tuesday"),
+			},
+			wantErr: ErrOverlappingSlots,
+		},
+		{
+			name: "overlapping across timezones",
+			rules: []*store.BookingSlot{
+				rule("Asia/Kolkata", "9:00", "AM", "11:00", "AM", "Monday"),
+				rule("UTC", "4:00", "AM", "5:00", "AM", "Monday"),
+			},
+			wantErr: ErrOverlappingSlots,
+		},
+		{
+			name: "same clock in different timezones",
+			rules: []*store.BookingSlot{
+				rule("Asia/Kolkata", "9:00", "AM", "11:00", "AM", "Monday"),
+				rule("UTC", "9:00", "AM", "11:00", "AM", "Monday"),
+			},
+		},
+		{
+			name: "overlapping across timezones on different days",
+			rules: []*store.BookingSlot{
+				rule("Asia/Tokyo", "7:00", "AM", "9:00", "AM", "Tuesday"),
+				rule("UTC", "10:00", "PM", "11:00", "PM", "Monday"),
+			},
+			wantErr: ErrOverlappingSlots,
+		},
+		{
+			name: "overlapping only in winter",
+			rules: []*store.BookingSlot{
+				rule("America/New_York", "9:00", "AM", "10:00", "AM", "Monday"),
+				rule("UTC", "2:00", "PM", "3:00", "PM", "Monday"),
+			},
+			wantErr: ErrOverlappingSlots,
+		},
+		{
+			name: "overnight",
+			rules: []*store.BookingSlot{
+				rule("UTC", "10:00", "PM", "2:00", "AM", "Friday"),
+				rule("UTC", "3:00", "AM", "6:00", "AM", "Saturday"),
+			},
+		},
+		{
+			name: "overnight into the next day's window",
+			rules: []*store.BookingSlot{
+				rule("UTC", "10:00", "PM", "2:00", "AM", "Friday"),
+				rule("UTC", "1:00", "AM", "6:00", "AM", "Saturday"),
+			},
+			wantErr: ErrOverlappingSlots,
+		},
+		{
+			name: "overnight on consecutive days",
+			rules: []*store.BookingSlot{
+				rule("UTC", "8:00", "PM", "7:00", "PM", "Monday", "Tuesday"),
+			},
+		},
+		{
+			name: "starting and ending at once",
+			rules: []*store.BookingSlot{
+				rule("UTC", "9:00", "AM", "9:00", "AM", "Monday"),
+			},
+			wantErr: ErrInvalidSlot,
+		},
+		{
+			name: "malformed clock",
+			rules: []*store.BookingSlot{
+				rule("UTC", "13:00", "PM", "2:00", "PM", "Monday"),
+			},
+			wantErr: ErrInvalidTime,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateRules(tt.rules); !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRuleWindowsOvernight(t *testing.T) {
+	r := rule("UTC", "10:00", "PM", "2:00", "AM", "Friday")
+	// a Saturday, which the Friday night runs into
+	from := time.Date(2026, time.October, 17, 0, 0, 0, 0, time.UTC)
+
+	windows, err := ruleWindows(r, from, from.Add(24*time.Hour), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Slot{
+		Start: time.Date(2026, time.October, 16, 22, 0, 0, 0, time.UTC),
+		End:   time.Date(2026, time.October, 17, 2, 0, 0, 0, time.UTC),
+	}
+	if len(windows) != 1 || !windows[0].Start.Equal(want.Start) || !windows[0].End.Equal(want.End) {
+		t.Fatalf("got %v, want [%v]", windows, want)
+	}
+
+	slots, err := Expand(windows, from, from.Add(24*time.Hour), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slots) != 2 || !slots[0].Start.Equal(from) {
+		t.Errorf("got %v, want the two hours after midnight", slots)
+	}
+}
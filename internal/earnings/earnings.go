@@ -0,0 +1,203 @@
+package earnings
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Althaf66/Appointr/internal/store"
+)
+
+const (
+	dayLayout   = "2006-01-02"
+	monthLayout = "2006-01"
+)
+
+// Totals adds up a mentor's earnings in one currency, in its minor unit.
+// Fee is the platform's commission and Net what is left for the mentor.
+// Pending is net earned on sessions that haven't happened yet, or that were
+// cancelled or missed and still await their refund; the rest, package sales
+// included, is Available.
+type Totals struct {
+	Period    string `json:"period,omitempty"`
+	Currency  string `json:"currency"`
+	Meetings  int    `json:"meetings"`
+	Packages  int    `json:"packages"`
+	Gross     int64  `json:"gross"`
+	Fee       int64  `json:"fee"`
+	Net       int64  `json:"net"`
+	Pending   int64  `json:"pending"`
+	Available int64  `json:"available"`
+}
+
+func (t *Totals) add(item *store.EarningItem) {
+	fee := int64(math.Round(float64(item.Gross) * item.CommissionRate))
+	net := item.Gross - fee
+
+	if item.PurchaseID != 0 {
+		t.Packages++
+	} else {
+		t.Meetings++
+	}
+	t.Gross += item.Gross
+	t.Fee += fee
+	t.Net += net
+	if isPending(item) {
+		t.Pending += net
+	} else {
+		t.Available += net
+	}
+}
+
+// Report is a mentor's earnings over a range, per currency, broken down by
+// day and by month.
+type Report struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Totals  []*Totals `json:"totals"`
+	Daily   []*Totals `json:"daily"`
+	Monthly []*Totals `json:"monthly"`
+}
+
+// Balance is what a mentor can still ask to be paid out in one currency.
+type Balance struct {
+	Currency string `json:"currency"`
+	// Available is all the net earnings that are no longer pending
+	Available int64 `json:"available"`
+	Requested int64 `json:"requested"`
+	PaidOut   int64 `json:"paid_out"`
+	// Withdrawable is Available less the payouts requested or paid out
+	Withdrawable int64 `json:"withdrawable"`
+}
+
+type Service struct {
+	store store.Storage
+}
+
+func NewService(store store.Storage) *Service {
+	return &Service{store: store}
+}
+
+// Report adds up what the mentor earned from payments taken in [from, to),
+// grouping days and months in loc.
+func (s *Service) Report(ctx context.Context, mentorUserID int64, from, to time.Time, loc *time.Location) (*Report, error) {
+	items, err := s.store.Payments.GetEarningItems(ctx, mentorUserID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := groups{}
+	daily := groups{}
+	monthly := groups{}
+	for _, item := range items {
+		paidAt := item.PaidAt.In(loc)
+		totals.get("", item.Currency).add(item)
+		daily.get(paidAt.Format(dayLayout), item.Currency).add(item)
+		monthly.get(paidAt.Format(monthLayout), item.Currency).add(item)
+	}
+
+	return &Report{
+		From:    from.In(loc),
+		To:      to.In(loc),
+		Totals:  totals.list(),
+		Daily:   daily.list(),
+		Monthly: monthly.list(),
+	}, nil
+}
+
+// Balances works out, per currency, what the mentor has earned and not yet
+// requested as a payout.
+func (s *Service) Balances(ctx context.Context, mentorUserID int64) ([]*Balance, error) {
+	items, err := s.store.Payments.GetEarningItems(ctx, mentorUserID, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	payouts, err := s.store.Payouts.GetPayoutsByMentorID(ctx, mentorUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	byCurrency := map[string]*Balance{}
+	balance := func(currency string) *Balance {
+		b, ok := byCurrency[currency]
+		if !ok {
+			b = &Balance{Currency: currency}
+			byCurrency[currency] = b
+		}
+		return b
+	}
+
+	for _, item := range items {
+		t := &Totals{}
+		t.add(item)
+		balance(item.Currency).Available += t.Available
+	}
+	for _, p := range payouts {
+		switch p.Status {
+		case store.PayoutRequested:
+			balance(p.Currency).Requested += p.Amount
+		case store.PayoutApproved:
+			balance(p.Currency).PaidOut += p.Amount
+		}
+	}
+
+	balances := make([]*Balance, 0, len(byCurrency))
+	for _, b := range byCurrency {
+		b.Withdrawable = max(b.Available-b.Requested-b.PaidOut, 0)
+		balances = append(balances, b)
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		return balances[i].Currency < balances[j].Currency
+	})
+
+	return balances, nil
+}
+
+// isPending reports whether money paid for item's meeting is still waiting
+// on the session to take place or, for a meeting cancelled or missed, on
+// the refund that settles what is owed back to the mentee.
+func isPending(item *store.EarningItem) bool {
+	switch item.MeetingStatus {
+	case store.MeetingPaid, store.MeetingInProgress:
+		return true
+	case store.MeetingCancelled, store.MeetingNoShow:
+		return !item.Refunded
+	default:
+		return false
+	}
+}
+
+type groupKey struct {
+	period   string
+	currency string
+}
+
+// groups collects Totals keyed by period and currency.
+type groups map[groupKey]*Totals
+
+func (g groups) get(period, currency string) *Totals {
+	key := groupKey{period, currency}
+	t, ok := g[key]
+	if !ok {
+		t = &Totals{Period: period, Currency: currency}
+		g[key] = t
+	}
+	return t
+}
+
+// list returns the totals ordered by period, then currency.
+func (g groups) list() []*Totals {
+	list := make([]*Totals, 0, len(g))
+	for _, t := range g {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Period != list[j].Period {
+			return list[i].Period < list[j].Period
+		}
+		return list[i].Currency < list[j].Currency
+	})
+	return list
+}
@@ -0,0 +1,72 @@
+package earnings
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Althaf66/Appointr/internal/store"
+)
+
+type testPayments struct {
+	*store.PaymentStore
+	items []*store.EarningItem
+}
+
+func (p *testPayments) GetEarningItems(ctx context.Context, mentorID int64, from, to time.Time) ([]*store.EarningItem, error) {
+	return p.items, nil
+}
+
+type testPayouts struct {
+	*store.PayoutStore
+}
+
+func (p *testPayouts) GetPayoutsByMentorID(ctx context.Context, mentorID int64) ([]*store.Payout, error) {
+	return []*store.Payout{}, nil
+}
+
+func TestBalances(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  store.MeetingStatus
+		refunds bool
+		// gross is what is left of the payment after refunds
+		gross            int64
+		wantWithdrawable int64
+	}{
+		{name: "paid, not yet held", status: store.MeetingPaid, gross: 10000},
+		{name: "in progress", status: store.MeetingInProgress, gross: 10000},
+		{name: "completed", status: store.MeetingCompleted, gross: 10000, wantWithdrawable: 9000},
+		{name: "paid then cancelled", status: store.MeetingCancelled, gross: 10000},
+		{name: "paid then missed", status: store.MeetingNoShow, gross: 10000},
+		{name: "cancelled late and refunded in part", status: store.MeetingCancelled, refunds: true, gross: 5000, wantWithdrawable: 4500},
+		{name: "missed and refunded in part", status: store.MeetingNoShow, refunds: true, gross: 5000, wantWithdrawable: 4500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payments := &testPayments{items: []*store.EarningItem{{
+				PaymentID:      1,
+				MeetingID:      1,
+				MeetingStatus:  tt.status,
+				Refunded:       tt.refunds,
+				Currency:       "usd",
+				Gross:          tt.gross,
+				CommissionRate: 0.1,
+				PaidAt:         time.Now(),
+			}}}
+			service := NewService(store.Storage{Payments: payments, Payouts: &testPayouts{}})
+
+			balances, err := service.Balances(context.Background(), 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(balances) != 1 {
+				t.Fatalf("got %d balances, want 1", len(balances))
+			}
+			if got := balances[0].Withdrawable; got != tt.wantWithdrawable {
+				t.Errorf("withdrawable %d, want %d", got, tt.wantWithdrawable)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package invoice
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+
+	"github.com/Althaf66/Appointr/internal/payments"
+	"github.com/Althaf66/Appointr/internal/store"
+)
+
+const dateLayout = "02 Jan 2006"
+
+//go:embed "templates"
+var fs embed.FS
+
+var htmlTemplate = template.Must(template.ParseFS(fs, "templates/invoice.tmpl"))
+
+type line struct {
+	Name   string
+	Amount string
+}
+
+// view is an invoice with its amounts and dates formatted for display.
+type view struct {
+	Number      string
+	IssuedAt    string
+	PaidAt      string
+	MentorName  string
+	MentorEmail string
+	MenteeName  string
+	MenteeEmail string
+	Lines       []line
+	Total       string
+}
+
+func newView(inv *store.Invoice) view {
+	v := view{
+		Number:      inv.Number,
+		IssuedAt:    inv.IssuedAt.UTC().Format(dateLayout),
+		PaidAt:      inv.PaidAt.UTC().Format(dateLayout),
+		MentorName:  inv.MentorName,
+		MentorEmail: inv.MentorEmail,
+		MenteeName:  inv.MenteeName,
+		MenteeEmail: inv.MenteeEmail,
+		Lines: []line{
+			{Name: inv.Description, Amount: payments.FormatMinor(inv.Subtotal, inv.Currency)},
+		},
+		Total: payments.FormatMinor(inv.Total, inv.Currency),
+	}
+	if inv.Fee > 0 {
+		v.Lines = append(v.Lines, line{Name: "Platform fee", Amount: payments.FormatMinor(inv.Fee, inv.Currency)})
+	}
+	if inv.Tax > 0 {
+		v.Lines = append(v.Lines, line{Name: "Tax", Amount: payments.FormatMinor(inv.Tax, inv.Currency)})
+	}
+	return v
+}
+
+// HTML renders the invoice as a standalone web page.
+func HTML(inv *store.Invoice) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := htmlTemplate.Execute(buf, newView(inv)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// PDF renders the invoice as a one page A4 document.
+func PDF(inv *store.Invoice) []byte {
+	v := newView(inv)
+
+	doc := &pdfPage{}
+	doc.text(50, 780, 24, true, "Invoice")
+	doc.text(50, 756, 10, false, v.Number+"  -  issued "+v.IssuedAt+"  -  paid "+v.PaidAt)
+
+	doc.text(50, 710, 11, true, "Mentor")
+	doc.text(50, 694, 11, false, v.MentorName)
+	doc.text(50, 680, 10, false, v.MentorEmail)
+	doc.text(320, 710, 11, true, "Billed to")
+	doc.text(320, 694, 11, false, v.MenteeName)
+	doc.text(320, 680, 10, false, v.MenteeEmail)
+
+	y := 630.0
+	doc.rule(50, y+18, 545)
+	for _, l := range v.Lines {
+		doc.text(50, y, 11, false, l.Name)
+		doc.text(420, y, 11, false, l.Amount)
+		y -= 24
+		doc.rule(50, y+18, 545)
+	}
+	doc.text(50, y, 12, true, "Total paid")
+	doc.text(420, y, 12, true, v.Total)
+
+	doc.text(50, 80, 9, false, "Thank you for booking with Appointr.")
+
+	return doc.bytes()
+}
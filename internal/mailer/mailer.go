@@ -6,11 +6,24 @@ const (
 	FromName            = "Appointr"
 	maxRetires          = 3
 	UserWelcomeTemplate = "user_invitation.tmpl"
+
+	MeetingCancelledTemplate          = "meeting_cancelled.tmpl"
+	MeetingRescheduleProposedTemplate = "meeting_reschedule_proposed.tmpl"
+	MeetingRescheduledTemplate        = "meeting_rescheduled.tmpl"
+	MeetingRescheduleDeclinedTemplate = "meeting_reschedule_declined.tmpl"
+	PaymentInvoiceTemplate            = "payment_invoice.tmpl"
 )
 
 //go:embed "templates"
 var FS embed.FS
 
+// Attachment is a file sent along with an email.
+type Attachment struct {
+	Filename string
+	Data     []byte
+}
+
 type Client interface {
 	Send(templateFile, username, email string, data any, isSandbox bool) (int, error)
+	SendWithAttachments(templateFile, username, email string, data any, attachments []Attachment, isSandbox bool) (int, error)
 }
@@ -23,6 +23,10 @@ func NewMailTrapClient(apiKey, fromEmail string) (mailtrapClient, error) {
 	}, nil
 }
 func (m mailtrapClient) Send(templateFile, username, email string, data any, isSandbox bool) (int, error) {
+	return m.SendWithAttachments(templateFile, username, email, data, nil, isSandbox)
+}
+
+func (m mailtrapClient) SendWithAttachments(templateFile, username, email string, data any, attachments []Attachment, isSandbox bool) (int, error) {
 	// Template parsing and building
 	tmpl, err := template.ParseFS(FS, "templates/"+templateFile)
 	if err != nil {
@@ -43,6 +47,9 @@ func (m mailtrapClient) Send(templateFile, username, email string, data any, isS
 	message.SetHeader("To", email)
 	message.SetHeader("Subject", subject.String())
 	message.AddAlternative("text/html", body.String())
+	for _, a := range attachments {
+		message.AttachReader(a.Filename, bytes.NewReader(a.Data))
+	}
 
 	dialer := gomail.NewDialer("live.smtp.mailtrap.io", 587, "api", m.apiKey)
 	if err := dialer.DialAndSend(message); err != nil {
@@ -0,0 +1,194 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// FakeSignatureHeader carries the fake's webhook signature: the hex
+// HMAC-SHA256 of the body under the fake's secret.
+const FakeSignatureHeader = "X-Fake-Signature"
+
+// Fake is an in-memory Provider for tests and local development. Nothing
+// is paid until Pay is called; its event can be fed to the webhook with
+// Sign.
+type Fake struct {
+	mu        sync.Mutex
+	secret    string
+	seq       int
+	checkouts map[string]*Checkout
+	// refunds is keyed by idempotency key when the request had one
+	refunds  map[string]*Refund
+	refunded map[string]int64
+}
+
+func NewFake(secret string) *Fake {
+	return &Fake{
+		secret:    secret,
+		checkouts: map[string]*Checkout{},
+		refunds:   map[string]*Refund{},
+		refunded:  map[string]int64{},
+	}
+}
+
+func (f *Fake) Name() string {
+	return "fake"
+}
+
+// CreateCheckout sends the payer straight to the success URL.
+func (f *Fake) CreateCheckout(ctx context.Context, req CheckoutRequest) (*Checkout, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c := &Checkout{
+		SessionID: f.nextID("cs_fake"),
+		URL:       req.SuccessURL,
+		Status:    CheckoutOpen,
+		Amount:    req.Quote.Total,
+		Currency:  req.Quote.Currency,
+		Metadata:  req.Metadata,
+	}
+	f.checkouts[c.SessionID] = c
+
+	out := *c
+	return &out, nil
+}
+
+func (f *Fake) GetCheckout(ctx context.Context, sessionID string) (*Checkout, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.checkouts[sessionID]
+	if !ok {
+		return nil, fmt.Errorf("%w: checkout %s", ErrNotFound, sessionID)
+	}
+
+	out := *c
+	return &out, nil
+}
+
+func (f *Fake) ExpireCheckout(ctx context.Context, sessionID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.checkouts[sessionID]
+	if !ok {
+		return fmt.Errorf("%w: checkout %s", ErrNotFound, sessionID)
+	}
+	if c.Status != CheckoutOpen {
+		return fmt.Errorf("%w: checkout %s is %s", ErrRejected, sessionID, c.Status)
+	}
+
+	c.Status = CheckoutExpired
+	return nil
+}
+
+func (f *Fake) Refund(ctx context.Context, req RefundRequest) (*Refund, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if re, ok := f.refunds[req.IdempotencyKey]; ok && req.IdempotencyKey != "" {
+		out := *re
+		return &out, nil
+	}
+
+	c := f.checkoutByIntent(req.PaymentIntentID)
+	if c == nil || c.Status != CheckoutPaid {
+		return nil, fmt.Errorf("%w: payment %s was not paid", ErrRejected, req.PaymentIntentID)
+	}
+	if req.Amount <= 0 || f.refunded[req.PaymentIntentID]+req.Amount > c.Amount {
+		return nil, fmt.Errorf("%w: refund exceeds what is left of the payment", ErrRejected)
+	}
+
+	re := &Refund{ID: f.nextID("re_fake"), Amount: req.Amount, Currency: c.Currency}
+	f.refunded[req.PaymentIntentID] += req.Amount
+	if req.IdempotencyKey != "" {
+		f.refunds[req.IdempotencyKey] = re
+	}
+
+	out := *re
+	return &out, nil
+}
+
+func (f *Fake) VerifyWebhook(payload []byte, header http.Header) (*Event, error) {
+	got, err := hex.DecodeString(header.Get(FakeSignatureHeader))
+	if err != nil || !hmac.Equal(got, f.mac(payload)) {
+		return nil, ErrInvalidSignature
+	}
+
+	return f.ParseEvent(payload)
+}
+
+func (f *Fake) ParseEvent(payload []byte) (*Event, error) {
+	var e Event
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Pay completes an open checkout and returns the event the provider would
+// send.
+func (f *Fake) Pay(sessionID string) (*Event, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.checkouts[sessionID]
+	if !ok {
+		return nil, fmt.Errorf("%w: checkout %s", ErrNotFound, sessionID)
+	}
+	if c.Status != CheckoutOpen {
+		return nil, fmt.Errorf("%w: checkout %s is %s", ErrRejected, sessionID, c.Status)
+	}
+
+	c.Status = CheckoutPaid
+	c.PaymentIntentID = f.nextID("pi_fake")
+
+	return &Event{
+		ID:              f.nextID("evt_fake"),
+		Type:            EventCheckoutCompleted,
+		SessionID:       c.SessionID,
+		PaymentIntentID: c.PaymentIntentID,
+		Metadata:        c.Metadata,
+		Amount:          c.Amount,
+		Currency:        c.Currency,
+	}, nil
+}
+
+// Sign encodes event as a webhook delivery VerifyWebhook accepts.
+func (f *Fake) Sign(event *Event) ([]byte, http.Header, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header := http.Header{}
+	header.Set(FakeSignatureHeader, hex.EncodeToString(f.mac(payload)))
+	return payload, header, nil
+}
+
+func (f *Fake) mac(payload []byte) []byte {
+	h := hmac.New(sha256.New, []byte(f.secret))
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+func (f *Fake) checkoutByIntent(paymentIntentID string) *Checkout {
+	for _, c := range f.checkouts {
+		if c.PaymentIntentID == paymentIntentID {
+			return c
+		}
+	}
+	return nil
+}
+
+func (f *Fake) nextID(prefix string) string {
+	f.seq++
+	return fmt.Sprintf("%s_%d", prefix, f.seq)
+}
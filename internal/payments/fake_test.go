@@ -0,0 +1,169 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFakeCheckoutWebhookRefund(t *testing.T) {
+	tests := []struct {
+		name string
+		// expire closes the checkout before it is paid
+		expire  bool
+		refunds []int64
+		// tamper changes the webhook body after it was signed
+		tamper     bool
+		wantPayErr error
+		wantHook   error
+		wantErrs   []error
+		wantLeft   int64
+	}{
+		{
+			name:     "full refund",
+			refunds:  []int64{5000},
+			wantErrs: []error{nil},
+		},
+		{
+			name:     "partial refunds up to the total",
+			refunds:  []int64{2000, 3000},
+			wantErrs: []error{nil, nil},
+		},
+		{
+			name:     "refund past what was paid",
+			refunds:  []int64{4000, 2000},
+			wantErrs: []error{nil, ErrRejected},
+			wantLeft: 1000,
+		},
+		{
+			name:     "refund of nothing",
+			refunds:  []int64{0},
+			wantErrs: []error{ErrRejected},
+			wantLeft: 5000,
+		},
+		{
+			name:       "expired checkout",
+			expire:     true,
+			wantPayErr: ErrRejected,
+		},
+		{
+			name:     "tampered webhook",
+			tamper:   true,
+			wantHook: ErrInvalidSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fake := NewFake("secret")
+
+			checkout, err := fake.CreateCheckout(ctx, CheckoutRequest{
+				Quote:      Quote{Total: 5000, Currency: "usd"},
+				SuccessURL: "http://localhost/success",
+				Metadata:   map[string]string{"meetingid": "10"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if checkout.Status != CheckoutOpen || checkout.URL != "http://localhost/success" {
+				t.Fatalf("got checkout %+v, want an open one sending the payer to the success URL", checkout)
+			}
+
+			if tt.expire {
+				if err := fake.ExpireCheckout(ctx, checkout.SessionID); err != nil {
+					t.Fatal(err)
+				}
+				if err := fake.ExpireCheckout(ctx, checkout.SessionID); !errors.Is(err, ErrRejected) {
+					t.Errorf("expiring twice: got %v, want %v", err, ErrRejected)
+				}
+			}
+
+			paid, err := fake.Pay(checkout.SessionID)
+			if !errors.Is(err, tt.wantPayErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantPayErr)
+			}
+			if err != nil {
+				return
+			}
+
+			payload, header, err := fake.Sign(paid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.tamper {
+				payload = append(payload[:len(payload)-1], ',', '}')
+			}
+
+			event, err := fake.VerifyWebhook(payload, header)
+			if !errors.Is(err, tt.wantHook) {
+				t.Fatalf("got %v, want %v", err, tt.wantHook)
+			}
+			if err != nil {
+				return
+			}
+			if event.Type != EventCheckoutCompleted || event.SessionID != checkout.SessionID ||
+				event.PaymentIntentID == "" || event.Amount != 5000 || event.Metadata["meetingid"] != "10" {
+				t.Fatalf("got event %+v, want the completed checkout", event)
+			}
+
+			got, err := fake.GetCheckout(ctx, checkout.SessionID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != CheckoutPaid || got.PaymentIntentID != event.PaymentIntentID {
+				t.Fatalf("got checkout %+v, want it paid by %s", got, event.PaymentIntentID)
+			}
+
+			left := int64(5000)
+			for i, amount := range tt.refunds {
+				re, err := fake.Refund(ctx, RefundRequest{PaymentIntentID: event.PaymentIntentID, Amount: amount})
+				if !errors.Is(err, tt.wantErrs[i]) {
+					t.Fatalf("refund %d: got %v, want %v", i, err, tt.wantErrs[i])
+				}
+				if err != nil {
+					continue
+				}
+				if re.Amount != amount || re.Currency != "usd" || re.ID == "" {
+					t.Errorf("refund %d: got %+v, want %d usd", i, re, amount)
+				}
+				left -= amount
+			}
+			if left != tt.wantLeft {
+				t.Errorf("%d left of the payment, want %d", left, tt.wantLeft)
+			}
+		})
+	}
+}
+
+func TestFakeRefundIdempotency(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake("secret")
+
+	checkout, err := fake.CreateCheckout(ctx, CheckoutRequest{Quote: Quote{Total: 5000, Currency: "usd"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event, err := fake.Pay(checkout.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := RefundRequest{PaymentIntentID: event.PaymentIntentID, Amount: 3000, IdempotencyKey: "refund-1"}
+	first, err := fake.Refund(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := fake.Refund(ctx, req)
+	if err != nil {
+		t.Fatalf("retrying a refund: %v", err)
+	}
+	if again.ID != first.ID {
+		t.Errorf("retry made refund %s, want %s back", again.ID, first.ID)
+	}
+
+	// a second 3000 would exceed the payment had the retry refunded again
+	if _, err := fake.Refund(ctx, RefundRequest{PaymentIntentID: event.PaymentIntentID, Amount: 2000}); err != nil {
+		t.Errorf("refunding the rest: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrRejected wraps a request the provider turned down, as opposed to
+	// one it failed to process
+	ErrRejected = errors.New("payment provider rejected the request")
+	ErrNotFound = errors.New("payment not found")
+)
+
+// Provider takes payments for meetings. Amounts are in the currency's minor
+// unit throughout.
+type Provider interface {
+	// Name is stored on payments taken through the provider
+	Name() string
+	CreateCheckout(ctx context.Context, req CheckoutRequest) (*Checkout, error)
+	GetCheckout(ctx context.Context, sessionID string) (*Checkout, error)
+	// ExpireCheckout closes an open checkout so it can no longer be paid.
+	// A checkout that isn't open any more is rejected.
+	ExpireCheckout(ctx context.Context, sessionID string) error
+	// VerifyWebhook authenticates a webhook delivery and decodes its event
+	VerifyWebhook(payload []byte, header http.Header) (*Event, error)
+	// ParseEvent decodes a delivery that was verified before, for replays
+	ParseEvent(payload []byte) (*Event, error)
+	Refund(ctx context.Context, req RefundRequest) (*Refund, error)
+}
+
+type CheckoutRequest struct {
+	Quote            Quote
+	CustomerEmail    string
+	AllowedCountries []string
+	SuccessURL       string
+	CancelURL        string
+	Metadata         map[string]string
+}
+
+type CheckoutStatus string
+
+const (
+	CheckoutOpen    CheckoutStatus = "open"
+	CheckoutPaid    CheckoutStatus = "paid"
+	CheckoutExpired CheckoutStatus = "expired"
+)
+
+// Checkout is a hosted payment page for one quote.
+type Checkout struct {
+	SessionID       string
+	URL             string
+	Status          CheckoutStatus
+	PaymentIntentID string
+	Amount          int64
+	Currency        string
+	Metadata        map[string]string
+}
+
+type RefundRequest struct {
+	PaymentIntentID string
+	Amount          int64
+	// IdempotencyKey makes a repeated request return the first refund
+	IdempotencyKey string
+	Metadata       map[string]string
+}
+
+type Refund struct {
+	ID       string
+	Amount   int64
+	Currency string
+}
+
+type EventType string
+
+const (
+	EventCheckoutCompleted EventType = "checkout.completed"
+	EventCheckoutExpired   EventType = "checkout.expired"
+	EventCheckoutFailed    EventType = "checkout.failed"
+	EventChargeRefunded    EventType = "charge.refunded"
+	EventDisputeCreated    EventType = "dispute.created"
+)
+
+// Event is a webhook event reduced to what the app acts on. Types the app
+// doesn't handle keep the provider's own name. EventCheckoutCompleted is
+// only sent once the checkout's money has come in, and EventCheckoutFailed
+// when a delayed payment method, like a bank debit, fails after checkout.
+type Event struct {
+	ID              string            `json:"id"`
+	Type            EventType         `json:"type"`
+	SessionID       string            `json:"session_id,omitempty"`
+	PaymentIntentID string            `json:"payment_intent_id,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	// Amount is what was paid on checkout.completed and the total refunded
+	// so far on charge.refunded
+	Amount   int64  `json:"amount,omitempty"`
+	Currency string `json:"currency,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
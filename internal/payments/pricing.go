@@ -0,0 +1,96 @@
+package payments
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Pricing holds the charges the server adds on top of a session's price.
+type Pricing struct {
+	// FeeRate is the platform fee as a share of the session price
+	FeeRate float64
+	// TaxRate applies to the session price plus the platform fee
+	TaxRate float64
+}
+
+// LineItem is one line of a checkout, in the currency's minor unit.
+type LineItem struct {
+	Name   string `json:"name"`
+	Amount int64  `json:"amount"`
+}
+
+// Quote is everything a mentee is charged for one session. Price, Fee and
+// Tax break Total down into the mentor's own price and what is added on top.
+type Quote struct {
+	Currency string     `json:"currency"`
+	Items    []LineItem `json:"items"`
+	Price    int64      `json:"price"`
+	Fee      int64      `json:"fee"`
+	Tax      int64      `json:"tax"`
+	Total    int64      `json:"total"`
+}
+
+// Quote prices a session called name costing price (in major units).
+// Fee and tax lines are only added when non-zero.
+func (p Pricing) Quote(name string, price float64, currency string) Quote {
+	q := Quote{Currency: strings.ToLower(currency)}
+
+	base := ToMinor(price, q.Currency)
+	q.Price = base
+	q.add(name, base)
+
+	q.Fee = roundShare(base, p.FeeRate)
+	if q.Fee > 0 {
+		q.add("Platform fee", q.Fee)
+	}
+
+	q.Tax = roundShare(base+q.Fee, p.TaxRate)
+	if q.Tax > 0 {
+		q.add("Tax", q.Tax)
+	}
+
+	return q
+}
+
+func (q *Quote) add(name string, amount int64) {
+	q.Items = append(q.Items, LineItem{Name: name, Amount: amount})
+	q.Total += amount
+}
+
+// zeroDecimal lists the currencies Stripe takes in whole units.
+var zeroDecimal = map[string]bool{
+	"bif": true, "clp": true, "djf": true, "gnf": true, "jpy": true, "kmf": true,
+	"krw": true, "mga": true, "pyg": true, "rwf": true, "ugx": true, "vnd": true,
+	"vuv": true, "xaf": true, "xof": true, "xpf": true,
+}
+
+// ToMinor converts a major-unit amount, like rupees, to the currency's minor
+// unit, like paise.
+func ToMinor(amount float64, currency string) int64 {
+	if zeroDecimal[strings.ToLower(currency)] {
+		return int64(math.Round(amount))
+	}
+	return int64(math.Round(amount * 100))
+}
+
+// FormatMinor renders a minor-unit amount in major units with the currency
+// code, like "INR 1250.00".
+func FormatMinor(amount int64, currency string) string {
+	code := strings.ToUpper(currency)
+	if zeroDecimal[strings.ToLower(currency)] {
+		return fmt.Sprintf("%s %d", code, amount)
+	}
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	return fmt.Sprintf("%s %s%d.%02d", code, sign, amount/100, amount%100)
+}
+
+func roundShare(amount int64, rate float64) int64 {
+	if rate <= 0 {
+		return 0
+	}
+	return int64(math.Round(float64(amount) * rate))
+}
@@ -0,0 +1,226 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/stripe/stripe-go/v75"
+	"github.com/stripe/stripe-go/v75/checkout/session"
+	"github.com/stripe/stripe-go/v75/refund"
+	"github.com/stripe/stripe-go/v75/webhook"
+)
+
+type StripeProvider struct {
+	sessions      session.Client
+	refunds       refund.Client
+	webhookSecret string
+}
+
+// NewStripeProvider talks to the Stripe API, or to apiURL when it is set,
+// like a local stripe-mock.
+func NewStripeProvider(key, webhookSecret, apiURL string) *StripeProvider {
+	backend := stripe.GetBackend(stripe.APIBackend)
+	if apiURL != "" {
+		backend = stripe.GetBackendWithConfig(stripe.APIBackend, &stripe.BackendConfig{
+			URL: stripe.String(apiURL),
+		})
+	}
+
+	return &StripeProvider{
+		sessions:      session.Client{B: backend, Key: key},
+		refunds:       refund.Client{B: backend, Key: key},
+		webhookSecret: webhookSecret,
+	}
+}
+
+func (p *StripeProvider) Name() string {
+	return "stripe"
+}
+
+func (p *StripeProvider) CreateCheckout(ctx context.Context, req CheckoutRequest) (*Checkout, error) {
+	lineItems := make([]*stripe.CheckoutSessionLineItemParams, 0, len(req.Quote.Items))
+	for _, item := range req.Quote.Items {
+		lineItems = append(lineItems, &stripe.CheckoutSessionLineItemParams{
+			PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+				Currency: stripe.String(req.Quote.Currency),
+				ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+					Name: stripe.String(item.Name),
+				},
+				UnitAmount: stripe.Int64(item.Amount),
+			},
+			Quantity: stripe.Int64(1),
+		})
+	}
+
+	params := &stripe.CheckoutSessionParams{
+		CustomerEmail:            stripe.String(req.CustomerEmail),
+		SubmitType:               stripe.String("book"),
+		BillingAddressCollection: stripe.String("auto"),
+		ShippingAddressCollection: &stripe.CheckoutSessionShippingAddressCollectionParams{
+			AllowedCountries: stripe.StringSlice(req.AllowedCountries),
+		},
+		LineItems:  lineItems,
+		Mode:       stripe.String(string(stripe.CheckoutSessionModePayment)),
+		SuccessURL: stripe.String(req.SuccessURL),
+		CancelURL:  stripe.String(req.CancelURL),
+		Metadata:   req.Metadata,
+	}
+	params.Context = ctx
+
+	s, err := p.sessions.New(params)
+	if err != nil {
+		return nil, stripeError(err)
+	}
+
+	return stripeCheckout(s), nil
+}
+
+func (p *StripeProvider) GetCheckout(ctx context.Context, sessionID string) (*Checkout, error) {
+	params := &stripe.CheckoutSessionParams{}
+	params.Context = ctx
+
+	s, err := p.sessions.Get(sessionID, params)
+	if err != nil {
+		return nil, stripeError(err)
+	}
+
+	return stripeCheckout(s), nil
+}
+
+func (p *StripeProvider) ExpireCheckout(ctx context.Context, sessionID string) error {
+	params := &stripe.CheckoutSessionExpireParams{}
+	params.Context = ctx
+
+	if _, err := p.sessions.Expire(sessionID, params); err != nil {
+		return stripeError(err)
+	}
+	return nil
+}
+
+func (p *StripeProvider) Refund(ctx context.Context, req RefundRequest) (*Refund, error) {
+	params := &stripe.RefundParams{
+		PaymentIntent: stripe.String(req.PaymentIntentID),
+		Amount:        stripe.Int64(req.Amount),
+		Reason:        stripe.String(string(stripe.RefundReasonRequestedByCustomer)),
+		Metadata:      req.Metadata,
+	}
+	params.Context = ctx
+	if req.IdempotencyKey != "" {
+		params.SetIdempotencyKey(req.IdempotencyKey)
+	}
+
+	re, err := p.refunds.New(params)
+	if err != nil {
+		return nil, stripeError(err)
+	}
+
+	return &Refund{ID: re.ID, Amount: re.Amount, Currency: string(re.Currency)}, nil
+}
+
+func (p *StripeProvider) VerifyWebhook(payload []byte, header http.Header) (*Event, error) {
+	_, err := webhook.ConstructEvent(payload, header.Get("Stripe-Signature"), p.webhookSecret)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	return p.ParseEvent(payload)
+}
+
+func (p *StripeProvider) ParseEvent(payload []byte) (*Event, error) {
+	var event stripe.Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+
+	e := &Event{ID: event.ID, Type: EventType(event.Type)}
+
+	switch event.Type {
+	case "checkout.session.completed", "checkout.session.async_payment_succeeded",
+		"checkout.session.async_payment_failed", "checkout.session.expired":
+		var s stripe.CheckoutSession
+		if err := json.Unmarshal(event.Data.Raw, &s); err != nil {
+			return nil, err
+		}
+		// a session completed with a delayed payment method keeps its
+		// type until async_payment_succeeded or async_payment_failed
+		switch {
+		case event.Type == "checkout.session.expired":
+			e.Type = EventCheckoutExpired
+		case event.Type == "checkout.session.async_payment_failed":
+			e.Type = EventCheckoutFailed
+		case s.PaymentStatus == stripe.CheckoutSessionPaymentStatusPaid:
+			e.Type = EventCheckoutCompleted
+		}
+		e.SessionID = s.ID
+		e.Metadata = s.Metadata
+		e.Amount = s.AmountTotal
+		e.Currency = string(s.Currency)
+		if s.PaymentIntent != nil {
+			e.PaymentIntentID = s.PaymentIntent.ID
+		}
+	case "charge.refunded":
+		var charge stripe.Charge
+		if err := json.Unmarshal(event.Data.Raw, &charge); err != nil {
+			return nil, err
+		}
+		e.Type = EventChargeRefunded
+		e.Amount = charge.AmountRefunded
+		e.Currency = string(charge.Currency)
+		if charge.PaymentIntent != nil {
+			e.PaymentIntentID = charge.PaymentIntent.ID
+		}
+	case "charge.dispute.created":
+		var dispute stripe.Dispute
+		if err := json.Unmarshal(event.Data.Raw, &dispute); err != nil {
+			return nil, err
+		}
+		e.Type = EventDisputeCreated
+		e.Amount = dispute.Amount
+		e.Currency = string(dispute.Currency)
+		e.Reason = string(dispute.Reason)
+		if dispute.PaymentIntent != nil {
+			e.PaymentIntentID = dispute.PaymentIntent.ID
+		}
+	}
+
+	return e, nil
+}
+
+func stripeCheckout(s *stripe.CheckoutSession) *Checkout {
+	c := &Checkout{
+		SessionID: s.ID,
+		URL:       s.URL,
+		Status:    CheckoutOpen,
+		Amount:    s.AmountTotal,
+		Currency:  string(s.Currency),
+		Metadata:  s.Metadata,
+	}
+	switch {
+	case s.PaymentStatus == stripe.CheckoutSessionPaymentStatusPaid:
+		c.Status = CheckoutPaid
+	case s.Status == stripe.CheckoutSessionStatusExpired:
+		c.Status = CheckoutExpired
+	}
+	if s.PaymentIntent != nil {
+		c.PaymentIntentID = s.PaymentIntent.ID
+	}
+	return c
+}
+
+// stripeError tells requests Stripe turned down apart from failures worth
+// retrying.
+func stripeError(err error) error {
+	var stripeErr *stripe.Error
+	if errors.As(err, &stripeErr) {
+		switch {
+		case stripeErr.HTTPStatusCode == http.StatusNotFound:
+			return fmt.Errorf("%w: %s", ErrNotFound, stripeErr.Msg)
+		case stripeErr.HTTPStatusCode < http.StatusInternalServerError:
+			return fmt.Errorf("%w: %s", ErrRejected, stripeErr.Msg)
+		}
+	}
+	return err
+}
@@ -0,0 +1,161 @@
+package recording
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// The Matroska elements a WebM recording is made of.
+const (
+	idEBML               = 0x1A45DFA3
+	idEBMLVersion        = 0x4286
+	idEBMLReadVersion    = 0x42F7
+	idEBMLMaxIDLength    = 0x42F2
+	idEBMLMaxSizeLength  = 0x42F3
+	idDocType            = 0x4282
+	idDocTypeVersion     = 0x4287
+	idDocTypeReadVersion = 0x4285
+
+	idSegment      = 0x18538067
+	idSeekHead     = 0x114D9B74
+	idSeek         = 0x4DBB
+	idSeekID       = 0x53AB
+	idSeekPosition = 0x53AC
+
+	idInfo          = 0x1549A966
+	idTimecodeScale = 0x2AD7B1
+	idDuration      = 0x4489
+	idMuxingApp     = 0x4D80
+	idWritingApp    = 0x5741
+
+	idTracks            = 0x1654AE6B
+	idTrackEntry        = 0xAE
+	idTrackNumber       = 0xD7
+	idTrackUID          = 0x73C5
+	idTrackType         = 0x83
+	idCodecID           = 0x86
+	idCodecPrivate      = 0x63A2
+	idSeekPreRoll       = 0x56BB
+	idVideo             = 0xE0
+	idPixelWidth        = 0xB0
+	idPixelHeight       = 0xBA
+	idAudio             = 0xE1
+	idSamplingFrequency = 0xB5
+	idChannels          = 0x9F
+
+	idCluster     = 0x1F43B675
+	idTimecode    = 0xE7
+	idSimpleBlock = 0xA3
+
+	idCues               = 0x1C53BB6B
+	idCuePoint           = 0xBB
+	idCueTime            = 0xB3
+	idCueTrackPositions  = 0xB7
+	idCueTrack           = 0xF7
+	idCueClusterPosition = 0xF1
+
+	idVoid = 0xEC
+)
+
+const (
+	trackTypeVideo = 1
+	trackTypeAudio = 2
+
+	// seekSize is a seek entry with an eight byte position
+	seekSize = 21
+	// seekHeadSize holds the info's, the tracks' and the cues' entries
+	seekHeadSize = 4 + 1 + 3*seekSize
+	// durationSize is the duration as an eight byte float
+	durationSize = 2 + 1 + 8
+)
+
+// unknownSize marks an element whose size isn't known yet.
+var unknownSize = []byte{0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+
+// element appends the element id holding data to buf.
+func element(buf []byte, id uint32, data []byte) []byte {
+	buf = appendID(buf, id)
+	buf = appendSize(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+// void appends an element of size bytes that readers skip, to be
+// overwritten later.
+func void(buf []byte, size int) []byte {
+	return element(buf, idVoid, make([]byte, size-2))
+}
+
+// seek is the seek head's entry for the element id at position.
+func seek(id uint32, position int64) []byte {
+	return element(nil, idSeek, concat(
+		element(nil, idSeekID, appendID(nil, id)),
+		element(nil, idSeekPosition, uintWidth(uint64(position), 8)),
+	))
+}
+
+func appendID(buf []byte, id uint32) []byte {
+	switch {
+	case id >= 1<<24:
+		return append(buf, byte(id>>24), byte(id>>16), byte(id>>8), byte(id))
+	case id >= 1<<16:
+		return append(buf, byte(id>>16), byte(id>>8), byte(id))
+	case id >= 1<<8:
+		return append(buf, byte(id>>8), byte(id))
+	default:
+		return append(buf, byte(id))
+	}
+}
+
+// appendSize appends n in as few bytes as it fits, leaving out the all ones
+// value of each width, which means unknown.
+func appendSize(buf []byte, n uint64) []byte {
+	width := 1
+	for width < 8 && n >= 1<<(7*width)-1 {
+		width++
+	}
+	return appendSizeWidth(buf, n, width)
+}
+
+func appendSizeWidth(buf []byte, n uint64, width int) []byte {
+	n |= 1 << (7 * width)
+	for i := width - 1; i >= 0; i-- {
+		buf = append(buf, byte(n>>(8*i)))
+	}
+	return buf
+}
+
+func uintData(n uint64) []byte {
+	width := 1
+	for width < 8 && n >= 1<<(8*width) {
+		width++
+	}
+	return uintWidth(n, width)
+}
+
+func uintWidth(n uint64, width int) []byte {
+	data := make([]byte, width)
+	for i := range data {
+		data[i] = byte(n >> (8 * (width - 1 - i)))
+	}
+	return data
+}
+
+func floatData(f float64) []byte {
+	return binary.BigEndian.AppendUint64(nil, math.Float64bits(f))
+}
+
+func le16(n uint16) []byte {
+	return binary.LittleEndian.AppendUint16(nil, n)
+}
+
+func le32(n uint32) []byte {
+	return binary.LittleEndian.AppendUint32(nil, n)
+}
+
+func concat(parts ...[]byte) []byte {
+	var buf []byte
+	for _, part := range parts {
+		buf = append(buf, part...)
+	}
+	return buf
+}
@@ -0,0 +1,399 @@
+// Package recording writes what a participant publishes in a recorded
+// video room to a WebM file browsers can play: their VP8 video and Opus
+// audio, muxed together.
+package recording
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/pion/webrtc/v3/pkg/media/samplebuilder"
+)
+
+var (
+	ErrUnsupportedCodec = errors.New("codec can't be recorded")
+	ErrClosed           = errors.New("recording is closed")
+)
+
+const (
+	// ContentType is what recordings are served as
+	ContentType = "video/webm"
+	// Extension is the recordings' file extension
+	Extension = ".webm"
+)
+
+const (
+	videoTrack = 1
+	audioTrack = 2
+
+	// clusterSpan is the longest a cluster runs, in milliseconds, when no
+	// video key frame starts another one
+	clusterSpan = 5000
+
+	// how many packets a track waits for one that came out of order
+	videoMaxLate = 256
+	audioMaxLate = 32
+)
+
+// WebM is a recording being written. Its header goes out first, with room
+// left for what is only known at the end, which Close fills in; until then
+// the file plays as a live stream would.
+type WebM struct {
+	mu    sync.Mutex
+	file  *os.File
+	start time.Time
+	// offset is how much was written to file so far
+	offset int64
+	// segmentStart is where the segment's data begins, which seek and cue
+	// positions are relative to
+	segmentStart int64
+	// where the placeholders Close and the first key frame fill in are
+	segmentSizeAt, cuesSeekAt, durationAt, widthAt, heightAt int64
+	sized                                                    bool
+
+	cluster     bytes.Buffer
+	inCluster   bool
+	clusterTime int64
+	cues        []cue
+	duration    int64
+	closed      bool
+}
+
+type cue struct {
+	time     int64
+	track    uint64
+	position int64
+}
+
+// Create starts a recording at path, creating its directory.
+func Create(path string) (*WebM, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &WebM{file: file, start: time.Now()}
+	if err := w.writeHeader(); err != nil {
+		file.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	return w, nil
+}
+
+// Track returns where to write the RTP packets of a track in codec. Only
+// VP8 video and Opus audio can be recorded.
+func (w *WebM) Track(codec webrtc.RTPCodecParameters) (media.Writer, error) {
+	t := &track{webm: w, clockRate: int64(codec.ClockRate)}
+	switch strings.ToLower(codec.MimeType) {
+	case strings.ToLower(webrtc.MimeTypeVP8):
+		t.number = videoTrack
+		t.builder = samplebuilder.New(videoMaxLate, &codecs.VP8Packet{}, codec.ClockRate)
+	case strings.ToLower(webrtc.MimeTypeOpus):
+		t.number = audioTrack
+		t.builder = samplebuilder.New(audioMaxLate, &codecs.OpusPacket{}, codec.ClockRate)
+	default:
+		return nil, ErrUnsupportedCodec
+	}
+	if t.clockRate == 0 {
+		return nil, ErrUnsupportedCodec
+	}
+	return t, nil
+}
+
+// Close finishes the file. Tracks still being written to fail with
+// ErrClosed from then on.
+func (w *WebM) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	err := w.finish()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (w *WebM) writeHeader() error {
+	var header []byte
+	header = element(header, idEBML, concat(
+		element(nil, idEBMLVersion, uintData(1)),
+		element(nil, idEBMLReadVersion, uintData(1)),
+		element(nil, idEBMLMaxIDLength, uintData(4)),
+		element(nil, idEBMLMaxSizeLength, uintData(8)),
+		element(nil, idDocType, []byte("webm")),
+		element(nil, idDocTypeVersion, uintData(4)),
+		element(nil, idDocTypeReadVersion, uintData(2)),
+	))
+
+	// the segment's size is unknown until Close
+	header = appendID(header, idSegment)
+	w.segmentSizeAt = int64(len(header))
+	header = append(header, unknownSize...)
+	w.segmentStart = int64(len(header))
+
+	// the duration takes the place of the void at the end of the info
+	infoData := concat(
+		element(nil, idTimecodeScale, uintData(uint64(time.Millisecond))),
+		element(nil, idMuxingApp, []byte("appointr")),
+		element(nil, idWritingApp, []byte("appointr")),
+	)
+	durationOffset := len(infoData)
+	infoData = void(infoData, durationSize)
+	info := element(nil, idInfo, infoData)
+
+	opusHead := concat([]byte("OpusHead"), []byte{1, 2}, le16(0), le32(48000), le16(0), []byte{0})
+	// the video size is the last thing in the header, for the first key
+	// frame to fill in
+	tracks := element(nil, idTracks, concat(
+		element(nil, idTrackEntry, concat(
+			element(nil, idTrackNumber, uintData(audioTrack)),
+			element(nil, idTrackUID, uintData(audioTrack)),
+			element(nil, idTrackType, uintData(trackTypeAudio)),
+			element(nil, idCodecID, []byte("A_OPUS")),
+			element(nil, idCodecPrivate, opusHead),
+			element(nil, idSeekPreRoll, uintData(uint64(80*time.Millisecond))),
+			element(nil, idAudio, concat(
+				element(nil, idSamplingFrequency, floatData(48000)),
+				element(nil, idChannels, uintData(2)),
+			)),
+		)),
+		element(nil, idTrackEntry, concat(
+			element(nil, idTrackNumber, uintData(videoTrack)),
+			element(nil, idTrackUID, uintData(videoTrack)),
+			element(nil, idTrackType, uintData(trackTypeVideo)),
+			element(nil, idCodecID, []byte("V_VP8")),
+			element(nil, idVideo, concat(
+				element(nil, idPixelWidth, uintWidth(640, 2)),
+				element(nil, idPixelHeight, uintWidth(480, 2)),
+			)),
+		)),
+	))
+
+	// the cues' entry takes the place of the void at the end of the seek head
+	infoPosition := int64(seekHeadSize)
+	tracksPosition := infoPosition + int64(len(info))
+	seekHead := element(nil, idSeekHead, void(concat(
+		seek(idInfo, infoPosition),
+		seek(idTracks, tracksPosition),
+	), seekSize))
+	if len(seekHead) != seekHeadSize {
+		return errors.New("recording: seek head is misaligned")
+	}
+
+	w.cuesSeekAt = w.segmentStart + int64(seekHeadSize-seekSize)
+	w.durationAt = w.segmentStart + infoPosition + int64(len(info)-len(infoData)+durationOffset)
+	end := w.segmentStart + tracksPosition + int64(len(tracks))
+	w.heightAt = end - 2
+	w.widthAt = end - 2 - 2 - 2
+
+	header = concat(header, seekHead, info, tracks)
+	if _, err := w.file.Write(header); err != nil {
+		return err
+	}
+	w.offset = int64(len(header))
+	return nil
+}
+
+// writeBlock adds a frame of track number at ms into the recording,
+// starting a new cluster at video key frames or when the open one runs
+// long.
+func (w *WebM) writeBlock(number uint64, ms int64, keyframe bool, frame []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrClosed
+	}
+
+	ms = max(ms, 0)
+	rel := ms - w.clusterTime
+	if !w.inCluster || rel < math.MinInt16 || rel > clusterSpan || (number == videoTrack && keyframe && rel > 0) {
+		if err := w.flushCluster(); err != nil {
+			return err
+		}
+		w.inCluster = true
+		w.clusterTime = ms
+		w.cues = append(w.cues, cue{time: ms, track: number, position: w.offset - w.segmentStart})
+		w.cluster.Write(element(nil, idTimecode, uintData(uint64(ms))))
+		rel = 0
+	}
+
+	var flags byte
+	if keyframe {
+		flags |= 0x80
+	}
+	block := concat([]byte{0x80 | byte(number), byte(uint16(rel) >> 8), byte(rel), flags}, frame)
+	w.cluster.Write(element(nil, idSimpleBlock, block))
+	w.duration = max(w.duration, ms)
+	return nil
+}
+
+// resize sets the video size from the first key frame.
+func (w *WebM) resize(width, height uint16) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.sized || w.closed {
+		return nil
+	}
+	w.sized = true
+
+	if _, err := w.file.WriteAt(uintWidth(uint64(width), 2), w.widthAt); err != nil {
+		return err
+	}
+	_, err := w.file.WriteAt(uintWidth(uint64(height), 2), w.heightAt)
+	return err
+}
+
+func (w *WebM) flushCluster() error {
+	if !w.inCluster {
+		return nil
+	}
+	w.inCluster = false
+
+	cluster := element(nil, idCluster, w.cluster.Bytes())
+	w.cluster.Reset()
+	if _, err := w.file.Write(cluster); err != nil {
+		return err
+	}
+	w.offset += int64(len(cluster))
+	return nil
+}
+
+// finish writes the last cluster and the cues and fills in the
+// placeholders of the header.
+func (w *WebM) finish() error {
+	if err := w.flushCluster(); err != nil {
+		return err
+	}
+
+	if len(w.cues) > 0 {
+		var points []byte
+		for _, c := range w.cues {
+			points = element(points, idCuePoint, concat(
+				element(nil, idCueTime, uintData(uint64(c.time))),
+				element(nil, idCueTrackPositions, concat(
+					element(nil, idCueTrack, uintData(c.track)),
+					element(nil, idCueClusterPosition, uintData(uint64(c.position))),
+				)),
+			))
+		}
+		cues := element(nil, idCues, points)
+		position := w.offset - w.segmentStart
+		if _, err := w.file.Write(cues); err != nil {
+			return err
+		}
+		w.offset += int64(len(cues))
+		if _, err := w.file.WriteAt(seek(idCues, position), w.cuesSeekAt); err != nil {
+			return err
+		}
+	}
+
+	if _, err := w.file.WriteAt(element(nil, idDuration, floatData(float64(w.duration))), w.durationAt); err != nil {
+		return err
+	}
+	_, err := w.file.WriteAt(appendSizeWidth(nil, uint64(w.offset-w.segmentStart), 8), w.segmentSizeAt)
+	return err
+}
+
+// track turns one track's RTP packets back into frames and writes them to
+// the recording, timed from when the track's first frame arrived.
+type track struct {
+	webm      *WebM
+	number    uint64
+	builder   *samplebuilder.SampleBuilder
+	clockRate int64
+
+	started bool
+	keyed   bool
+	// offset is when the track started in the recording, in milliseconds
+	offset  int64
+	last    uint32
+	elapsed int64
+}
+
+func (t *track) WriteRTP(packet *rtp.Packet) error {
+	// the builder holds on to packets, whose payload the caller reuses
+	held := *packet
+	held.Payload = append([]byte(nil), packet.Payload...)
+	t.builder.Push(&held)
+
+	for {
+		sample, timestamp := t.builder.PopWithTimestamp()
+		if sample == nil {
+			return nil
+		}
+		if err := t.write(sample.Data, timestamp); err != nil {
+			return err
+		}
+	}
+}
+
+// Close ends the track; the file is closed with the WebM.
+func (t *track) Close() error {
+	return nil
+}
+
+func (t *track) write(frame []byte, timestamp uint32) error {
+	if len(frame) == 0 {
+		return nil
+	}
+
+	keyframe := true
+	if t.number == videoTrack {
+		// the frame tag's inverted key frame bit
+		keyframe = frame[0]&0x01 == 0
+		if !t.keyed {
+			// nothing decodes before the first key frame
+			if !keyframe {
+				return nil
+			}
+			t.keyed = true
+			if width, height, ok := vp8Size(frame); ok {
+				if err := t.webm.resize(width, height); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if !t.started {
+		t.started = true
+		t.last = timestamp
+		t.offset = time.Since(t.webm.start).Milliseconds()
+	}
+	t.elapsed += int64(int32(timestamp - t.last))
+	t.last = timestamp
+
+	return t.webm.writeBlock(t.number, t.offset+t.elapsed*1000/t.clockRate, keyframe, frame)
+}
+
+// vp8Size reads the picture size from a VP8 key frame's header.
+func vp8Size(frame []byte) (width, height uint16, ok bool) {
+	if len(frame) < 10 || frame[3] != 0x9d || frame[4] != 0x01 || frame[5] != 0x2a {
+		return 0, 0, false
+	}
+	width = binary.LittleEndian.Uint16(frame[6:8]) & 0x3fff
+	height = binary.LittleEndian.Uint16(frame[8:10]) & 0x3fff
+	return width, height, width > 0 && height > 0
+}
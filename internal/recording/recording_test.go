@@ -0,0 +1,254 @@
+package recording
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+)
+
+var (
+	vp8  = webrtc.RTPCodecParameters{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}}
+	opus = webrtc.RTPCodecParameters{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2}}
+)
+
+// ebmlElement is an element read back from a file, at offset.
+type ebmlElement struct {
+	id       uint32
+	offset   int64
+	data     []byte
+	children []*ebmlElement
+}
+
+var masters = map[uint32]bool{
+	idEBML: true, idSegment: true, idSeekHead: true, idSeek: true, idInfo: true, idTracks: true,
+	idTrackEntry: true, idVideo: true, idAudio: true, idCluster: true, idCues: true, idCuePoint: true,
+	idCueTrackPositions: true,
+}
+
+func readVint(t *testing.T, data []byte, keepMarker bool) (uint64, int) {
+	t.Helper()
+	if len(data) == 0 || data[0] == 0 {
+		t.Fatalf("bad variable size integer % x", data)
+	}
+	width := 1
+	for data[0]&(0x80>>(width-1)) == 0 {
+		width++
+	}
+	if len(data) < width {
+		t.Fatalf("truncated variable size integer % x", data)
+	}
+	n := uint64(data[0])
+	if !keepMarker {
+		n &^= 0x80 >> (width - 1)
+	}
+	for _, b := range data[1:width] {
+		n = n<<8 | uint64(b)
+	}
+	return n, width
+}
+
+// parse reads the elements in data, which starts at offset in the file.
+func parse(t *testing.T, data []byte, offset int64) []*ebmlElement {
+	t.Helper()
+	var elements []*ebmlElement
+	for len(data) > 0 {
+		id, idWidth := readVint(t, data, true)
+		size, sizeWidth := readVint(t, data[idWidth:], false)
+		start := idWidth + sizeWidth
+		if uint64(len(data)-start) < size {
+			t.Fatalf("element %x at %d is %d bytes, only %d left", id, offset, size, len(data)-start)
+		}
+		e := &ebmlElement{id: uint32(id), offset: offset, data: data[start : start+int(size)]}
+		if masters[e.id] {
+			e.children = parse(t, e.data, offset+int64(start))
+		}
+		elements = append(elements, e)
+		data = data[start+int(size):]
+		offset += int64(start) + int64(size)
+	}
+	return elements
+}
+
+func (e *ebmlElement) child(id uint32) *ebmlElement {
+	for _, c := range e.children {
+		if c.id == id {
+			return c
+		}
+	}
+	return nil
+}
+
+func (e *ebmlElement) all(id uint32) []*ebmlElement {
+	var found []*ebmlElement
+	for _, c := range e.children {
+		if c.id == id {
+			found = append(found, c)
+		}
+	}
+	return found
+}
+
+func (e *ebmlElement) uint() uint64 {
+	var n uint64
+	for _, b := range e.data {
+		n = n<<8 | uint64(b)
+	}
+	return n
+}
+
+// vp8Frame is a frame of the given size in a single packet, behind the
+// payload descriptor marking the start of a partition.
+func vp8Frame(keyframe bool, width, height uint16) []byte {
+	if !keyframe {
+		return []byte{0x10, 0x01, 0x00, 0x00, 0xAA, 0xBB}
+	}
+	frame := []byte{0x10, 0x00, 0x00, 0x00, 0x9d, 0x01, 0x2a}
+	frame = binary.LittleEndian.AppendUint16(frame, width)
+	frame = binary.LittleEndian.AppendUint16(frame, height)
+	return append(frame, 0xCC, 0xDD)
+}
+
+func TestWebM(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meeting", "room-1.webm")
+	w, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	video, err := w.Track(vp8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	audio, err := w.Track(opus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Track(webrtc.RTPCodecParameters{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264, ClockRate: 90000}}); !errors.Is(err, ErrUnsupportedCodec) {
+		t.Errorf("recording H.264: got %v, want %v", err, ErrUnsupportedCodec)
+	}
+
+	// a frame before the first key frame, key frames at 1 and 6; the last
+	// frame is held back waiting for the next, which never comes
+	keyframes := map[int]bool{1: true, 6: true}
+	for i := range 10 {
+		packet := &rtp.Packet{
+			Header:  rtp.Header{Version: 2, Marker: true, SequenceNumber: uint16(1000 + i), Timestamp: uint32(i * 3000)},
+			Payload: vp8Frame(keyframes[i], 320, 240),
+		}
+		if err := video.WriteRTP(packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range 50 {
+		packet := &rtp.Packet{
+			Header:  rtp.Header{Version: 2, Marker: true, SequenceNumber: uint16(65530 + i), Timestamp: uint32(math.MaxUint32 - 4800 + i*960)},
+			Payload: []byte{0xFC, byte(i)},
+		}
+		if err := audio.WriteRTP(packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("closing twice: %v", err)
+	}
+	if err := audio.WriteRTP(&rtp.Packet{Header: rtp.Header{SequenceNumber: 80, Timestamp: 1 << 20}, Payload: []byte{0xFC}}); !errors.Is(err, ErrClosed) {
+		t.Errorf("writing after close: got %v, want %v", err, ErrClosed)
+	}
+
+	file, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	top := parse(t, file, 0)
+	if len(top) != 2 || top[0].id != idEBML || top[1].id != idSegment {
+		t.Fatalf("file is not an EBML header and a segment")
+	}
+	if docType := top[0].child(idDocType); docType == nil || string(docType.data) != "webm" {
+		t.Fatalf("doc type is not webm")
+	}
+
+	segment := top[1]
+	segmentStart := segment.children[0].offset
+	info := segment.child(idInfo)
+	tracks := segment.child(idTracks)
+	cues := segment.child(idCues)
+	if info == nil || tracks == nil || cues == nil {
+		t.Fatal("segment lacks info, tracks or cues")
+	}
+
+	for _, s := range segment.child(idSeekHead).all(idSeek) {
+		id := uint32(s.child(idSeekID).uint())
+		position := segmentStart + int64(s.child(idSeekPosition).uint())
+		var found bool
+		for _, e := range segment.children {
+			found = found || (e.id == id && e.offset == position)
+		}
+		if !found {
+			t.Errorf("seek entry for %x points at %d, where it isn't", id, position)
+		}
+	}
+
+	duration := info.child(idDuration)
+	if duration == nil || math.Float64frombits(binary.BigEndian.Uint64(duration.data)) <= 0 {
+		t.Error("duration is not set")
+	}
+
+	for _, entry := range tracks.all(idTrackEntry) {
+		if entry.child(idTrackNumber).uint() != videoTrack {
+			continue
+		}
+		v := entry.child(idVideo)
+		if width, height := v.child(idPixelWidth).uint(), v.child(idPixelHeight).uint(); width != 320 || height != 240 {
+			t.Errorf("video is %dx%d, want 320x240", width, height)
+		}
+	}
+
+	blocks := map[uint64]int{}
+	var keyBlocks int
+	clusters := map[int64]bool{}
+	last := map[uint64]int64{}
+	for _, cluster := range segment.all(idCluster) {
+		clusters[cluster.offset-segmentStart] = true
+		timecode := int64(cluster.child(idTimecode).uint())
+		for i, block := range cluster.all(idSimpleBlock) {
+			number, width := readVint(t, block.data, false)
+			at := timecode + int64(int16(binary.BigEndian.Uint16(block.data[width:])))
+			if at < last[number] {
+				t.Errorf("track %d went back in time to %d", number, at)
+			}
+			last[number] = at
+			blocks[number]++
+
+			keyframe := block.data[width+2]&0x80 != 0
+			if number == videoTrack && keyframe {
+				keyBlocks++
+				if i != 0 && at > timecode {
+					t.Errorf("key frame at %d is inside the cluster at %d", at, timecode)
+				}
+			}
+		}
+	}
+	if blocks[videoTrack] != 8 || keyBlocks != 2 {
+		t.Errorf("got %d video frames, %d key frames, want 8 and 2", blocks[videoTrack], keyBlocks)
+	}
+	if blocks[audioTrack] != 49 {
+		t.Errorf("got %d audio frames, want 49", blocks[audioTrack])
+	}
+
+	for _, point := range cues.all(idCuePoint) {
+		position := int64(point.child(idCueTrackPositions).child(idCueClusterPosition).uint())
+		if !clusters[position] {
+			t.Errorf("cue points at %d, where no cluster is", position)
+		}
+	}
+}
@@ -0,0 +1,465 @@
+// Package sfu forwards media between the participants of group and
+// recorded video rooms. Each participant has one peer connection with the
+// server: it publishes its own audio and video on it and receives everyone
+// else's. The server always makes the offers, so a connection renegotiates
+// whenever someone's tracks come or go.
+package sfu
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Althaf66/Appointr/internal/signaling"
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+)
+
+// keyFrameInterval is how often publishers are asked for a key frame, so
+// that subscribers who join mid-stream get a picture quickly.
+const keyFrameInterval = 3 * time.Second
+
+var ErrNotConnected = errors.New("not connected to the media server")
+
+// Recorder starts recording what userID publishes in roomID, or returns
+// nil when they aren't recorded.
+type Recorder func(roomID string, userID int64) (Recording, error)
+
+// Recording keeps the tracks a participant publishes together, until it
+// is closed when they leave.
+type Recording interface {
+	Track(codec webrtc.RTPCodecParameters) (media.Writer, error)
+	Close() error
+}
+
+// SFU is a signaling.Server forwarding media between the participants of
+// each room. Like the hub it signals through, it only knows the rooms
+// whose sockets reached this process.
+type SFU struct {
+	hub    *signaling.Hub
+	api    *webrtc.API
+	config webrtc.Configuration
+	record Recorder
+	rooms  map[string]*room
+	mutex  sync.Mutex
+}
+
+// New makes an SFU signaling through hub. record may be nil, to record
+// nothing.
+func New(hub *signaling.Hub, config webrtc.Configuration, record Recorder) (*SFU, error) {
+	api, err := newAPI()
+	if err != nil {
+		return nil, err
+	}
+	return &SFU{
+		hub:    hub,
+		api:    api,
+		config: config,
+		record: record,
+		rooms:  make(map[string]*room),
+	}, nil
+}
+
+// newAPI negotiates VP8 video and Opus audio only, which every browser
+// sends and recordings are written in.
+func newAPI() (*webrtc.API, error) {
+	m := &webrtc.MediaEngine{}
+	err := m.RegisterCodec(webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{
+			MimeType:    webrtc.MimeTypeOpus,
+			ClockRate:   48000,
+			Channels:    2,
+			SDPFmtpLine: "minptime=10;useinbandfec=1",
+		},
+		PayloadType: 111,
+	}, webrtc.RTPCodecTypeAudio)
+	if err != nil {
+		return nil, err
+	}
+	err = m.RegisterCodec(webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{
+			MimeType:  webrtc.MimeTypeVP8,
+			ClockRate: 90000,
+			RTCPFeedback: []webrtc.RTCPFeedback{
+				{Type: "goog-remb"}, {Type: "ccm", Parameter: "fir"}, {Type: "nack"}, {Type: "nack", Parameter: "pli"},
+			},
+		},
+		PayloadType: 96,
+	}, webrtc.RTPCodecTypeVideo)
+	if err != nil {
+		return nil, err
+	}
+
+	interceptors := &interceptor.Registry{}
+	if err := webrtc.RegisterDefaultInterceptors(m, interceptors); err != nil {
+		return nil, err
+	}
+	return webrtc.NewAPI(webrtc.WithMediaEngine(m), webrtc.WithInterceptorRegistry(interceptors)), nil
+}
+
+type room struct {
+	id     string
+	sfu    *SFU
+	peers  map[int64]*participant
+	tracks map[string]*track // track ID -> track
+	mutex  sync.Mutex
+}
+
+type participant struct {
+	userID int64
+	pc     *webrtc.PeerConnection
+	// pending is set when the connection needs an offer it couldn't get
+	// yet, because the previous one is still unanswered
+	pending bool
+	// recording is nil when the participant isn't recorded
+	recording Recording
+}
+
+// track is a participant's published track, written to every other
+// participant's connection.
+type track struct {
+	owner *participant
+	local *webrtc.TrackLocalStaticRTP
+}
+
+// Join connects userID to the room's media, replacing an earlier
+// connection of theirs, and sends them the first offer.
+func (s *SFU) Join(roomID string, userID int64) error {
+	pc, err := s.api.NewPeerConnection(s.config)
+	if err != nil {
+		return err
+	}
+	for _, kind := range []webrtc.RTPCodecType{webrtc.RTPCodecTypeAudio, webrtc.RTPCodecTypeVideo} {
+		_, err := pc.AddTransceiverFromKind(kind, webrtc.RTPTransceiverInit{
+			Direction: webrtc.RTPTransceiverDirectionRecvonly,
+		})
+		if err != nil {
+			pc.Close()
+			return err
+		}
+	}
+
+	p := &participant{userID: userID, pc: pc, pending: true}
+	if s.record != nil {
+		p.recording, err = s.record(roomID, userID)
+		if err != nil {
+			log.Printf("sfu: error recording user %d: %v", userID, err)
+		}
+	}
+
+	pc.OnICECandidate(func(c *webrtc.ICECandidate) {
+		if c == nil {
+			return
+		}
+		candidate, err := json.Marshal(c.ToJSON())
+		if err != nil {
+			return
+		}
+		s.hub.Send(roomID, userID, signaling.Message{Type: signaling.TypeCandidate, Candidate: candidate})
+	})
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		if state == webrtc.PeerConnectionStateFailed {
+			pc.Close()
+		}
+	})
+
+	s.mutex.Lock()
+	r, exists := s.rooms[roomID]
+	if !exists {
+		r = &room{
+			id:     roomID,
+			sfu:    s,
+			peers:  make(map[int64]*participant),
+			tracks: make(map[string]*track),
+		}
+		s.rooms[roomID] = r
+	}
+	r.mutex.Lock()
+	s.mutex.Unlock()
+	defer r.mutex.Unlock()
+
+	pc.OnTrack(func(remote *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		r.forward(p, remote)
+	})
+
+	if old, ok := r.peers[userID]; ok {
+		r.drop(old)
+	}
+	r.peers[userID] = p
+	r.negotiateAll()
+
+	return nil
+}
+
+// Leave disconnects userID and stops forwarding their tracks.
+func (s *SFU) Leave(roomID string, userID int64) {
+	s.mutex.Lock()
+	r, exists := s.rooms[roomID]
+	if !exists {
+		s.mutex.Unlock()
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	p, ok := r.peers[userID]
+	if ok {
+		r.drop(p)
+		delete(r.peers, userID)
+	}
+	if len(r.peers) == 0 {
+		delete(s.rooms, roomID)
+	}
+	s.mutex.Unlock()
+
+	if ok {
+		r.negotiateAll()
+	}
+}
+
+// Handle applies an answer or candidate from userID to their connection.
+// Offers are only ever made by the server.
+func (s *SFU) Handle(roomID string, userID int64, msg signaling.Message) error {
+	s.mutex.Lock()
+	r, exists := s.rooms[roomID]
+	s.mutex.Unlock()
+	if !exists {
+		return ErrNotConnected
+	}
+
+	r.mutex.Lock()
+	p, ok := r.peers[userID]
+	r.mutex.Unlock()
+	if !ok {
+		return ErrNotConnected
+	}
+
+	switch msg.Type {
+	case signaling.TypeAnswer:
+		err := p.pc.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeAnswer, SDP: msg.SDP})
+		if err != nil {
+			return err
+		}
+
+		// offer what changed while this answer was awaited
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		if r.peers[userID] == p {
+			r.negotiate(p)
+		}
+		return nil
+
+	case signaling.TypeCandidate:
+		var candidate webrtc.ICECandidateInit
+		if err := json.Unmarshal(msg.Candidate, &candidate); err != nil {
+			return err
+		}
+		return p.pc.AddICECandidate(candidate)
+
+	default:
+		return fmt.Errorf("the media server doesn't take %s messages", msg.Type)
+	}
+}
+
+// drop closes p's connection and stops forwarding its tracks. The caller
+// holds r.mutex.
+func (r *room) drop(p *participant) {
+	for id, t := range r.tracks {
+		if t.owner == p {
+			delete(r.tracks, id)
+		}
+	}
+	if err := p.pc.Close(); err != nil {
+		log.Printf("sfu: error closing connection of user %d: %v", p.userID, err)
+	}
+	if p.recording != nil {
+		go func() {
+			if err := p.recording.Close(); err != nil {
+				log.Printf("sfu: error closing recording of user %d: %v", p.userID, err)
+			}
+		}()
+	}
+}
+
+// forward publishes remote, received from p, to the rest of the room until
+// it ends.
+func (r *room) forward(p *participant, remote *webrtc.TrackRemote) {
+	// the stream ID tells subscribers whose track it is
+	local, err := webrtc.NewTrackLocalStaticRTP(
+		remote.Codec().RTPCodecCapability,
+		fmt.Sprintf("%d-%s", p.userID, remote.ID()),
+		strconv.FormatInt(p.userID, 10),
+	)
+	if err != nil {
+		log.Printf("sfu: error forwarding track of user %d: %v", p.userID, err)
+		return
+	}
+	t := &track{owner: p, local: local}
+
+	r.mutex.Lock()
+	if r.peers[p.userID] != p {
+		r.mutex.Unlock()
+		return
+	}
+	r.tracks[local.ID()] = t
+	r.negotiateAll()
+	r.mutex.Unlock()
+
+	var recording media.Writer
+	if p.recording != nil {
+		recording, err = p.recording.Track(remote.Codec())
+		if err != nil {
+			log.Printf("sfu: error recording track of user %d: %v", p.userID, err)
+		}
+	}
+
+	done := make(chan struct{})
+	defer func() {
+		if recording != nil {
+			recording.Close()
+		}
+		close(done)
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		if r.tracks[local.ID()] == t {
+			delete(r.tracks, local.ID())
+			r.negotiateAll()
+		}
+	}()
+
+	if remote.Kind() == webrtc.RTPCodecTypeVideo {
+		go requestKeyFrames(p.pc, remote, done)
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := remote.Read(buf)
+		if err != nil {
+			return
+		}
+		if recording != nil {
+			packet := &rtp.Packet{}
+			if err := packet.Unmarshal(buf[:n]); err == nil {
+				if err := recording.WriteRTP(packet); err != nil {
+					log.Printf("sfu: error recording track of user %d: %v", p.userID, err)
+					recording.Close()
+					recording = nil
+				}
+			}
+		}
+		// a track nobody is subscribed to yet reports a closed pipe
+		if _, err := local.Write(buf[:n]); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+			return
+		}
+	}
+}
+
+func requestKeyFrames(pc *webrtc.PeerConnection, remote *webrtc.TrackRemote, done <-chan struct{}) {
+	ticker := time.NewTicker(keyFrameInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			err := pc.WriteRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: uint32(remote.SSRC())}})
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// negotiateAll brings every connection in the room up to date with the
+// room's tracks. The caller holds r.mutex.
+func (r *room) negotiateAll() {
+	for _, p := range r.peers {
+		r.negotiate(p)
+	}
+}
+
+// negotiate makes p's connection send it the tracks of the others in the
+// room, and nothing else, and offers it the change. A connection that
+// fails to renegotiate is closed, so the client can start over. The caller
+// holds r.mutex.
+func (r *room) negotiate(p *participant) {
+	if err := r.offer(p); err != nil {
+		log.Printf("sfu: error renegotiating with user %d: %v", p.userID, err)
+		p.pc.Close()
+	}
+}
+
+func (r *room) offer(p *participant) error {
+	switch p.pc.ConnectionState() {
+	case webrtc.PeerConnectionStateClosed, webrtc.PeerConnectionStateFailed:
+		return nil
+	}
+	if p.pc.SignalingState() != webrtc.SignalingStateStable {
+		p.pending = true
+		return nil
+	}
+
+	changed := p.pending
+	p.pending = false
+
+	sending := map[string]bool{}
+	for _, sender := range p.pc.GetSenders() {
+		if sender.Track() == nil {
+			continue
+		}
+		id := sender.Track().ID()
+		if _, ok := r.tracks[id]; !ok {
+			if err := p.pc.RemoveTrack(sender); err != nil {
+				return err
+			}
+			changed = true
+			continue
+		}
+		sending[id] = true
+	}
+	for id, t := range r.tracks {
+		if t.owner == p || sending[id] {
+			continue
+		}
+		sender, err := p.pc.AddTrack(t.local)
+		if err != nil {
+			return err
+		}
+		go drainRTCP(sender)
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	offer, err := p.pc.CreateOffer(nil)
+	if err != nil {
+		return err
+	}
+	if err := p.pc.SetLocalDescription(offer); err != nil {
+		return err
+	}
+
+	return r.sfu.hub.Send(r.id, p.userID, signaling.Message{Type: signaling.TypeOffer, SDP: offer.SDP})
+}
+
+// drainRTCP reads the subscriber's reports, which interceptors like NACK
+// handling need read to work, until the sender stops.
+func drainRTCP(sender *webrtc.RTPSender) {
+	buf := make([]byte, 1500)
+	for {
+		if _, _, err := sender.Read(buf); err != nil {
+			return
+		}
+	}
+}
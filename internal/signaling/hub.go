@@ -0,0 +1,243 @@
+package signaling
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	ErrRoomFull     = errors.New("room is full")
+	ErrPeerNotFound = errors.New("peer is not in the room")
+)
+
+// Message types. Offers, answers and candidates are relayed between peers;
+// the rest are sent by the server.
+const (
+	TypeOffer     = "offer"
+	TypeAnswer    = "answer"
+	TypeCandidate = "candidate"
+	TypePeers     = "peers"
+	TypeJoin      = "join"
+	TypeLeave     = "leave"
+	TypeError     = "error"
+)
+
+// Message is one signaling message on the socket. From is always set by
+// the server to the sender's user ID, or left out on messages from the
+// server itself; To names the peer to relay to.
+type Message struct {
+	Type      string          `json:"type"`
+	From      int64           `json:"from,omitempty"`
+	To        int64           `json:"to,omitempty"`
+	SDP       string          `json:"sdp,omitempty"`
+	Candidate json.RawMessage `json:"candidate,omitempty"`
+	Peers     []int64         `json:"peers,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// Server is a media server taking part in a room's signaling, like an
+// SFU. Offers, answers and candidates addressed to no peer go to it, and
+// it answers through Hub.Send.
+type Server interface {
+	// Join connects userID to the server, replacing an earlier connection
+	Join(roomID string, userID int64) error
+	Leave(roomID string, userID int64)
+	Handle(roomID string, userID int64, msg Message) error
+}
+
+// Hub relays signaling messages between the peers connected to each room.
+// A user is in a room at most once: connecting again replaces the older
+// socket. Only sockets connected to this process are reached, so all of a
+// room's have to be routed to the same API instance.
+type Hub struct {
+	rooms    map[string]map[int64]*peer // roomID -> userID -> peer
+	mutex    sync.Mutex
+	maxPeers int
+	Upgrader websocket.Upgrader
+}
+
+func NewHub(maxPeers int) *Hub {
+	return &Hub{
+		rooms:    make(map[string]map[int64]*peer),
+		maxPeers: maxPeers,
+		Upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin: func(r *http.Request) bool {
+				return true
+			},
+		},
+	}
+}
+
+// join adds p to its room, tells the others it joined and tells p who is
+// already there.
+func (h *Hub) join(p *peer) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	peers, exists := h.rooms[p.roomID]
+	if !exists {
+		peers = make(map[int64]*peer)
+		h.rooms[p.roomID] = peers
+	}
+
+	if old, ok := peers[p.userID]; ok {
+		old.close()
+	} else if len(peers) >= h.maxPeers {
+		if len(peers) == 0 {
+			delete(h.rooms, p.roomID)
+		}
+		return ErrRoomFull
+	}
+
+	others := []int64{}
+	for id, other := range peers {
+		if id == p.userID {
+			continue
+		}
+		others = append(others, id)
+		other.push(Message{Type: TypeJoin, From: p.userID})
+	}
+	peers[p.userID] = p
+	p.push(Message{Type: TypePeers, Peers: others})
+
+	return nil
+}
+
+// leave removes p from its room, unless a newer socket of the same user
+// has taken its place, and tells the others it left. It reports whether p
+// was removed.
+func (h *Hub) leave(p *peer) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	peers, exists := h.rooms[p.roomID]
+	if !exists || peers[p.userID] != p {
+		return false
+	}
+
+	delete(peers, p.userID)
+	p.close()
+	if len(peers) == 0 {
+		delete(h.rooms, p.roomID)
+		return true
+	}
+	for _, other := range peers {
+		other.push(Message{Type: TypeLeave, From: p.userID})
+	}
+	return true
+}
+
+// relay sends msg to the peer it is addressed to in roomID.
+func (h *Hub) relay(roomID string, msg Message) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	to, ok := h.rooms[roomID][msg.To]
+	if !ok || msg.To == msg.From {
+		return ErrPeerNotFound
+	}
+	to.push(msg)
+	return nil
+}
+
+// Send delivers a message from the server, like a candidate of its own
+// peer connection, to userID in roomID.
+func (h *Hub) Send(roomID string, userID int64, msg Message) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	to, ok := h.rooms[roomID][userID]
+	if !ok {
+		return ErrPeerNotFound
+	}
+	msg.From = 0
+	msg.To = userID
+	to.push(msg)
+	return nil
+}
+
+// CloseRoom disconnects everyone in roomID.
+func (h *Hub) CloseRoom(roomID string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for _, p := range h.rooms[roomID] {
+		p.close()
+	}
+	delete(h.rooms, roomID)
+}
+
+// Peers lists the users connected to roomID.
+func (h *Hub) Peers(roomID string) []int64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	ids := []int64{}
+	for id := range h.rooms[roomID] {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Serve runs the signaling socket of userID in roomID until it closes.
+// With a server, the user also connects to it; without, peers only
+// connect to each other.
+func (h *Hub) Serve(roomID string, userID int64, conn *websocket.Conn, server Server) {
+	p := newPeer(roomID, userID, conn)
+	if err := h.join(p); err != nil {
+		p.writeClose(err)
+		return
+	}
+	defer func() {
+		// a replaced socket leaves the server to the newer one
+		if h.leave(p) && server != nil {
+			server.Leave(roomID, userID)
+		}
+	}()
+
+	go p.writePump()
+
+	if server != nil {
+		if err := server.Join(roomID, userID); err != nil {
+			log.Printf("signaling server error: %v", err)
+			p.push(Message{Type: TypeError, Error: err.Error()})
+			return
+		}
+	}
+
+	for {
+		msg, err := p.read()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Printf("signaling error: %v", err)
+			}
+			return
+		}
+		if msg == nil {
+			continue
+		}
+
+		switch msg.Type {
+		case TypeOffer, TypeAnswer, TypeCandidate:
+			msg.From = userID
+			if msg.To == 0 && server != nil {
+				if err := server.Handle(roomID, userID, *msg); err != nil {
+					p.push(Message{Type: TypeError, Error: err.Error()})
+				}
+				continue
+			}
+			if err := h.relay(roomID, *msg); err != nil {
+				p.push(Message{Type: TypeError, To: msg.To, Error: err.Error()})
+			}
+		default:
+			p.push(Message{Type: TypeError, Error: "unknown message type " + msg.Type})
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package signaling
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = 54 * time.Second
+	maxMessageSize = 64 * 1024
+)
+
+// peer is one user's socket in a room. Everything sent to it goes through
+// send, so only writePump writes to the connection.
+type peer struct {
+	roomID string
+	userID int64
+	conn   *websocket.Conn
+	send   chan []byte
+	closed bool
+	mutex  sync.Mutex
+}
+
+func newPeer(roomID string, userID int64, conn *websocket.Conn) *peer {
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	return &peer{
+		roomID: roomID,
+		userID: userID,
+		conn:   conn,
+		send:   make(chan []byte, 64),
+	}
+}
+
+// push queues msg for the peer, dropping the peer if it can't keep up.
+func (p *peer) push(msg Message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Error marshaling signaling message: %v", err)
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		return
+	}
+	select {
+	case p.send <- data:
+	default:
+		p.closed = true
+		close(p.send)
+	}
+}
+
+// close ends writePump, which closes the connection.
+func (p *peer) close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if !p.closed {
+		p.closed = true
+		close(p.send)
+	}
+}
+
+// read returns the next message, or nil for one that isn't valid JSON.
+func (p *peer) read() (*Message, error) {
+	_, data, err := p.conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Printf("Error parsing signaling message: %v", err)
+		return nil, nil
+	}
+	return &msg, nil
+}
+
+func (p *peer) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		p.conn.Close()
+	}()
+
+	for {
+		select {
+		case data, ok := <-p.send:
+			p.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				p.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := p.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			p.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := p.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeClose turns the peer away before it joined, with err as the reason.
+func (p *peer) writeClose(err error) {
+	defer p.conn.Close()
+
+	data, _ := json.Marshal(Message{Type: TypeError, Error: err.Error()})
+	p.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	p.conn.WriteMessage(websocket.TextMessage, data)
+	p.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, err.Error()))
+}
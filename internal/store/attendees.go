@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrMeetingFull       = errors.New("meeting has no places left")
+	ErrDuplicateAttendee = errors.New("user is already attending the meeting")
+	ErrMeetingLocked     = errors.New("meeting can no longer be changed")
+)
+
+// Attendee is a user the mentor invited to a group meeting besides its
+// mentee. With the mentor and the mentee they fill the meeting's
+// MaxParticipants places.
+type Attendee struct {
+	MeetingID int64     `json:"meeting_id"`
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	AddedAt   time.Time `json:"added_at"`
+}
+
+type AttendeeStore struct {
+	db *sql.DB
+}
+
+func (s *AttendeeStore) AddAttendee(ctx context.Context, attendee *Attendee) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		// the lock keeps two invitations from taking the last place
+		var max int
+		err := tx.QueryRowContext(ctx, `SELECT max_participants FROM meetings WHERE id = $1 FOR UPDATE`, attendee.MeetingID).Scan(&max)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		attendees, err := countAttendees(ctx, tx, attendee.MeetingID)
+		if err != nil {
+			return err
+		}
+		if 2+attendees >= max {
+			return ErrMeetingFull
+		}
+
+		return tx.QueryRowContext(ctx, `
+			WITH added AS (
+				INSERT INTO meeting_attendees (meeting_id, user_id) VALUES ($1, $2)
+				RETURNING added_at
+			)
+			SELECT added.added_at, users.username FROM added, users WHERE users.id = $2`,
+			attendee.MeetingID, attendee.UserID).Scan(&attendee.AddedAt, &attendee.Username)
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrDuplicateAttendee
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *AttendeeStore) RemoveAttendee(ctx context.Context, meetingID, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM meeting_attendees WHERE meeting_id = $1 AND user_id = $2`, meetingID, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *AttendeeStore) GetAttendees(ctx context.Context, meetingID int64) ([]*Attendee, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT a.meeting_id, a.user_id, u.username, a.added_at
+		FROM meeting_attendees a
+		JOIN users u ON u.id = a.user_id
+		WHERE a.meeting_id = $1
+		ORDER BY a.added_at, a.user_id`, meetingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attendees := []*Attendee{}
+	for rows.Next() {
+		a := &Attendee{}
+		if err := rows.Scan(&a.MeetingID, &a.UserID, &a.Username, &a.AddedAt); err != nil {
+			return nil, err
+		}
+		attendees = append(attendees, a)
+	}
+
+	return attendees, rows.Err()
+}
+
+func (s *AttendeeStore) IsAttendee(ctx context.Context, meetingID, userID int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var attending bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM meeting_attendees WHERE meeting_id = $1 AND user_id = $2)`,
+		meetingID, userID).Scan(&attending)
+	return attending, err
+}
+
+// SetMaxParticipants resizes a meeting that isn't paid yet; its room is
+// opened at that size. It can't shrink below the people already invited.
+func (s *AttendeeStore) SetMaxParticipants(ctx context.Context, meetingID int64, max int) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		status, err := lockMeetingStatus(ctx, tx, meetingID)
+		if err != nil {
+			return err
+		}
+		if status != MeetingRequested && status != MeetingConfirmed {
+			return ErrMeetingLocked
+		}
+
+		attendees, err := countAttendees(ctx, tx, meetingID)
+		if err != nil {
+			return err
+		}
+		if 2+attendees > max {
+			return ErrMeetingFull
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE meetings SET max_participants = $2 WHERE id = $1`, meetingID, max)
+		return err
+	})
+}
+
+func countAttendees(ctx context.Context, tx *sql.Tx, meetingID int64) (int, error) {
+	var n int
+	err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM meeting_attendees WHERE meeting_id = $1`, meetingID).Scan(&n)
+	return n, err
+}
@@ -16,56 +16,193 @@ type BookingSlot struct {
 	StartPeriod string    `json:"start_period"`
 	EndTime     string    `json:"end_time"`
 	EndPeriod   string    `json:"end_period"`
+	Timezone    string    `json:"timezone"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
+// ScheduleCheck vets a mentor's whole weekly schedule as a change would
+// leave it. Its error is returned as is, and nothing is changed.
+type ScheduleCheck func(slots []*BookingSlot) error
+
 type BookingStore struct {
 	db *sql.DB
 }
 
-func (s *BookingStore) CreateBookingSlot(ctx context.Context, slot *BookingSlot) error {
-	query := `INSERT INTO bookingslots (userid, days, start_time, start_period, end_time, end_period)
-        VALUES ($1, $2, $3, $4, $5, $6)
+// CreateBookingSlot adds slot to its mentor's schedule if check passes on
+// the schedule with it. The mentor is locked from reading the schedule to
+// the insert, so two concurrent changes can't both pass.
+func (s *BookingStore) CreateBookingSlot(ctx context.Context, slot *BookingSlot, check ScheduleCheck) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		existing, err := lockBookingSlots(ctx, tx, slot.UserID)
+		if err != nil {
+			return err
+		}
+		if err := check(append(existing, slot)); err != nil {
+			return err
+		}
+
+		return s.createBookingSlot(ctx, tx, slot)
+	})
+}
+
+func (s *BookingStore) createBookingSlot(ctx context.Context, tx *sql.Tx, slot *BookingSlot) error {
+	query := `INSERT INTO bookingslots (userid, days, start_time, start_period, end_time, end_period, timezone)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
         RETURNING id, created_at, updated_at`
 
+	return tx.QueryRowContext(ctx, query, slot.UserID, pq.Array(slot.Days), slot.StartTime,
+		slot.StartPeriod, slot.EndTime, slot.EndPeriod, slot.Timezone).Scan(&slot.ID, &slot.CreatedAt, &slot.UpdatedAt)
+}
+
+func (s *BookingStore) GetBookingSlotByID(ctx context.Context, id int64) (*BookingSlot, error) {
+	query := `SELECT id, userid, days, start_time, start_period, end_time, end_period, timezone, created_at, updated_at
+		FROM bookingslots
+		WHERE id = $1`
+
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
-	tx, err := s.db.BeginTx(ctx, nil)
+	slot := &BookingSlot{}
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&slot.ID, &slot.UserID, pq.Array(&slot.Days), &slot.StartTime,
+		&slot.StartPeriod, &slot.EndTime, &slot.EndPeriod, &slot.Timezone, &slot.CreatedAt, &slot.UpdatedAt)
 	if err != nil {
-		return err
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
 	}
-	defer tx.Rollback()
 
-	err = s.db.QueryRowContext(ctx, query, slot.UserID, pq.Array(slot.Days), slot.StartTime,
-		slot.StartPeriod, slot.EndTime, slot.EndPeriod).Scan(&slot.ID, &slot.CreatedAt, &slot.UpdatedAt)
+	return slot, nil
+}
+
+func (s *BookingStore) GetBookingSlotsByUserID(ctx context.Context, userid int64) ([]*BookingSlot, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, bookingSlotsByUserIDQuery, userid)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	return scanBookingSlots(rows)
+}
+
+// lockBookingSlots locks the mentor, like lockMentorSlot, and returns their
+// schedule.
+func lockBookingSlots(ctx context.Context, tx *sql.Tx, userid int64) ([]*BookingSlot, error) {
+	if err := lockMentor(ctx, tx, userid); err != nil {
+		return nil, err
 	}
-	// createdSlots := make([]BookingSlot, 0, len(slots))
-	// for _, slot := range slots {
-	//     var id int
-	//     var createdAt, updatedAt time.Time
-	//     err = s.db.QueryRow(
-	//         userID,
-	//         slot.Days,
-	//         slot.StartTime,
-	//         slot.StartPeriod,
-	//         slot.EndTime,
-	//         slot.EndPeriod,
-	//     ).Scan(&id, &createdAt, &updatedAt)
-	// createdSlots = append(createdSlots, BookingSlot{
-	//     ID:          id,
-	//     UserID:      userID,
-	//     Days:        slot.Days,
-	//     StartTime:   slot.StartTime,
-	//     StartPeriod: slot.StartPeriod,
-	//     EndTime:     slot.EndTime,
-	//     EndPeriod:   slot.EndPeriod,
-	//     CreatedAt:   createdAt,
-	//     UpdatedAt:   updatedAt,
-	// })
-	// }
-	return tx.Commit()
+
+	rows, err := tx.QueryContext(ctx, bookingSlotsByUserIDQuery, userid)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanBookingSlots(rows)
+}
+
+const bookingSlotsByUserIDQuery = `
+	SELECT id, userid, days, start_time, start_period, end_time, end_period, timezone, created_at, updated_at
+	FROM bookingslots
+	WHERE userid = $1
+	ORDER BY id`
+
+func scanBookingSlots(rows *sql.Rows) ([]*BookingSlot, error) {
+	defer rows.Close()
+
+	slots := []*BookingSlot{}
+	for rows.Next() {
+		slot := &BookingSlot{}
+		err := rows.Scan(&slot.ID, &slot.UserID, pq.Array(&slot.Days), &slot.StartTime, &slot.StartPeriod,
+			&slot.EndTime, &slot.EndPeriod, &slot.Timezone, &slot.CreatedAt, &slot.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		slots = append(slots, slot)
+	}
+
+	return slots, rows.Err()
+}
+
+// UpdateBookingSlot saves slot if check passes on its mentor's schedule
+// with the change, under the same lock as CreateBookingSlot.
+func (s *BookingStore) UpdateBookingSlot(ctx context.Context, slot *BookingSlot, check ScheduleCheck) error {
+	query := `UPDATE bookingslots
+		SET days = $1, start_time = $2, start_period = $3, end_time = $4, end_period = $5, timezone = $6, updated_at = NOW()
+		WHERE id = $7
+		RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		existing, err := lockBookingSlots(ctx, tx, slot.UserID)
+		if err != nil {
+			return err
+		}
+		slots := []*BookingSlot{slot}
+		for _, other := range existing {
+			if other.ID != slot.ID {
+				slots = append(slots, other)
+			}
+		}
+		if err := check(slots); err != nil {
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx, query, pq.Array(slot.Days), slot.StartTime, slot.StartPeriod,
+			slot.EndTime, slot.EndPeriod, slot.Timezone, slot.ID).Scan(&slot.UpdatedAt)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (s *BookingStore) DeleteBookingSlot(ctx context.Context, id int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := lockOwner(ctx, tx, `SELECT userid FROM bookingslots WHERE id = $1`, id); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `DELETE FROM bookingslots WHERE id = $1`, id)
+		return err
+	})
+}
+
+// ReplaceBookingSlots swaps the user's whole weekly schedule for slots.
+func (s *BookingStore) ReplaceBookingSlots(ctx context.Context, userid int64, slots []*BookingSlot) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := lockMentor(ctx, tx, userid); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `DELETE FROM bookingslots WHERE userid = $1`, userid)
+		if err != nil {
+			return err
+		}
+
+		for _, slot := range slots {
+			slot.UserID = userid
+			if err := s.createBookingSlot(ctx, tx, slot); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
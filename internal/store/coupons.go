@@ -0,0 +1,286 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateCoupon = errors.New("a coupon with that code already exists")
+	ErrCouponInvalid   = errors.New("coupon can't be used")
+)
+
+const (
+	CouponPercent = "percent"
+	CouponFixed   = "fixed"
+)
+
+// Coupon is a discount code mentees apply at checkout. A percent coupon
+// takes PercentOff of the price, a fixed one AmountOff in Currency, never
+// more than the price, and it can't be applied where it would take all of
+// it. Coupons without a mentor work for every mentor.
+type Coupon struct {
+	ID             int64      `json:"id"`
+	Code           string     `json:"code"`
+	MentorID       *int64     `json:"mentor_id"`
+	Kind           string     `json:"kind"`
+	PercentOff     *int       `json:"percent_off"`
+	AmountOff      *float64   `json:"amount_off"`
+	Currency       *string    `json:"currency"`
+	MaxRedemptions *int       `json:"max_redemptions"`
+	MaxPerUser     *int       `json:"max_per_user"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	Active         bool       `json:"active"`
+	// Redemptions counts the meetings paid for with it that weren't
+	// cancelled or refunded
+	Redemptions int       `json:"redemptions"`
+	CreatedBy   *int64    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Discount is how much the coupon takes off price, in the same unit.
+func (c *Coupon) Discount(price float64) float64 {
+	var discount float64
+	switch c.Kind {
+	case CouponPercent:
+		discount = math.Round(price*float64(*c.PercentOff)) / 100
+	case CouponFixed:
+		discount = *c.AmountOff
+	}
+	return min(discount, price)
+}
+
+// usableOn tells why the coupon can't discount a session with mentorID
+// charged in currency, if it can't.
+func (c *Coupon) usableOn(mentorID int64, currency string, now time.Time) error {
+	switch {
+	case !c.Active:
+		return fmt.Errorf("%w: it is no longer active", ErrCouponInvalid)
+	case c.ExpiresAt != nil && !now.Before(*c.ExpiresAt):
+		return fmt.Errorf("%w: it has expired", ErrCouponInvalid)
+	case c.MentorID != nil && *c.MentorID != mentorID:
+		return fmt.Errorf("%w: it is for another mentor's sessions", ErrCouponInvalid)
+	case c.Currency != nil && !strings.EqualFold(*c.Currency, currency):
+		return fmt.Errorf("%w: it is in %s, the session is charged in %s", ErrCouponInvalid, *c.Currency, currency)
+	}
+	return nil
+}
+
+type CouponStore struct {
+	db *sql.DB
+}
+
+func (s *CouponStore) CreateCoupon(ctx context.Context, coupon *Coupon) error {
+	query := `
+		INSERT INTO coupons (code, mentor_id, kind, percent_off, amount_off, currency, max_redemptions, max_per_user, expires_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, active, created_at`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query,
+		coupon.Code, coupon.MentorID, coupon.Kind, coupon.PercentOff, coupon.AmountOff, coupon.Currency,
+		coupon.MaxRedemptions, coupon.MaxPerUser, coupon.ExpiresAt, coupon.CreatedBy,
+	).Scan(&coupon.ID, &coupon.Active, &coupon.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrDuplicateCoupon
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *CouponStore) GetCouponByID(ctx context.Context, id int64) (*Coupon, error) {
+	query := couponSelect + `WHERE c.id = $1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	coupon, err := scanCoupon(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return coupon, nil
+}
+
+// GetCoupons lists every coupon, newest first.
+func (s *CouponStore) GetCoupons(ctx context.Context, limit, offset int) ([]*Coupon, error) {
+	query := couponSelect + `
+		ORDER BY c.created_at DESC, c.id DESC
+		LIMIT $1 OFFSET $2`
+
+	return s.queryCoupons(ctx, query, limit, offset)
+}
+
+// GetCouponsByMentorID lists the coupons for a mentor's sessions, newest
+// first.
+func (s *CouponStore) GetCouponsByMentorID(ctx context.Context, mentorID int64) ([]*Coupon, error) {
+	query := couponSelect + `
+		WHERE c.mentor_id = $1
+		ORDER BY c.created_at DESC, c.id DESC`
+
+	return s.queryCoupons(ctx, query, mentorID)
+}
+
+// DeactivateCoupon stops the coupon from being applied again. Meetings it
+// was applied to keep their discount.
+func (s *CouponStore) DeactivateCoupon(ctx context.Context, id int64) error {
+	query := `UPDATE coupons SET active = FALSE WHERE id = $1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// ApplyCoupon discounts a confirmed meeting with the coupon named by code,
+// replacing any coupon applied before, and updates meeting to match. The
+// meeting's session is charged in currency.
+func (s *CouponStore) ApplyCoupon(ctx context.Context, meeting *Meetings, code, currency string) (*Coupon, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var coupon *Coupon
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		var (
+			status           MeetingStatus
+			amount, discount float64
+			mentorID, userID int64
+		)
+		err := tx.QueryRowContext(ctx, `
+			SELECT status, amount, discount, mentorid, userid FROM meetings WHERE id = $1 FOR UPDATE`,
+			meeting.ID).Scan(&status, &amount, &discount, &mentorID, &userID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrNotFound
+			}
+			return err
+		}
+		if status != MeetingConfirmed {
+			return fmt.Errorf("%w: coupons only apply to confirmed meetings, this one is %s", ErrInvalidTransition, status)
+		}
+
+		coupon, err = scanCoupon(tx.QueryRowContext(ctx, couponSelect+`WHERE LOWER(c.code) = LOWER($1) FOR UPDATE OF c`, code))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("%w: there is no coupon %s", ErrCouponInvalid, code)
+			}
+			return err
+		}
+		if err := coupon.usableOn(mentorID, currency, time.Now()); err != nil {
+			return err
+		}
+
+		// uses by other meetings; re-applying to this one doesn't count, nor
+		// do meetings whose checkout was never paid
+		var used, usedByUser int
+		err = tx.QueryRowContext(ctx, `
+			SELECT COUNT(*), COUNT(*) FILTER (WHERE userid = $2)
+			FROM meetings
+			WHERE coupon_id = $1 AND id <> $3 AND status IN `+redeemedStatuses,
+			coupon.ID, userID, meeting.ID).Scan(&used, &usedByUser)
+		if err != nil {
+			return err
+		}
+		if coupon.MaxRedemptions != nil && used >= *coupon.MaxRedemptions {
+			return fmt.Errorf("%w: it has been used up", ErrCouponInvalid)
+		}
+		if coupon.MaxPerUser != nil && usedByUser >= *coupon.MaxPerUser {
+			return fmt.Errorf("%w: you have already used it", ErrCouponInvalid)
+		}
+
+		price := amount + discount
+		discount = coupon.Discount(price)
+		amount = price - discount
+		// checkout can't charge nothing, and free sessions aren't something
+		// a coupon should hand out
+		if amount <= 0 {
+			return fmt.Errorf("%w: it would make the session free", ErrCouponInvalid)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE meetings SET coupon_id = $1, discount = $2, amount = $3 WHERE id = $4`,
+			coupon.ID, discount, amount, meeting.ID)
+		if err != nil {
+			return err
+		}
+
+		meeting.CouponID = &coupon.ID
+		meeting.Discount = discount
+		meeting.Amount = amount
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return coupon, nil
+}
+
+// redeemedStatuses are the statuses of meetings a coupon counts as
+// redeemed on: paid for and not given back.
+const redeemedStatuses = `('paid', 'in_progress', 'completed', 'no_show')`
+
+const couponSelect = `
+	SELECT c.id, c.code, c.mentor_id, c.kind, c.percent_off, c.amount_off, c.currency, c.max_redemptions,
+		c.max_per_user, c.expires_at, c.active,
+		(SELECT COUNT(*) FROM meetings m WHERE m.coupon_id = c.id AND m.status IN ` + redeemedStatuses + `),
+		c.created_by, c.created_at
+	FROM coupons c
+	`
+
+func (s *CouponStore) queryCoupons(ctx context.Context, query string, args ...any) ([]*Coupon, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	coupons := []*Coupon{}
+	for rows.Next() {
+		coupon, err := scanCoupon(rows)
+		if err != nil {
+			return nil, err
+		}
+		coupons = append(coupons, coupon)
+	}
+
+	return coupons, rows.Err()
+}
+
+func scanCoupon(row scanner) (*Coupon, error) {
+	c := &Coupon{}
+	err := row.Scan(&c.ID, &c.Code, &c.MentorID, &c.Kind, &c.PercentOff, &c.AmountOff, &c.Currency, &c.MaxRedemptions,
+		&c.MaxPerUser, &c.ExpiresAt, &c.Active, &c.Redemptions, &c.CreatedBy, &c.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -12,6 +12,7 @@ type Gig struct {
 	Userid      int64    `json:"userid"`
 	Title       string   `json:"title"`
 	Amount      float64  `json:"amount"`
+	Currency    string   `json:"currency"`
 	Description string   `json:"description"`
 	Expertise   string   `json:"expertise"`
 	Discipline  []string `json:"discipline"`
@@ -35,10 +36,10 @@ func (s *GigStore) CreateGig(ctx context.Context, gig *Gig) error {
 	defer tx.Rollback()
 
 	err = tx.QueryRowContext(ctx, `
-		INSERT INTO gigs (userid, title, amount, description, expertise, discipline)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO gigs (userid, title, amount, currency, description, expertise, discipline)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id, created_at, updated_at`,
-		gig.Userid, gig.Title, gig.Amount, gig.Description, gig.Expertise, pq.Array(gig.Discipline)).
+		gig.Userid, gig.Title, gig.Amount, gig.Currency, gig.Description, gig.Expertise, pq.Array(gig.Discipline)).
 		Scan(&gig.ID, &gig.CreatedAt, &gig.UpdatedAt)
 	if err != nil {
 		return err
@@ -56,7 +57,7 @@ func (s *GigStore) GetAllGigs(ctx context.Context, limit, offset int) ([]*Gig, e
 	}
 
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT id, userid, title, amount,description, expertise, discipline FROM gigs
+		SELECT id, userid, title, amount, currency, description, expertise, discipline FROM gigs
 		ORDER BY created_at DESC
 		LIMIT $1 OFFSET $2
 	`, limit, offset)
@@ -69,7 +70,7 @@ func (s *GigStore) GetAllGigs(ctx context.Context, limit, offset int) ([]*Gig, e
 	for rows.Next() {
 		gig := &Gig{}
 		err := rows.Scan(
-			&gig.ID, &gig.Userid, &gig.Title, &gig.Amount, &gig.Description, &gig.Expertise, pq.Array(&gig.Discipline),
+			&gig.ID, &gig.Userid, &gig.Title, &gig.Amount, &gig.Currency, &gig.Description, &gig.Expertise, pq.Array(&gig.Discipline),
 		)
 		if err != nil {
 			return nil, err
@@ -81,7 +82,7 @@ func (s *GigStore) GetAllGigs(ctx context.Context, limit, offset int) ([]*Gig, e
 }
 
 func (s *GigStore) GetGigsByExpertise(ctx context.Context, expertise string) ([]*Gig, error) {
-	query := `SELECT id,userid,title,amount,expertise,discipline,created_at,updated_at FROM 
+	query := `SELECT id,userid,title,amount,currency,expertise,discipline,created_at,updated_at FROM 
 	gigs WHERE expertise ILIKE $1`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
@@ -97,7 +98,7 @@ func (s *GigStore) GetGigsByExpertise(ctx context.Context, expertise string) ([]
 	for rows.Next() {
 		gig := &Gig{}
 		err := rows.Scan(
-			&gig.ID, &gig.Userid, &gig.Title, &gig.Amount, &gig.Expertise, pq.Array(&gig.Discipline),
+			&gig.ID, &gig.Userid, &gig.Title, &gig.Amount, &gig.Currency, &gig.Expertise, pq.Array(&gig.Discipline),
 			&gig.CreatedAt, &gig.UpdatedAt,
 		)
 		if err != nil {
@@ -110,23 +111,56 @@ func (s *GigStore) GetGigsByExpertise(ctx context.Context, expertise string) ([]
 }
 
 func (s *GigStore) GetGigByID(ctx context.Context, id int64) (*Gig, error) {
-	query := `SELECT * FROM gigs WHERE id = $1`
+	query := `SELECT id, userid, title, amount, currency, COALESCE(description, ''), COALESCE(expertise, ''), discipline, created_at, updated_at
+	FROM gigs WHERE id = $1`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
 	var gig Gig
-	err := s.db.QueryRowContext(ctx, query, id).Scan(&gig.ID, &gig.Userid, &gig.Title, &gig.Amount, &gig.Description, &gig.Expertise, pq.Array(&gig.Discipline),
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&gig.ID, &gig.Userid, &gig.Title, &gig.Amount, &gig.Currency, &gig.Description, &gig.Expertise, pq.Array(&gig.Discipline),
 		&gig.CreatedAt, &gig.UpdatedAt)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
 		return nil, err
 	}
 
 	return &gig, nil
 }
 
+// GetGigsByUserID returns the mentor's gigs, cheapest first.
+func (s *GigStore) GetGigsByUserID(ctx context.Context, userid int64) ([]*Gig, error) {
+	query := `SELECT id, userid, title, amount, currency, COALESCE(description, ''), COALESCE(expertise, ''), discipline, created_at, updated_at
+	FROM gigs WHERE userid = $1
+	ORDER BY amount, id`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	gigs := []*Gig{}
+	for rows.Next() {
+		gig := &Gig{}
+		err := rows.Scan(
+			&gig.ID, &gig.Userid, &gig.Title, &gig.Amount, &gig.Currency, &gig.Description, &gig.Expertise, pq.Array(&gig.Discipline),
+			&gig.CreatedAt, &gig.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		gigs = append(gigs, gig)
+	}
+
+	return gigs, rows.Err()
+}
+
 // getbydiscipline
 // getbymentorid
-// getbyuserid
 
 func (s *GigStore) UpdateGig(ctx context.Context, gig *Gig) error {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
@@ -140,9 +174,9 @@ func (s *GigStore) UpdateGig(ctx context.Context, gig *Gig) error {
 
 	_, err = tx.ExecContext(ctx, `
         UPDATE gigs 
-        SET title = $1, amount=$2, description = $3, expertise = $4, discipline = $5, updated_at = NOW()
-        WHERE id = $6
-    `, gig.Title, gig.Amount, gig.Description, gig.Expertise, pq.Array(gig.Discipline), gig.ID)
+        SET title = $1, amount=$2, currency = $3, description = $4, expertise = $5, discipline = $6, updated_at = NOW()
+        WHERE id = $7
+    `, gig.Title, gig.Amount, gig.Currency, gig.Description, gig.Expertise, pq.Array(gig.Discipline), gig.ID)
 	if err != nil {
 		return err
 	}
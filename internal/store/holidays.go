@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// HolidayDateLayout is the format of Holiday.Date
+const HolidayDateLayout = "2006-01-02"
+
+type Holiday struct {
+	ID       int64  `json:"id"`
+	Calendar string `json:"calendar"`
+	Date     string `json:"date"`
+	Name     string `json:"name"`
+}
+
+type HolidayStore struct {
+	db *sql.DB
+}
+
+func (s *HolidayStore) CreateHoliday(ctx context.Context, holiday *Holiday) error {
+	query := `INSERT INTO holidays (calendar, date, name)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (calendar, date) DO UPDATE SET name = EXCLUDED.name
+		RETURNING id`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, holiday.Calendar, holiday.Date, holiday.Name).Scan(&holiday.ID)
+}
+
+func (s *HolidayStore) GetHolidays(ctx context.Context, calendar string) ([]*Holiday, error) {
+	query := `SELECT id, calendar, to_char(date, 'YYYY-MM-DD'), name
+		FROM holidays
+		WHERE calendar = $1
+		ORDER BY date`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, calendar)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanHolidays(rows)
+}
+
+// GetHolidaysForUser returns holidays between the two dates (inclusive) from
+// every calendar the user follows.
+func (s *HolidayStore) GetHolidaysForUser(ctx context.Context, userid int64, from, to string) ([]*Holiday, error) {
+	query := `SELECT h.id, h.calendar, to_char(h.date, 'YYYY-MM-DD'), h.name
+		FROM holidays h
+		JOIN mentor_holiday_calendars c ON c.calendar = h.calendar
+		WHERE c.userid = $1 AND h.date BETWEEN $2::date AND $3::date
+		ORDER BY h.date`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userid, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanHolidays(rows)
+}
+
+func (s *HolidayStore) DeleteHoliday(ctx context.Context, id int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM holidays WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *HolidayStore) GetCalendars(ctx context.Context, userid int64) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	calendars := []string{}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COALESCE(array_agg(calendar ORDER BY calendar), '{}')
+		FROM mentor_holiday_calendars
+		WHERE userid = $1`, userid).Scan(pq.Array(&calendars))
+	if err != nil {
+		return nil, err
+	}
+
+	return calendars, nil
+}
+
+// SetCalendars replaces the calendars the user follows.
+func (s *HolidayStore) SetCalendars(ctx context.Context, userid int64, calendars []string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := lockMentor(ctx, tx, userid); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `DELETE FROM mentor_holiday_calendars WHERE userid = $1`, userid)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO mentor_holiday_calendars (userid, calendar)
+			SELECT $1, unnest($2::varchar[])
+			ON CONFLICT DO NOTHING`, userid, pq.Array(calendars))
+		return err
+	})
+}
+
+func scanHolidays(rows *sql.Rows) ([]*Holiday, error) {
+	holidays := []*Holiday{}
+	for rows.Next() {
+		h := &Holiday{}
+		if err := rows.Scan(&h.ID, &h.Calendar, &h.Date, &h.Name); err != nil {
+			return nil, err
+		}
+		holidays = append(holidays, h)
+	}
+	return holidays, rows.Err()
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Invoice is the record of a payment sent to the mentee. Amounts are in
+// the currency's minor unit: Subtotal is the mentor's price, Fee and Tax
+// what was charged on top, adding up to Total.
+type Invoice struct {
+	ID          int64      `json:"id"`
+	Number      string     `json:"number"`
+	PaymentID   int64      `json:"payment_id"`
+	MentorName  string     `json:"mentor_name"`
+	MentorEmail string     `json:"mentor_email"`
+	MenteeName  string     `json:"mentee_name"`
+	MenteeEmail string     `json:"mentee_email"`
+	Description string     `json:"description"`
+	Currency    string     `json:"currency"`
+	Subtotal    int64      `json:"subtotal"`
+	Fee         int64      `json:"fee"`
+	Tax         int64      `json:"tax"`
+	Total       int64      `json:"total"`
+	PaidAt      time.Time  `json:"paid_at"`
+	IssuedAt    time.Time  `json:"issued_at"`
+	EmailedAt   *time.Time `json:"emailed_at"`
+}
+
+type InvoiceStore struct {
+	db *sql.DB
+}
+
+// CreateInvoice numbers and saves the invoice for its payment and reports
+// whether it was new. A payment that already has an invoice gets that one
+// back in invoice instead.
+func (s *InvoiceStore) CreateInvoice(ctx context.Context, invoice *Invoice) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	created := false
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		existing, err := scanInvoice(tx.QueryRowContext(ctx, invoiceSelect+`WHERE payment_id = $1`, invoice.PaymentID))
+		if err == nil {
+			*invoice = *existing
+			return nil
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+
+		// the counter row stays locked until commit, so numbers are handed
+		// out in order, and a rollback returns the number
+		year := time.Now().UTC().Year()
+		var n int64
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO invoice_counters (year, last_number) VALUES ($1, 1)
+			ON CONFLICT (year) DO UPDATE SET last_number = invoice_counters.last_number + 1
+			RETURNING last_number`, year).Scan(&n)
+		if err != nil {
+			return err
+		}
+		invoice.Number = fmt.Sprintf("INV-%d-%06d", year, n)
+
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO invoices (number, payment_id, mentor_name, mentor_email, mentee_name, mentee_email,
+				description, currency, subtotal, fee, tax, total, paid_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			RETURNING id, issued_at`,
+			invoice.Number, invoice.PaymentID, invoice.MentorName, invoice.MentorEmail, invoice.MenteeName,
+			invoice.MenteeEmail, invoice.Description, invoice.Currency, invoice.Subtotal, invoice.Fee, invoice.Tax,
+			invoice.Total, invoice.PaidAt).Scan(&invoice.ID, &invoice.IssuedAt)
+		if err != nil {
+			return err
+		}
+		created = true
+		return nil
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			// issued concurrently for the same payment
+			existing, err := s.GetInvoiceByPaymentID(ctx, invoice.PaymentID)
+			if err != nil {
+				return false, err
+			}
+			*invoice = *existing
+			return false, nil
+		}
+		return false, err
+	}
+
+	return created, nil
+}
+
+func (s *InvoiceStore) GetInvoiceByPaymentID(ctx context.Context, paymentID int64) (*Invoice, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	invoice, err := scanInvoice(s.db.QueryRowContext(ctx, invoiceSelect+`WHERE payment_id = $1`, paymentID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return invoice, nil
+}
+
+func (s *InvoiceStore) MarkInvoiceEmailed(ctx context.Context, id int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `UPDATE invoices SET emailed_at = NOW() WHERE id = $1`, id)
+	return err
+}
+
+const invoiceSelect = `
+	SELECT id, number, payment_id, mentor_name, mentor_email, mentee_name, mentee_email, description, currency,
+		subtotal, fee, tax, total, paid_at, issued_at, emailed_at
+	FROM invoices
+	`
+
+func scanInvoice(row scanner) (*Invoice, error) {
+	i := &Invoice{}
+	err := row.Scan(&i.ID, &i.Number, &i.PaymentID, &i.MentorName, &i.MentorEmail, &i.MenteeName, &i.MenteeEmail,
+		&i.Description, &i.Currency, &i.Subtotal, &i.Fee, &i.Tax, &i.Total, &i.PaidAt, &i.IssuedAt, &i.EmailedAt)
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
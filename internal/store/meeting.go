@@ -3,32 +3,68 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"time"
 )
 
+var ErrMeetingConflict = errors.New("mentor already has a meeting at that time")
+
+// MeetingDateLayout is how Meetings.Date is rendered for clients
+const MeetingDateLayout = "02 Jan 2006"
+
 type Meetings struct {
-	ID          int64   `json:"id"`
-	Userid      int64   `json:"userid"`
-	Mentorid    int64   `json:"mentorid"`
-	Day         string  `json:"day"`
-	Date        string  `json:"date"`
-	StartTime   string  `json:"start_time"`
-	StartPeriod string  `json:"start_period"`
-	Isconfirm   bool    `json:"isconfirm"`
-	Ispaid      bool    `json:"ispaid"`
-	Iscompleted bool    `json:"iscompleted"`
-	Amount      float64 `json:"amount"`
-	Link        string  `json:"link"`
+	ID              int64         `json:"id"`
+	Userid          int64         `json:"userid"`
+	Mentorid        int64         `json:"mentorid"`
+	GigID           *int64        `json:"gig_id"`
+	StartAt         time.Time     `json:"start_at"`
+	DurationMinutes int           `json:"duration_minutes"`
+	Status          MeetingStatus `json:"status"`
+	Amount          float64       `json:"amount"`
+	CouponID        *int64        `json:"coupon_id"`
+	Discount        float64       `json:"discount"`
+	PurchaseID      *int64        `json:"purchase_id"`
+	Link            string        `json:"link"`
+	MaxParticipants int           `json:"max_participants"`
+
+	// Display fields, filled by Localize for the viewer's timezone
+	Timezone    string `json:"timezone"`
+	Day         string `json:"day"`
+	Date        string `json:"date"`
+	StartTime   string `json:"start_time"`
+	StartPeriod string `json:"start_period"`
+}
+
+func (m *Meetings) EndAt() time.Time {
+	return m.StartAt.Add(time.Duration(m.DurationMinutes) * time.Minute)
+}
+
+// Localize renders the meeting's start in loc.
+func (m *Meetings) Localize(loc *time.Location) {
+	start := m.StartAt.In(loc)
+	m.StartAt = start
+	m.Timezone = loc.String()
+	m.Day = start.Weekday().String()
+	m.Date = start.Format(MeetingDateLayout)
+	m.StartTime = start.Format("3:04")
+	m.StartPeriod = start.Format("PM")
 }
 
 type MeetingsStore struct {
 	db *sql.DB
 }
 
-func (s *MeetingsStore) CreateMeeting(ctx context.Context, meeting *Meetings) error {
+// BookingCheck vets a booking once its mentor is locked, so that nothing
+// about the mentor's schedule can change before the booking commits. Its
+// error is returned as is.
+type BookingCheck func(ctx context.Context) error
+
+// CreateMeeting books meeting if check passes and the mentor is free then.
+func (s *MeetingsStore) CreateMeeting(ctx context.Context, meeting *Meetings, check BookingCheck) error {
 	query := `
-		INSERT INTO meetings (userid, mentorid, day, date, start_time, start_period, isconfirm, ispaid, iscompleted, amount, link)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-		RETURNING id`
+		INSERT INTO meetings (userid, mentorid, gig_id, start_at, duration_minutes, status, amount, purchase_id, link)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, max_participants`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
@@ -38,10 +74,28 @@ func (s *MeetingsStore) CreateMeeting(ctx context.Context, meeting *Meetings) er
 	}
 	defer tx.Rollback()
 
+	err = lockMentorSlot(ctx, tx, meeting.Mentorid, meeting.StartAt, meeting.EndAt(), 0)
+	if err != nil {
+		return err
+	}
+	if err := check(ctx); err != nil {
+		return err
+	}
+
+	if meeting.PurchaseID != nil {
+		if err := useCredit(ctx, tx, *meeting.PurchaseID); err != nil {
+			return err
+		}
+	}
+
 	err = tx.QueryRowContext(ctx, query,
-		meeting.Userid, meeting.Mentorid, meeting.Day, meeting.Date, meeting.StartTime,
-		meeting.StartPeriod, meeting.Isconfirm, meeting.Ispaid, meeting.Iscompleted,
-		meeting.Amount, meeting.Link).Scan(&meeting.ID)
+		meeting.Userid, meeting.Mentorid, meeting.GigID, meeting.StartAt, meeting.DurationMinutes,
+		meeting.Status, meeting.Amount, meeting.PurchaseID, meeting.Link).Scan(&meeting.ID, &meeting.MaxParticipants)
+	if err != nil {
+		return err
+	}
+
+	err = recordMeetingStatus(ctx, tx, meeting.ID, nil, meeting.Status, meeting.Userid, "")
 	if err != nil {
 		return err
 	}
@@ -49,9 +103,57 @@ func (s *MeetingsStore) CreateMeeting(ctx context.Context, meeting *Meetings) er
 	return tx.Commit()
 }
 
+// lockMentorSlot serializes bookings per mentor so two requests can't both
+// pass the check, then fails with ErrMeetingConflict if another active
+// meeting (other than exceptID) overlaps [start, end).
+func lockMentorSlot(ctx context.Context, tx *sql.Tx, mentorID int64, start, end time.Time, exceptID int64) error {
+	if err := lockMentor(ctx, tx, mentorID); err != nil {
+		return err
+	}
+
+	var taken bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM meetings
+			WHERE mentorid = $1
+			AND id <> $4
+			AND status NOT IN ('cancelled', 'refunded')
+			AND start_at < $3
+			AND start_at + duration_minutes * INTERVAL '1 minute' > $2
+		)`, mentorID, start, end, exceptID).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrMeetingConflict
+	}
+
+	return nil
+}
+
+// lockMentor holds the mentor's lock until tx ends. Bookings and changes to
+// the mentor's schedule take it, so each sees the others' effects.
+func lockMentor(ctx context.Context, tx *sql.Tx, mentorID int64) error {
+	_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, mentorID)
+	return err
+}
+
+// lockOwner locks the mentor query finds for id, or fails with ErrNotFound.
+func lockOwner(ctx context.Context, tx *sql.Tx, query string, id int64) error {
+	var mentorID int64
+	if err := tx.QueryRowContext(ctx, query, id).Scan(&mentorID); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	return lockMentor(ctx, tx, mentorID)
+}
+
 func (s *MeetingsStore) GetAllMeetings(ctx context.Context, limit, offset int) ([]*Meetings, error) {
 	query := `
-		SELECT id, userid, mentorid, day, date, start_time, start_period, isconfirm, ispaid, iscompleted, amount, link
+		SELECT id, userid, mentorid, gig_id, start_at, duration_minutes, status, amount, coupon_id, discount, purchase_id, link, max_participants
 		FROM meetings
 		ORDER BY id
 		LIMIT $1 OFFSET $2`
@@ -68,9 +170,9 @@ func (s *MeetingsStore) GetAllMeetings(ctx context.Context, limit, offset int) (
 	for rows.Next() {
 		meeting := &Meetings{}
 		err := rows.Scan(
-			&meeting.ID, &meeting.Userid, &meeting.Mentorid, &meeting.Day, &meeting.Date,
-			&meeting.StartTime, &meeting.StartPeriod, &meeting.Isconfirm, &meeting.Ispaid,
-			&meeting.Iscompleted, &meeting.Amount, &meeting.Link,
+			&meeting.ID, &meeting.Userid, &meeting.Mentorid, &meeting.GigID, &meeting.StartAt, &meeting.DurationMinutes,
+			&meeting.Status, &meeting.Amount, &meeting.CouponID, &meeting.Discount, &meeting.PurchaseID, &meeting.Link,
+			&meeting.MaxParticipants,
 		)
 		if err != nil {
 			return nil, err
@@ -83,7 +185,7 @@ func (s *MeetingsStore) GetAllMeetings(ctx context.Context, limit, offset int) (
 
 func (s *MeetingsStore) GetMeetingByID(ctx context.Context, id int64) (*Meetings, error) {
 	query := `
-		SELECT id, userid, mentorid, day, date, start_time, start_period, isconfirm, ispaid, iscompleted, amount, link
+		SELECT id, userid, mentorid, gig_id, start_at, duration_minutes, status, amount, coupon_id, discount, purchase_id, link, max_participants
 		FROM meetings
 		WHERE id = $1`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
@@ -91,9 +193,9 @@ func (s *MeetingsStore) GetMeetingByID(ctx context.Context, id int64) (*Meetings
 
 	meeting := &Meetings{}
 	err := s.db.QueryRowContext(ctx, query, id).Scan(
-		&meeting.ID, &meeting.Userid, &meeting.Mentorid, &meeting.Day, &meeting.Date,
-		&meeting.StartTime, &meeting.StartPeriod, &meeting.Isconfirm, &meeting.Ispaid,
-		&meeting.Iscompleted, &meeting.Amount, &meeting.Link,
+		&meeting.ID, &meeting.Userid, &meeting.Mentorid, &meeting.GigID, &meeting.StartAt, &meeting.DurationMinutes,
+		&meeting.Status, &meeting.Amount, &meeting.CouponID, &meeting.Discount, &meeting.PurchaseID, &meeting.Link,
+		&meeting.MaxParticipants,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -107,7 +209,7 @@ func (s *MeetingsStore) GetMeetingByID(ctx context.Context, id int64) (*Meetings
 
 func (s *MeetingsStore) GetMeetingByUserID(ctx context.Context, userid int64) ([]*Meetings, error) {
 	query := `
-		SELECT id, userid, mentorid, day, date, start_time, start_period, isconfirm, ispaid, iscompleted, amount, link
+		SELECT id, userid, mentorid, gig_id, start_at, duration_minutes, status, amount, coupon_id, discount, purchase_id, link, max_participants
 		FROM meetings
 		WHERE userid = $1`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
@@ -123,9 +225,9 @@ func (s *MeetingsStore) GetMeetingByUserID(ctx context.Context, userid int64) ([
 	for rows.Next() {
 		meeting := &Meetings{}
 		err := rows.Scan(
-			&meeting.ID, &meeting.Userid, &meeting.Mentorid, &meeting.Day, &meeting.Date,
-			&meeting.StartTime, &meeting.StartPeriod, &meeting.Isconfirm, &meeting.Ispaid,
-			&meeting.Iscompleted, &meeting.Amount, &meeting.Link,
+			&meeting.ID, &meeting.Userid, &meeting.Mentorid, &meeting.GigID, &meeting.StartAt, &meeting.DurationMinutes,
+			&meeting.Status, &meeting.Amount, &meeting.CouponID, &meeting.Discount, &meeting.PurchaseID, &meeting.Link,
+			&meeting.MaxParticipants,
 		)
 		if err != nil {
 			return nil, err
@@ -136,15 +238,21 @@ func (s *MeetingsStore) GetMeetingByUserID(ctx context.Context, userid int64) ([
 	return meetings, nil
 }
 
-func (s *MeetingsStore) GetMeetingMentorNotConfirm(ctx context.Context, mentorID int64) ([]*Meetings, error) {
+// GetMeetingsByMentorID returns the mentor's meetings overlapping [from, to)
+// that still hold their slot.
+func (s *MeetingsStore) GetMeetingsByMentorID(ctx context.Context, mentorID int64, from, to time.Time) ([]*Meetings, error) {
 	query := `
-		SELECT id, userid, mentorid, day, date, start_time, start_period, isconfirm, ispaid, iscompleted, amount, link
+		SELECT id, userid, mentorid, gig_id, start_at, duration_minutes, status, amount, coupon_id, discount, purchase_id, link, max_participants
 		FROM meetings
-		WHERE mentorid = $1 AND isconfirm = false`
+		WHERE mentorid = $1
+		AND status NOT IN ('cancelled', 'refunded')
+		AND start_at < $3
+		AND start_at + duration_minutes * INTERVAL '1 minute' > $2
+		ORDER BY start_at`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, mentorID)
+	rows, err := s.db.QueryContext(ctx, query, mentorID, from, to)
 	if err != nil {
 		return nil, err
 	}
@@ -154,9 +262,9 @@ func (s *MeetingsStore) GetMeetingMentorNotConfirm(ctx context.Context, mentorID
 	for rows.Next() {
 		meeting := &Meetings{}
 		err := rows.Scan(
-			&meeting.ID, &meeting.Userid, &meeting.Mentorid, &meeting.Day, &meeting.Date,
-			&meeting.StartTime, &meeting.StartPeriod, &meeting.Isconfirm, &meeting.Ispaid,
-			&meeting.Iscompleted, &meeting.Amount, &meeting.Link,
+			&meeting.ID, &meeting.Userid, &meeting.Mentorid, &meeting.GigID, &meeting.StartAt, &meeting.DurationMinutes,
+			&meeting.Status, &meeting.Amount, &meeting.CouponID, &meeting.Discount, &meeting.PurchaseID, &meeting.Link,
+			&meeting.MaxParticipants,
 		)
 		if err != nil {
 			return nil, err
@@ -167,15 +275,15 @@ func (s *MeetingsStore) GetMeetingMentorNotConfirm(ctx context.Context, mentorID
 	return meetings, nil
 }
 
-func (s *MeetingsStore) GetMeetingUserNotPaid(ctx context.Context, userID int64) ([]*Meetings, error) {
+func (s *MeetingsStore) GetMeetingMentorNotConfirm(ctx context.Context, mentorID int64) ([]*Meetings, error) {
 	query := `
-		SELECT id, userid, mentorid, day, date, start_time, start_period, isconfirm, ispaid, iscompleted, amount, link
+		SELECT id, userid, mentorid, gig_id, start_at, duration_minutes, status, amount, coupon_id, discount, purchase_id, link, max_participants
 		FROM meetings
-		WHERE userid = $1 AND isconfirm = true AND ispaid = false`
+		WHERE mentorid = $1 AND status = 'requested'`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, userID)
+	rows, err := s.db.QueryContext(ctx, query, mentorID)
 	if err != nil {
 		return nil, err
 	}
@@ -185,9 +293,9 @@ func (s *MeetingsStore) GetMeetingUserNotPaid(ctx context.Context, userID int64)
 	for rows.Next() {
 		meeting := &Meetings{}
 		err := rows.Scan(
-			&meeting.ID, &meeting.Userid, &meeting.Mentorid, &meeting.Day, &meeting.Date,
-			&meeting.StartTime, &meeting.StartPeriod, &meeting.Isconfirm, &meeting.Ispaid,
-			&meeting.Iscompleted, &meeting.Amount, &meeting.Link,
+			&meeting.ID, &meeting.Userid, &meeting.Mentorid, &meeting.GigID, &meeting.StartAt, &meeting.DurationMinutes,
+			&meeting.Status, &meeting.Amount, &meeting.CouponID, &meeting.Discount, &meeting.PurchaseID, &meeting.Link,
+			&meeting.MaxParticipants,
 		)
 		if err != nil {
 			return nil, err
@@ -198,11 +306,11 @@ func (s *MeetingsStore) GetMeetingUserNotPaid(ctx context.Context, userID int64)
 	return meetings, nil
 }
 
-func (s *MeetingsStore) GetMeetingUserNotCompleted(ctx context.Context, userID int64) ([]*Meetings, error) {
+func (s *MeetingsStore) GetMeetingUserNotPaid(ctx context.Context, userID int64) ([]*Meetings, error) {
 	query := `
-		SELECT id, userid, mentorid, day, date, start_time, start_period, isconfirm, ispaid, iscompleted, amount, link
+		SELECT id, userid, mentorid, gig_id, start_at, duration_minutes, status, amount, coupon_id, discount, purchase_id, link, max_participants
 		FROM meetings
-		WHERE userid = $1 AND isconfirm = true AND ispaid = true AND iscompleted = false`
+		WHERE userid = $1 AND status = 'confirmed'`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
@@ -216,9 +324,9 @@ func (s *MeetingsStore) GetMeetingUserNotCompleted(ctx context.Context, userID i
 	for rows.Next() {
 		meeting := &Meetings{}
 		err := rows.Scan(
-			&meeting.ID, &meeting.Userid, &meeting.Mentorid, &meeting.Day, &meeting.Date,
-			&meeting.StartTime, &meeting.StartPeriod, &meeting.Isconfirm, &meeting.Ispaid,
-			&meeting.Iscompleted, &meeting.Amount, &meeting.Link,
+			&meeting.ID, &meeting.Userid, &meeting.Mentorid, &meeting.GigID, &meeting.StartAt, &meeting.DurationMinutes,
+			&meeting.Status, &meeting.Amount, &meeting.CouponID, &meeting.Discount, &meeting.PurchaseID, &meeting.Link,
+			&meeting.MaxParticipants,
 		)
 		if err != nil {
 			return nil, err
@@ -229,15 +337,15 @@ func (s *MeetingsStore) GetMeetingUserNotCompleted(ctx context.Context, userID i
 	return meetings, nil
 }
 
-func (s *MeetingsStore) GetMeetingMentorNotCompleted(ctx context.Context, mentorID int64) ([]*Meetings, error) {
+func (s *MeetingsStore) GetMeetingUserNotCompleted(ctx context.Context, userID int64) ([]*Meetings, error) {
 	query := `
-		SELECT id, userid, mentorid, day, date, start_time, start_period, isconfirm, ispaid, iscompleted, amount, link
+		SELECT id, userid, mentorid, gig_id, start_at, duration_minutes, status, amount, coupon_id, discount, purchase_id, link, max_participants
 		FROM meetings
-		WHERE mentorid = $1 AND isconfirm = true AND ispaid = true AND iscompleted = false`
+		WHERE userid = $1 AND status IN ('paid', 'in_progress')`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
-	rows, err := s.db.QueryContext(ctx, query, mentorID)
+	rows, err := s.db.QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -247,9 +355,9 @@ func (s *MeetingsStore) GetMeetingMentorNotCompleted(ctx context.Context, mentor
 	for rows.Next() {
 		meeting := &Meetings{}
 		err := rows.Scan(
-			&meeting.ID, &meeting.Userid, &meeting.Mentorid, &meeting.Day, &meeting.Date,
-			&meeting.StartTime, &meeting.StartPeriod, &meeting.Isconfirm, &meeting.Ispaid,
-			&meeting.Iscompleted, &meeting.Amount, &meeting.Link,
+			&meeting.ID, &meeting.Userid, &meeting.Mentorid, &meeting.GigID, &meeting.StartAt, &meeting.DurationMinutes,
+			&meeting.Status, &meeting.Amount, &meeting.CouponID, &meeting.Discount, &meeting.PurchaseID, &meeting.Link,
+			&meeting.MaxParticipants,
 		)
 		if err != nil {
 			return nil, err
@@ -260,94 +368,35 @@ func (s *MeetingsStore) GetMeetingMentorNotCompleted(ctx context.Context, mentor
 	return meetings, nil
 }
 
-func (s *MeetingsStore) UpdateMeetingConfirm(ctx context.Context, meetingID int64) error {
-	query := `
-		UPDATE meetings 
-		SET isconfirm = true
-		WHERE id = $1`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
-	defer cancel()
-
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	result, err := tx.ExecContext(ctx, query, meetingID)
-	if err != nil {
-		return err
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if rowsAffected == 0 {
-		return ErrNotFound
-	}
-
-	return tx.Commit()
-}
-
-func (s *MeetingsStore) UpdateMeetingPaid(ctx context.Context, meetingID int64) error {
-	query := `
-		UPDATE meetings 
-		SET ispaid = true
-		WHERE id = $1`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
-	defer cancel()
-
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	result, err := tx.ExecContext(ctx, query, meetingID)
-	if err != nil {
-		return err
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if rowsAffected == 0 {
-		return ErrNotFound
-	}
-
-	return tx.Commit()
-}
-
-func (s *MeetingsStore) UpdateMeetingCompleted(ctx context.Context, meetingID int64) error {
+func (s *MeetingsStore) GetMeetingMentorNotCompleted(ctx context.Context, mentorID int64) ([]*Meetings, error) {
 	query := `
-		UPDATE meetings 
-		SET iscompleted = true
-		WHERE id = $1`
+		SELECT id, userid, mentorid, gig_id, start_at, duration_minutes, status, amount, coupon_id, discount, purchase_id, link, max_participants
+		FROM meetings
+		WHERE mentorid = $1 AND status IN ('paid', 'in_progress')`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	result, err := tx.ExecContext(ctx, query, meetingID)
+	rows, err := s.db.QueryContext(ctx, query, mentorID)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if rowsAffected == 0 {
-		return ErrNotFound
+	meetings := []*Meetings{}
+	for rows.Next() {
+		meeting := &Meetings{}
+		err := rows.Scan(
+			&meeting.ID, &meeting.Userid, &meeting.Mentorid, &meeting.GigID, &meeting.StartAt, &meeting.DurationMinutes,
+			&meeting.Status, &meeting.Amount, &meeting.CouponID, &meeting.Discount, &meeting.PurchaseID, &meeting.Link,
+			&meeting.MaxParticipants,
+		)
+		if err != nil {
+			return nil, err
+		}
+		meetings = append(meetings, meeting)
 	}
 
-	return tx.Commit()
+	return meetings, nil
 }
 
 func (s *MeetingsStore) UpdateLink(ctx context.Context, meeting *Meetings) error {
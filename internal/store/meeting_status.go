@@ -0,0 +1,202 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidTransition = errors.New("invalid meeting status transition")
+
+type MeetingStatus string
+
+const (
+	MeetingRequested  MeetingStatus = "requested"
+	MeetingConfirmed  MeetingStatus = "confirmed"
+	MeetingPaid       MeetingStatus = "paid"
+	MeetingInProgress MeetingStatus = "in_progress"
+	MeetingCompleted  MeetingStatus = "completed"
+	MeetingCancelled  MeetingStatus = "cancelled"
+	MeetingNoShow     MeetingStatus = "no_show"
+	MeetingRefunded   MeetingStatus = "refunded"
+)
+
+// meetingTransitions lists where each status may move next. Anything not
+// listed is rejected with ErrInvalidTransition.
+var meetingTransitions = map[MeetingStatus][]MeetingStatus{
+	MeetingRequested:  {MeetingConfirmed, MeetingCancelled},
+	MeetingConfirmed:  {MeetingPaid, MeetingCancelled},
+	MeetingPaid:       {MeetingInProgress, MeetingCompleted, MeetingCancelled, MeetingNoShow, MeetingRefunded},
+	MeetingInProgress: {MeetingCompleted, MeetingNoShow},
+	MeetingCompleted:  {MeetingRefunded},
+	MeetingCancelled:  {MeetingRefunded},
+	MeetingNoShow:     {MeetingRefunded},
+	MeetingRefunded:   {},
+}
+
+// Valid reports whether s is a known status.
+func (s MeetingStatus) Valid() bool {
+	_, ok := meetingTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether a meeting in s may move to next.
+func (s MeetingStatus) CanTransitionTo(next MeetingStatus) bool {
+	for _, allowed := range meetingTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Active reports whether a meeting in s still holds its time slot.
+func (s MeetingStatus) Active() bool {
+	return s != MeetingCancelled && s != MeetingRefunded
+}
+
+// MeetingStatusChange is one row of a meeting's status history. ChangedBy is
+// nil when the system made the change.
+type MeetingStatusChange struct {
+	ID         int64          `json:"id"`
+	MeetingID  int64          `json:"meeting_id"`
+	FromStatus *MeetingStatus `json:"from_status"`
+	ToStatus   MeetingStatus  `json:"to_status"`
+	ChangedBy  *int64         `json:"changed_by"`
+	Reason     string         `json:"reason"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// UpdateMeetingStatus moves the meeting to status if the transition table
+// allows it and records who did it. changedBy is 0 for system changes.
+func (s *MeetingsStore) UpdateMeetingStatus(ctx context.Context, meetingID int64, status MeetingStatus, changedBy int64, reason string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		current, err := lockMeetingStatus(ctx, tx, meetingID)
+		if err != nil {
+			return err
+		}
+		return transitionMeeting(ctx, tx, meetingID, current, status, changedBy, reason)
+	})
+}
+
+// MeetingPayment is what the payment provider reports for a paid meeting.
+type MeetingPayment struct {
+	SessionID       string
+	PaymentIntentID string
+	// Amount is in the currency's minor unit
+	Amount   int64
+	Currency string
+}
+
+// markMeetingPaid moves the meeting to paid on behalf of the system and
+// records the payment on it. Replaying the same session is a no-op.
+func markMeetingPaid(ctx context.Context, tx *sql.Tx, meetingID int64, payment MeetingPayment) error {
+	current, err := lockMeetingStatus(ctx, tx, meetingID)
+	if err != nil {
+		return err
+	}
+
+	var session sql.NullString
+	err = tx.QueryRowContext(ctx, `SELECT stripe_session_id FROM meetings WHERE id = $1`, meetingID).Scan(&session)
+	if err != nil {
+		return err
+	}
+	if current == MeetingPaid && session.String == payment.SessionID {
+		return nil
+	}
+
+	err = transitionMeeting(ctx, tx, meetingID, current, MeetingPaid, 0, "checkout "+payment.SessionID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE meetings
+		SET stripe_session_id = $1, stripe_payment_intent_id = $2, paid_amount = $3, paid_currency = $4, paid_at = NOW()
+		WHERE id = $5`, payment.SessionID, payment.PaymentIntentID, payment.Amount, payment.Currency, meetingID)
+	return err
+}
+
+// lockMeetingStatus reads the meeting's status, holding the row until the
+// transaction ends.
+func lockMeetingStatus(ctx context.Context, tx *sql.Tx, meetingID int64) (MeetingStatus, error) {
+	var current MeetingStatus
+	err := tx.QueryRowContext(ctx, `SELECT status FROM meetings WHERE id = $1 FOR UPDATE`, meetingID).Scan(&current)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	return current, nil
+}
+
+func transitionMeeting(ctx context.Context, tx *sql.Tx, meetingID int64, current, status MeetingStatus, changedBy int64, reason string) error {
+	if !current.CanTransitionTo(status) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, current, status)
+	}
+
+	_, err := tx.ExecContext(ctx, `UPDATE meetings SET status = $1 WHERE id = $2`, status, meetingID)
+	if err != nil {
+		return err
+	}
+
+	err = recordMeetingStatus(ctx, tx, meetingID, &current, status, changedBy, reason)
+	if err != nil {
+		return err
+	}
+
+	return settleCredit(ctx, tx, meetingID, status, changedBy)
+}
+
+func (s *MeetingsStore) GetMeetingStatusHistory(ctx context.Context, meetingID int64) ([]*MeetingStatusChange, error) {
+	query := `
+		SELECT id, meeting_id, from_status, to_status, changed_by, reason, created_at
+		FROM meeting_status_history
+		WHERE meeting_id = $1
+		ORDER BY created_at, id`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, meetingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []*MeetingStatusChange{}
+	for rows.Next() {
+		c := &MeetingStatusChange{}
+		var from sql.NullString
+		var by sql.NullInt64
+		err := rows.Scan(&c.ID, &c.MeetingID, &from, &c.ToStatus, &by, &c.Reason, &c.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if from.Valid {
+			status := MeetingStatus(from.String)
+			c.FromStatus = &status
+		}
+		if by.Valid {
+			c.ChangedBy = &by.Int64
+		}
+		history = append(history, c)
+	}
+
+	return history, rows.Err()
+}
+
+func recordMeetingStatus(ctx context.Context, tx *sql.Tx, meetingID int64, from *MeetingStatus, to MeetingStatus, changedBy int64, reason string) error {
+	query := `
+		INSERT INTO meeting_status_history (meeting_id, from_status, to_status, changed_by, reason)
+		VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := tx.ExecContext(ctx, query, meetingID, from, to,
+		sql.NullInt64{Int64: changedBy, Valid: changedBy != 0}, reason)
+	return err
+}
@@ -11,19 +11,25 @@ import (
 
 var ErrNotFound = errors.New("resource not found")
 
+// DefaultCancellationWindowHours applies to mentors without a profile, who
+// haven't set their own window.
+const DefaultCancellationWindowHours = 24
+
 type Mentor struct {
-	ID          int64         `json:"id"`
-	Userid      int64         `json:"userid"`
-	Name        string        `json:"name"`
-	Country     string        `json:"country"`
-	Language    []string      `json:"language"`
-	Gigs        []Gig         `json:"gigs"`
-	Education   []Education   `json:"education"`
-	Experience  []Experience  `json:"experience"`
-	WorkingAt   *WorkingAt    `json:"workingat"`
-	BookingSlot []BookingSlot `json:"bookingslots"`
-	CreatedAt   string        `json:"created_at"`
-	UpdatedAt   string        `json:"updated_at"`
+	ID                      int64         `json:"id"`
+	Userid                  int64         `json:"userid"`
+	Name                    string        `json:"name"`
+	Country                 string        `json:"country"`
+	Language                []string      `json:"language"`
+	Timezone                string        `json:"timezone"`
+	CancellationWindowHours int           `json:"cancellation_window_hours"`
+	Gigs                    []Gig         `json:"gigs"`
+	Education               []Education   `json:"education"`
+	Experience              []Experience  `json:"experience"`
+	WorkingAt               *WorkingAt    `json:"workingat"`
+	BookingSlot             []BookingSlot `json:"bookingslots"`
+	CreatedAt               string        `json:"created_at"`
+	UpdatedAt               string        `json:"updated_at"`
 }
 
 type MentorStore struct {
@@ -44,10 +50,11 @@ func (s *MentorStore) CreateMentor(ctx context.Context, mentor *Mentor) error {
 
 	// Insert mentor basic details
 	err = tx.QueryRowContext(ctx, `
-		INSERT INTO mentors (userid, name, country, language)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO mentors (userid, name, country, language, timezone, cancellation_window_hours)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id, created_at, updated_at`,
-		mentor.Userid, mentor.Name, mentor.Country, pq.Array(mentor.Language)).Scan(
+		mentor.Userid, mentor.Name, mentor.Country, pq.Array(mentor.Language), mentor.Timezone,
+		mentor.CancellationWindowHours).Scan(
 		&mentor.ID, &mentor.CreatedAt, &mentor.UpdatedAt,
 	)
 	if err != nil {
@@ -67,7 +74,7 @@ func (s *MentorStore) GetAllMentors(ctx context.Context, limit, offset int) ([]*
 	}
 
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT id, userid, name, country, language, created_at, updated_at
+		SELECT id, userid, name, country, language, timezone, cancellation_window_hours, created_at, updated_at
 		FROM mentors
 		ORDER BY created_at DESC
 		LIMIT $1 OFFSET $2
@@ -81,7 +88,7 @@ func (s *MentorStore) GetAllMentors(ctx context.Context, limit, offset int) ([]*
 	for rows.Next() {
 		mentor := &Mentor{}
 		err := rows.Scan(
-			&mentor.ID, &mentor.Userid, &mentor.Name, &mentor.Country, pq.Array(&mentor.Language), &mentor.CreatedAt, &mentor.UpdatedAt,
+			&mentor.ID, &mentor.Userid, &mentor.Name, &mentor.Country, pq.Array(&mentor.Language), &mentor.Timezone, &mentor.CancellationWindowHours, &mentor.CreatedAt, &mentor.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -98,7 +105,7 @@ func (s *MentorStore) GetMentorByName(ctx context.Context, name string) ([]*Ment
 	defer cancel()
 
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT id, userid, name, country, language, created_at, updated_at
+		SELECT id, userid, name, country, language, timezone, cancellation_window_hours, created_at, updated_at
 		FROM mentors
 		WHERE name ILIKE $1
 		ORDER BY name`, "%"+name+"%")
@@ -112,7 +119,7 @@ func (s *MentorStore) GetMentorByName(ctx context.Context, name string) ([]*Ment
 		mentor := &Mentor{}
 		err := rows.Scan(
 			&mentor.ID, &mentor.Userid, &mentor.Name, &mentor.Country,
-			pq.Array(&mentor.Language), &mentor.CreatedAt, &mentor.UpdatedAt)
+			pq.Array(&mentor.Language), &mentor.Timezone, &mentor.CancellationWindowHours, &mentor.CreatedAt, &mentor.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -124,13 +131,13 @@ func (s *MentorStore) GetMentorByName(ctx context.Context, name string) ([]*Ment
 
 // GetMentorByID finds mentors by ID
 func (s *MentorStore) GetMentorByID(ctx context.Context, id int64) (*Mentor, error) {
-	query := `SELECT * FROM mentors WHERE id = $1`
+	query := `SELECT id, userid, name, country, language, timezone, cancellation_window_hours, created_at, updated_at FROM mentors WHERE id = $1`
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
 	var mentor Mentor
-	err := s.db.QueryRowContext(ctx, query, id).Scan(&mentor.ID, &mentor.Userid, &mentor.Name, &mentor.Country, pq.Array(&mentor.Language),
-		&mentor.CreatedAt, &mentor.UpdatedAt)
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&mentor.ID, &mentor.Userid, &mentor.Name, &mentor.Country, pq.Array(&mentor.Language), &mentor.Timezone,
+		&mentor.CancellationWindowHours, &mentor.CreatedAt, &mentor.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -142,7 +149,7 @@ func (s *MentorStore) GetMentorByID(ctx context.Context, id int64) (*Mentor, err
 func (s *MentorStore) GetMentorByUserID(ctx context.Context, userid int64) (*Mentor, error) {
 	mentor := &Mentor{}
 	err := s.db.QueryRow(`
-        SELECT id, userid, name, country, language, created_at, updated_at
+        SELECT id, userid, name, country, language, timezone, cancellation_window_hours, created_at, updated_at
         FROM mentors
         WHERE userid = $1`, userid).Scan(
 		&mentor.ID,
@@ -150,19 +157,21 @@ func (s *MentorStore) GetMentorByUserID(ctx context.Context, userid int64) (*Men
 		&mentor.Name,
 		&mentor.Country,
 		pq.Array(&mentor.Language),
+		&mentor.Timezone,
+		&mentor.CancellationWindowHours,
 		&mentor.CreatedAt,
 		&mentor.UpdatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("mentor with userid %d not found", userid)
+			return nil, ErrNotFound
 		}
 		return nil, err
 	}
 
 	// Fetch gigs
 	gigsRows, err := s.db.Query(`
-        SELECT id, title, amount,description, expertise, discipline, created_at, updated_at
+        SELECT id, title, amount, currency, description, expertise, discipline, created_at, updated_at
         FROM gigs
         WHERE userid = $1`, userid)
 	if err != nil {
@@ -176,6 +185,7 @@ func (s *MentorStore) GetMentorByUserID(ctx context.Context, userid int64) (*Men
 			&gig.ID,
 			&gig.Title,
 			&gig.Amount,
+			&gig.Currency,
 			&gig.Description,
 			&gig.Expertise,
 			pq.Array(&gig.Discipline),
@@ -247,7 +257,7 @@ func (s *MentorStore) GetMentorByUserID(ctx context.Context, userid int64) (*Men
 
 	// Fetch BookingSlot
 	bookingRows, err := s.db.Query(`
-        SELECT id, days, start_time, start_period, end_time, end_period
+        SELECT id, days, start_time, start_period, end_time, end_period, timezone
         FROM bookingslots
         WHERE userid = $1`, userid)
 	if err != nil {
@@ -257,7 +267,7 @@ func (s *MentorStore) GetMentorByUserID(ctx context.Context, userid int64) (*Men
 
 	for bookingRows.Next() {
 		slot := BookingSlot{}
-		err = bookingRows.Scan(&slot.ID, pq.Array(&slot.Days), &slot.StartTime, &slot.StartPeriod, &slot.EndTime, &slot.EndPeriod)
+		err = bookingRows.Scan(&slot.ID, pq.Array(&slot.Days), &slot.StartTime, &slot.StartPeriod, &slot.EndTime, &slot.EndPeriod, &slot.Timezone)
 		if err != nil {
 			return nil, err
 		}
@@ -271,7 +281,7 @@ func (s *MentorStore) GetMentorByUserID(ctx context.Context, userid int64) (*Men
 func (s *MentorStore) GetMentorsByExpertise(ctx context.Context, expertise string) ([]*Mentor, error) {
 	// First get all userIDs with matching expertise
 	rows, err := s.db.Query(`
-        SELECT DISTINCT m.id, m.userid, m.name, m.country, m.language, m.created_at, m.updated_at
+        SELECT DISTINCT m.id, m.userid, m.name, m.country, m.language, m.timezone, m.cancellation_window_hours, m.created_at, m.updated_at
         FROM mentors m
         JOIN gigs g ON m.userid = g.userid
         WHERE g.expertise = $1`, expertise)
@@ -292,6 +302,8 @@ func (s *MentorStore) GetMentorsByExpertise(ctx context.Context, expertise strin
 			&mentor.Name,
 			&mentor.Country,
 			pq.Array(&mentor.Language),
+			&mentor.Timezone,
+			&mentor.CancellationWindowHours,
 			&mentor.CreatedAt,
 			&mentor.UpdatedAt,
 		)
@@ -314,7 +326,7 @@ func (s *MentorStore) GetMentorsByExpertise(ctx context.Context, expertise strin
 
 	// Fetch gigs
 	gigsRows, err := s.db.Query(`
-        SELECT id, userid, title, amount,description, expertise, discipline, created_at, updated_at
+        SELECT id, userid, title, amount, currency, description, expertise, discipline, created_at, updated_at
         FROM gigs
         WHERE userid = ANY($1) AND expertise = $2`, pq.Array(userIDs), expertise)
 	if err != nil {
@@ -330,6 +342,7 @@ func (s *MentorStore) GetMentorsByExpertise(ctx context.Context, expertise strin
 			&userID,
 			&gig.Title,
 			&gig.Amount,
+			&gig.Currency,
 			&gig.Description,
 			&gig.Expertise,
 			pq.Array(&gig.Discipline),
@@ -412,7 +425,7 @@ func (s *MentorStore) GetMentorsByExpertise(ctx context.Context, expertise strin
 
 	// Fetch booking slot
 	bookingRows, err := s.db.Query(`
-        SELECT id,userid, days, start_time, start_period, end_time, end_period
+        SELECT id, userid, days, start_time, start_period, end_time, end_period, timezone
         FROM bookingslots
         WHERE userid = ANY($1)`, pq.Array(userIDs))
 	if err != nil {
@@ -423,7 +436,7 @@ func (s *MentorStore) GetMentorsByExpertise(ctx context.Context, expertise strin
 	for bookingRows.Next() {
 		slot := BookingSlot{}
 		var userID int64
-		err = bookingRows.Scan(&slot.ID, &userID, pq.Array(&slot.Days), &slot.StartTime, &slot.StartPeriod, &slot.EndTime, &slot.EndPeriod)
+		err = bookingRows.Scan(&slot.ID, &userID, pq.Array(&slot.Days), &slot.StartTime, &slot.StartPeriod, &slot.EndTime, &slot.EndPeriod, &slot.Timezone)
 		if err != nil {
 			return nil, err
 		}
@@ -438,7 +451,7 @@ func (s *MentorStore) GetMentorsByExpertise(ctx context.Context, expertise strin
 func (s *MentorStore) GetMentorsByDiscipline(ctx context.Context, discipline string) ([]*Mentor, error) {
 	// First get all mentors with matching discipline using array contains operator
 	rows, err := s.db.Query(`
-        SELECT DISTINCT m.id, m.userid, m.name, m.country, m.language, m.created_at, m.updated_at
+        SELECT DISTINCT m.id, m.userid, m.name, m.country, m.language, m.timezone, m.cancellation_window_hours, m.created_at, m.updated_at
         FROM mentors m
         JOIN gigs g ON m.userid = g.userid
         WHERE $1 = ANY(g.discipline)`, discipline)
@@ -459,6 +472,8 @@ func (s *MentorStore) GetMentorsByDiscipline(ctx context.Context, discipline str
 			&mentor.Name,
 			&mentor.Country,
 			pq.Array(&mentor.Language),
+			&mentor.Timezone,
+			&mentor.CancellationWindowHours,
 			&mentor.CreatedAt,
 			&mentor.UpdatedAt,
 		)
@@ -481,7 +496,7 @@ func (s *MentorStore) GetMentorsByDiscipline(ctx context.Context, discipline str
 
 	// Fetch gigs (only those with matching discipline)
 	gigsRows, err := s.db.Query(`
-        SELECT id, userid, title, amount,description, expertise, discipline, created_at, updated_at
+        SELECT id, userid, title, amount, currency, description, expertise, discipline, created_at, updated_at
         FROM gigs
         WHERE userid = ANY($1) AND $2 = ANY(discipline)`, pq.Array(userIDs), discipline)
 	if err != nil {
@@ -497,6 +512,7 @@ func (s *MentorStore) GetMentorsByDiscipline(ctx context.Context, discipline str
 			&userID,
 			&gig.Title,
 			&gig.Amount,
+			&gig.Currency,
 			&gig.Description,
 			&gig.Expertise,
 			pq.Array(&gig.Discipline),
@@ -579,7 +595,7 @@ func (s *MentorStore) GetMentorsByDiscipline(ctx context.Context, discipline str
 
 	// Fetch bookingslot
 	bookingRows, err := s.db.Query(`
-        SELECT id, userid, days, start_time, start_period, end_time, end_period
+        SELECT id, userid, days, start_time, start_period, end_time, end_period, timezone
         FROM bookingslots
         WHERE userid = ANY($1)`, pq.Array(userIDs))
 	if err != nil {
@@ -590,7 +606,7 @@ func (s *MentorStore) GetMentorsByDiscipline(ctx context.Context, discipline str
 	for bookingRows.Next() {
 		slot := BookingSlot{}
 		var userID int64
-		err = bookingRows.Scan(&slot.ID, &userID, pq.Array(&slot.Days), &slot.StartTime, &slot.StartPeriod, &slot.EndTime, &slot.EndPeriod)
+		err = bookingRows.Scan(&slot.ID, &userID, pq.Array(&slot.Days), &slot.StartTime, &slot.StartPeriod, &slot.EndTime, &slot.EndPeriod, &slot.Timezone)
 		if err != nil {
 			return nil, err
 		}
@@ -681,9 +697,9 @@ func (s *MentorStore) UpdateMentor(ctx context.Context, mentor *Mentor) error {
 	// Update basic details
 	_, err = tx.ExecContext(ctx, `
 		UPDATE mentors 
-		SET name = $1, country = $2, language = $3, updated_at = NOW()
-		WHERE id = $4
-	`, mentor.Name, mentor.Country, pq.Array(mentor.Language), mentor.ID)
+		SET name = $1, country = $2, language = $3, timezone = $4, cancellation_window_hours = $5, updated_at = NOW()
+		WHERE id = $6
+	`, mentor.Name, mentor.Country, pq.Array(mentor.Language), mentor.Timezone, mentor.CancellationWindowHours, mentor.ID)
 	if err != nil {
 		return err
 	}
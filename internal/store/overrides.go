@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	OverrideBlocked = "blocked"
+	OverrideExtra   = "extra"
+)
+
+// AvailabilityOverride is a one-off change to a mentor's weekly schedule:
+// either time off or extra hours.
+type AvailabilityOverride struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"userid"`
+	Kind      string    `json:"kind"`
+	StartAt   time.Time `json:"start_at"`
+	EndAt     time.Time `json:"end_at"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type OverrideStore struct {
+	db *sql.DB
+}
+
+// CreateOverride holds the mentor's lock while it adds the override, so a
+// booking being checked against the schedule sees it or comes first.
+func (s *OverrideStore) CreateOverride(ctx context.Context, override *AvailabilityOverride) error {
+	query := `INSERT INTO availability_overrides (userid, kind, start_at, end_at, reason)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := lockMentor(ctx, tx, override.UserID); err != nil {
+			return err
+		}
+
+		return tx.QueryRowContext(ctx, query, override.UserID, override.Kind, override.StartAt,
+			override.EndAt, override.Reason).Scan(&override.ID, &override.CreatedAt)
+	})
+}
+
+func (s *OverrideStore) GetOverrideByID(ctx context.Context, id int64) (*AvailabilityOverride, error) {
+	query := `SELECT id, userid, kind, start_at, end_at, reason, created_at
+		FROM availability_overrides
+		WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	o := &AvailabilityOverride{}
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&o.ID, &o.UserID, &o.Kind, &o.StartAt, &o.EndAt,
+		&o.Reason, &o.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// GetOverridesByUserID returns the user's overrides overlapping [from, to).
+func (s *OverrideStore) GetOverridesByUserID(ctx context.Context, userid int64, from, to time.Time) ([]*AvailabilityOverride, error) {
+	query := `SELECT id, userid, kind, start_at, end_at, reason, created_at
+		FROM availability_overrides
+		WHERE userid = $1 AND start_at < $3 AND end_at > $2
+		ORDER BY start_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userid, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overrides := []*AvailabilityOverride{}
+	for rows.Next() {
+		o := &AvailabilityOverride{}
+		err := rows.Scan(&o.ID, &o.UserID, &o.Kind, &o.StartAt, &o.EndAt, &o.Reason, &o.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, o)
+	}
+
+	return overrides, rows.Err()
+}
+
+func (s *OverrideStore) DeleteOverride(ctx context.Context, id int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := lockOwner(ctx, tx, `SELECT userid FROM availability_overrides WHERE id = $1`, id); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `DELETE FROM availability_overrides WHERE id = $1`, id)
+		return err
+	})
+}
@@ -0,0 +1,352 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrNoCredits = errors.New("no session credits left with this mentor")
+
+const (
+	PurchasePending = "pending"
+	PurchasePaid    = "paid"
+	PurchaseExpired = "expired"
+)
+
+// Package sells Sessions of a gig for Amount. Credits from a package with
+// ValidDays lapse that many days after purchase.
+type Package struct {
+	ID          int64     `json:"id"`
+	GigID       int64     `json:"gig_id"`
+	Userid      int64     `json:"userid"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Sessions    int       `json:"sessions"`
+	Amount      float64   `json:"amount"`
+	Currency    string    `json:"currency"`
+	ValidDays   *int      `json:"valid_days"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Purchase is a mentee buying a package. Once paid, its sessions not yet
+// used are credits the mentee books the mentor with. Amounts are in the
+// currency's minor unit; SessionAmount is the mentor's price within Amount.
+type Purchase struct {
+	ID                int64      `json:"id"`
+	PackageID         int64      `json:"package_id"`
+	BuyerID           int64      `json:"buyer_id"`
+	MentorID          int64      `json:"mentor_id"`
+	GigID             *int64     `json:"gig_id"`
+	Sessions          int        `json:"sessions"`
+	CreditsUsed       int        `json:"credits_used"`
+	ValidDays         *int       `json:"valid_days"`
+	Provider          string     `json:"provider"`
+	CheckoutSessionID string     `json:"checkout_session_id"`
+	Amount            int64      `json:"amount"`
+	SessionAmount     int64      `json:"session_amount"`
+	CommissionRate    float64    `json:"commission_rate"`
+	Currency          string     `json:"currency"`
+	Status            string     `json:"status"`
+	ExpiresAt         *time.Time `json:"expires_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+	PaidAt            *time.Time `json:"paid_at"`
+}
+
+// Credits is how many sessions a mentee has left with one mentor.
+// ExpiresAt is when the next of them lapse, nil if none do.
+type Credits struct {
+	MentorID  int64      `json:"mentor_id"`
+	Remaining int        `json:"remaining"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type PackageStore struct {
+	db *sql.DB
+}
+
+func (s *PackageStore) CreatePackage(ctx context.Context, pkg *Package) error {
+	query := `
+		INSERT INTO packages (gig_id, userid, title, description, sessions, amount, currency, valid_days)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, active, created_at`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, pkg.GigID, pkg.Userid, pkg.Title, pkg.Description, pkg.Sessions,
+		pkg.Amount, pkg.Currency, pkg.ValidDays).Scan(&pkg.ID, &pkg.Active, &pkg.CreatedAt)
+}
+
+func (s *PackageStore) GetPackageByID(ctx context.Context, id int64) (*Package, error) {
+	query := `
+		SELECT id, gig_id, userid, title, description, sessions, amount, currency, valid_days, active, created_at
+		FROM packages
+		WHERE id = $1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	pkg, err := scanPackage(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return pkg, nil
+}
+
+// GetPackagesByUserID returns the packages a mentor still sells, smallest
+// first.
+func (s *PackageStore) GetPackagesByUserID(ctx context.Context, userID int64) ([]*Package, error) {
+	query := `
+		SELECT id, gig_id, userid, title, description, sessions, amount, currency, valid_days, active, created_at
+		FROM packages
+		WHERE userid = $1 AND active
+		ORDER BY sessions, amount, id`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	packages := []*Package{}
+	for rows.Next() {
+		pkg, err := scanPackage(rows)
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, pkg)
+	}
+
+	return packages, rows.Err()
+}
+
+// DeactivatePackage takes the package off sale. Credits already bought
+// stay usable.
+func (s *PackageStore) DeactivatePackage(ctx context.Context, id int64) error {
+	query := `UPDATE packages SET active = FALSE WHERE id = $1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *PackageStore) CreatePurchase(ctx context.Context, purchase *Purchase) error {
+	query := `
+		INSERT INTO package_purchases (package_id, buyer_id, mentor_id, gig_id, sessions, valid_days, provider,
+			checkout_session_id, amount, session_amount, commission_rate, currency)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id, status, created_at`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, purchase.PackageID, purchase.BuyerID, purchase.MentorID, purchase.GigID,
+		purchase.Sessions, purchase.ValidDays, purchase.Provider, purchase.CheckoutSessionID, purchase.Amount,
+		purchase.SessionAmount, purchase.CommissionRate, purchase.Currency).Scan(
+		&purchase.ID, &purchase.Status, &purchase.CreatedAt)
+}
+
+// CompletePurchase marks the purchase made through the payment's checkout
+// paid, which makes its credits usable and starts their validity. Replaying
+// the same session is a no-op.
+func (s *PackageStore) CompletePurchase(ctx context.Context, payment MeetingPayment) error {
+	query := `
+		UPDATE package_purchases
+		SET status = 'paid', payment_intent_id = $1, amount = $2, currency = $3, paid_at = NOW(),
+			expires_at = NOW() + make_interval(days => valid_days)
+		WHERE checkout_session_id = $4 AND status <> 'paid'`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, payment.PaymentIntentID, payment.Amount, payment.Currency,
+		payment.SessionID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows > 0 {
+		return nil
+	}
+
+	var exists bool
+	err = s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM package_purchases WHERE checkout_session_id = $1)`,
+		payment.SessionID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// ExpirePurchase closes out a purchase whose checkout was abandoned.
+func (s *PackageStore) ExpirePurchase(ctx context.Context, sessionID string) error {
+	query := `
+		UPDATE package_purchases
+		SET status = 'expired'
+		WHERE checkout_session_id = $1 AND status = 'pending'`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, sessionID)
+	return err
+}
+
+// GetCredits returns the credits a mentee has left, per mentor.
+func (s *PackageStore) GetCredits(ctx context.Context, buyerID int64) ([]*Credits, error) {
+	query := `
+		SELECT mentor_id, SUM(sessions - credits_used), MIN(expires_at)
+		FROM package_purchases
+		WHERE buyer_id = $1 AND status = 'paid' AND credits_used < sessions
+			AND (expires_at IS NULL OR expires_at > NOW())
+		GROUP BY mentor_id
+		ORDER BY mentor_id`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, buyerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credits := []*Credits{}
+	for rows.Next() {
+		c := &Credits{}
+		if err := rows.Scan(&c.MentorID, &c.Remaining, &c.ExpiresAt); err != nil {
+			return nil, err
+		}
+		credits = append(credits, c)
+	}
+
+	return credits, rows.Err()
+}
+
+// GetCreditPurchase picks the purchase a new booking with the mentor draws
+// its credit from: the one that lapses first.
+func (s *PackageStore) GetCreditPurchase(ctx context.Context, buyerID, mentorID int64) (*Purchase, error) {
+	query := `
+		SELECT id, package_id, buyer_id, mentor_id, gig_id, sessions, credits_used, valid_days, provider,
+			checkout_session_id, amount, session_amount, commission_rate, currency, status, expires_at,
+			created_at, paid_at
+		FROM package_purchases
+		WHERE buyer_id = $1 AND mentor_id = $2 AND status = 'paid' AND credits_used < sessions
+			AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY expires_at NULLS LAST, id
+		LIMIT 1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	p := &Purchase{}
+	err := s.db.QueryRowContext(ctx, query, buyerID, mentorID).Scan(&p.ID, &p.PackageID, &p.BuyerID, &p.MentorID,
+		&p.GigID, &p.Sessions, &p.CreditsUsed, &p.ValidDays, &p.Provider, &p.CheckoutSessionID, &p.Amount,
+		&p.SessionAmount, &p.CommissionRate, &p.Currency, &p.Status, &p.ExpiresAt, &p.CreatedAt, &p.PaidAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNoCredits
+		}
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// useCredit spends one credit of the purchase, failing with ErrNoCredits
+// when it has none left.
+func useCredit(ctx context.Context, tx *sql.Tx, purchaseID int64) error {
+	res, err := tx.ExecContext(ctx, `
+		UPDATE package_purchases
+		SET credits_used = credits_used + 1
+		WHERE id = $1 AND status = 'paid' AND credits_used < sessions
+			AND (expires_at IS NULL OR expires_at > NOW())`, purchaseID)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoCredits
+	}
+	return nil
+}
+
+// settleCredit follows a credit-booked meeting moving to status, changed by
+// changedBy: confirming it needs no payment, so it is paid straight away, and
+// cancelling or refunding it gives the credit back. Like a cash refund, a
+// mentee cancelling within the mentor's cancellation window loses it; a
+// credit can't be split, so none of it comes back, unless the meeting is
+// refunded later.
+func settleCredit(ctx context.Context, tx *sql.Tx, meetingID int64, status MeetingStatus, changedBy int64) error {
+	if status != MeetingConfirmed && status != MeetingCancelled && status != MeetingRefunded {
+		return nil
+	}
+
+	var (
+		purchaseID  sql.NullInt64
+		menteeID    int64
+		startAt     time.Time
+		windowHours int
+	)
+	err := tx.QueryRowContext(ctx, `
+		SELECT m.purchase_id, m.userid, m.start_at, COALESCE(mt.cancellation_window_hours, $2)
+		FROM meetings m
+		LEFT JOIN mentors mt ON mt.userid = m.mentorid
+		WHERE m.id = $1`, meetingID, DefaultCancellationWindowHours).Scan(&purchaseID, &menteeID, &startAt, &windowHours)
+	if err != nil || !purchaseID.Valid {
+		return err
+	}
+
+	if status == MeetingConfirmed {
+		return transitionMeeting(ctx, tx, meetingID, MeetingConfirmed, MeetingPaid, 0, "paid with a package credit")
+	}
+
+	if status == MeetingCancelled && changedBy == menteeID && time.Until(startAt) < time.Duration(windowHours)*time.Hour {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE package_purchases SET credits_used = credits_used - 1 WHERE id = $1 AND credits_used > 0`,
+		purchaseID.Int64)
+	if err != nil {
+		return err
+	}
+	// the credit only comes back once
+	_, err = tx.ExecContext(ctx, `UPDATE meetings SET purchase_id = NULL WHERE id = $1`, meetingID)
+	return err
+}
+
+func scanPackage(row scanner) (*Package, error) {
+	p := &Package{}
+	err := row.Scan(&p.ID, &p.GigID, &p.Userid, &p.Title, &p.Description, &p.Sessions, &p.Amount, &p.Currency,
+		&p.ValidDays, &p.Active, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
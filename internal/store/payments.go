@@ -0,0 +1,525 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	PaymentPending           = "pending"
+	PaymentSucceeded         = "succeeded"
+	PaymentFailed            = "failed"
+	PaymentExpired           = "expired"
+	PaymentPartiallyRefunded = "partially_refunded"
+	PaymentRefunded          = "refunded"
+	PaymentDisputed          = "disputed"
+)
+
+// Payment is one checkout attempt for a meeting. Amounts are in the
+// currency's minor unit, as the provider reports them. SessionAmount is the
+// mentor's price within Amount, which CommissionRate is taken from; the rest
+// is FeeAmount and TaxAmount. An Unapplied payment came in for a meeting that
+// was already paid or could no longer be, and is owed back in full.
+type Payment struct {
+	ID                int64      `json:"id"`
+	MeetingID         int64      `json:"meeting_id"`
+	PayerID           int64      `json:"payer_id"`
+	PayeeID           int64      `json:"payee_id"`
+	Provider          string     `json:"provider"`
+	CheckoutSessionID string     `json:"checkout_session_id"`
+	PaymentIntentID   string     `json:"payment_intent_id"`
+	Amount            int64      `json:"amount"`
+	RefundedAmount    int64      `json:"refunded_amount"`
+	SessionAmount     int64      `json:"session_amount"`
+	CommissionRate    float64    `json:"commission_rate"`
+	FeeAmount         int64      `json:"fee_amount"`
+	TaxAmount         int64      `json:"tax_amount"`
+	Currency          string     `json:"currency"`
+	Status            string     `json:"status"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	PaidAt            *time.Time `json:"paid_at"`
+	DisputedAt        *time.Time `json:"disputed_at"`
+	Unapplied         bool       `json:"unapplied"`
+}
+
+// Refundable is what is left of the payment to give back.
+func (p *Payment) Refundable() int64 {
+	if p.PaidAt == nil {
+		return 0
+	}
+	return p.Amount - p.RefundedAmount
+}
+
+// Refund is money sent back to the payer through the API. RequestedBy is nil
+// when the system issued it.
+type Refund struct {
+	ID               int64     `json:"id"`
+	PaymentID        int64     `json:"payment_id"`
+	ProviderRefundID string    `json:"provider_refund_id"`
+	Amount           int64     `json:"amount"`
+	Currency         string    `json:"currency"`
+	Reason           string    `json:"reason"`
+	RequestedBy      *int64    `json:"requested_by"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// EarningItem is what a mentor made from one meeting payment, or from one
+// package purchase when PurchaseID is set. Gross is their price less its
+// share of any refunds, in the currency's minor unit.
+type EarningItem struct {
+	PaymentID     int64
+	PurchaseID    int64
+	MeetingID     int64
+	MeetingStatus MeetingStatus
+	// Refunded is set once any of the payment was refunded
+	Refunded       bool
+	Currency       string
+	Gross          int64
+	CommissionRate float64
+	PaidAt         time.Time
+}
+
+type PaymentStore struct {
+	db *sql.DB
+}
+
+func (s *PaymentStore) CreatePayment(ctx context.Context, payment *Payment) error {
+	query := `
+		INSERT INTO payments (meeting_id, payer_id, payee_id, provider, checkout_session_id, amount, session_amount,
+			commission_rate, fee_amount, tax_amount, currency, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id, created_at, updated_at`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	if payment.Provider == "" {
+		payment.Provider = "stripe"
+	}
+	if payment.Status == "" {
+		payment.Status = PaymentPending
+	}
+
+	return s.db.QueryRowContext(ctx, query, payment.MeetingID, payment.PayerID, payment.PayeeID,
+		payment.Provider, payment.CheckoutSessionID, payment.Amount, payment.SessionAmount, payment.CommissionRate,
+		payment.FeeAmount, payment.TaxAmount, payment.Currency, payment.Status).Scan(
+		&payment.ID, &payment.CreatedAt, &payment.UpdatedAt)
+}
+
+func (s *PaymentStore) GetPaymentByID(ctx context.Context, id int64) (*Payment, error) {
+	query := `
+		SELECT id, meeting_id, payer_id, payee_id, provider, checkout_session_id, COALESCE(payment_intent_id, ''),
+			amount, refunded_amount, COALESCE(session_amount, amount), commission_rate, fee_amount, tax_amount, currency, status,
+			created_at, updated_at, paid_at, disputed_at, unapplied
+		FROM payments
+		WHERE id = $1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	payment, err := scanPayment(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+func (s *PaymentStore) GetPaymentBySessionID(ctx context.Context, sessionID string) (*Payment, error) {
+	query := `
+		SELECT id, meeting_id, payer_id, payee_id, provider, checkout_session_id, COALESCE(payment_intent_id, ''),
+			amount, refunded_amount, COALESCE(session_amount, amount), commission_rate, fee_amount, tax_amount, currency, status,
+			created_at, updated_at, paid_at, disputed_at, unapplied
+		FROM payments
+		WHERE checkout_session_id = $1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	payment, err := scanPayment(s.db.QueryRowContext(ctx, query, sessionID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// GetPaymentsByUserID returns payments the user made or received, newest
+// first.
+func (s *PaymentStore) GetPaymentsByUserID(ctx context.Context, userID int64, limit, offset int) ([]*Payment, error) {
+	query := `
+		SELECT id, meeting_id, payer_id, payee_id, provider, checkout_session_id, COALESCE(payment_intent_id, ''),
+			amount, refunded_amount, COALESCE(session_amount, amount), commission_rate, fee_amount, tax_amount, currency, status,
+			created_at, updated_at, paid_at, disputed_at, unapplied
+		FROM payments
+		WHERE payer_id = $1 OR payee_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []*Payment{}
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+
+	return payments, rows.Err()
+}
+
+// CompleteCheckout records a successful checkout in the ledger and moves the
+// meeting to paid, in one transaction. Sessions created before the ledger
+// existed get their row here.
+func (s *PaymentStore) CompleteCheckout(ctx context.Context, meetingID int64, payment MeetingPayment) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := markMeetingPaid(ctx, tx, meetingID, payment); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO payments (meeting_id, payer_id, payee_id, checkout_session_id, payment_intent_id, amount, currency, status, paid_at)
+			SELECT id, userid::BIGINT, mentorid::BIGINT, $2, $3, $4, $5, 'succeeded', NOW()
+			FROM meetings
+			WHERE id = $1
+			ON CONFLICT (checkout_session_id) DO UPDATE
+			SET payment_intent_id = EXCLUDED.payment_intent_id,
+				amount = EXCLUDED.amount,
+				currency = EXCLUDED.currency,
+				status = 'succeeded',
+				paid_at = COALESCE(payments.paid_at, NOW()),
+				updated_at = NOW()`,
+			meetingID, payment.SessionID, payment.PaymentIntentID, payment.Amount, payment.Currency)
+		return err
+	})
+}
+
+// RecordUnappliedPayment records a checkout that completed when the meeting
+// could no longer be paid with it, leaving the meeting as it is. Recording
+// the same checkout twice returns the payment recorded the first time.
+func (s *PaymentStore) RecordUnappliedPayment(ctx context.Context, meetingID int64, payment MeetingPayment) (*Payment, error) {
+	query := `
+		INSERT INTO payments (meeting_id, payer_id, payee_id, checkout_session_id, payment_intent_id, amount, currency,
+			status, paid_at, unapplied)
+		SELECT id, userid::BIGINT, mentorid::BIGINT, $2, $3, $4, $5, 'succeeded', NOW(), TRUE
+		FROM meetings
+		WHERE id = $1
+		ON CONFLICT (checkout_session_id) DO UPDATE
+		SET payment_intent_id = EXCLUDED.payment_intent_id,
+			amount = EXCLUDED.amount,
+			currency = EXCLUDED.currency,
+			status = CASE WHEN payments.paid_at IS NULL THEN 'succeeded' ELSE payments.status END,
+			paid_at = COALESCE(payments.paid_at, NOW()),
+			unapplied = TRUE,
+			updated_at = NOW()
+		RETURNING id`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var id int64
+	err := s.db.QueryRowContext(ctx, query, meetingID, payment.SessionID, payment.PaymentIntentID,
+		payment.Amount, payment.Currency).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return s.GetPaymentByID(ctx, id)
+}
+
+// GetPaidPaymentByMeetingID returns the payment that paid for the meeting.
+func (s *PaymentStore) GetPaidPaymentByMeetingID(ctx context.Context, meetingID int64) (*Payment, error) {
+	query := `
+		SELECT id, meeting_id, payer_id, payee_id, provider, checkout_session_id, COALESCE(payment_intent_id, ''),
+			amount, refunded_amount, COALESCE(session_amount, amount), commission_rate, fee_amount, tax_amount, currency, status,
+			created_at, updated_at, paid_at, disputed_at, unapplied
+		FROM payments
+		WHERE meeting_id = $1 AND paid_at IS NOT NULL AND NOT unapplied
+		ORDER BY paid_at DESC, id DESC
+		LIMIT 1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	payment, err := scanPayment(s.db.QueryRowContext(ctx, query, meetingID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// GetPendingPaymentByMeetingID returns the meeting's newest checkout that
+// is still waiting to be paid.
+func (s *PaymentStore) GetPendingPaymentByMeetingID(ctx context.Context, meetingID int64) (*Payment, error) {
+	query := `
+		SELECT id, meeting_id, payer_id, payee_id, provider, checkout_session_id, COALESCE(payment_intent_id, ''),
+			amount, refunded_amount, COALESCE(session_amount, amount), commission_rate, fee_amount, tax_amount, currency, status,
+			created_at, updated_at, paid_at, disputed_at, unapplied
+		FROM payments
+		WHERE meeting_id = $1 AND status = 'pending'
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	payment, err := scanPayment(s.db.QueryRowContext(ctx, query, meetingID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// RecordRefund stores a refund the provider accepted and adds it to the
+// payment. Once the payment is fully refunded the meeting moves to refunded
+// when its status allows. Recording the same refund twice is a no-op.
+func (s *PaymentStore) RecordRefund(ctx context.Context, refund *Refund) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		meetingID, err := lockPaymentMeeting(ctx, tx, `id = $1`, refund.PaymentID)
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO refunds (payment_id, provider_refund_id, amount, currency, reason, requested_by)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (provider_refund_id) DO NOTHING
+			RETURNING id, created_at`,
+			refund.PaymentID, refund.ProviderRefundID, refund.Amount, refund.Currency, refund.Reason, refund.RequestedBy).
+			Scan(&refund.ID, &refund.CreatedAt)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE payments SET refunded_amount = LEAST(amount, refunded_amount + $1) WHERE id = $2`,
+			refund.Amount, refund.PaymentID)
+		if err != nil {
+			return err
+		}
+
+		var by int64
+		if refund.RequestedBy != nil {
+			by = *refund.RequestedBy
+		}
+		return settleRefunds(ctx, tx, refund.PaymentID, meetingID, by)
+	})
+}
+
+// SyncRefundedAmount brings the ledger up to the total the provider reports
+// refunded for a payment intent, catching refunds made outside the API.
+func (s *PaymentStore) SyncRefundedAmount(ctx context.Context, paymentIntentID string, refunded int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		meetingID, err := lockPaymentMeeting(ctx, tx, `payment_intent_id = $1`, paymentIntentID)
+		if err != nil {
+			return err
+		}
+
+		var paymentID int64
+		err = tx.QueryRowContext(ctx, `
+			UPDATE payments SET refunded_amount = LEAST(amount, GREATEST(refunded_amount, $1))
+			WHERE payment_intent_id = $2
+			RETURNING id`, refunded, paymentIntentID).Scan(&paymentID)
+		if err != nil {
+			return err
+		}
+
+		return settleRefunds(ctx, tx, paymentID, meetingID, 0)
+	})
+}
+
+// MarkPaymentDisputed flags the payment behind a payment intent as disputed
+// by the payer's bank.
+func (s *PaymentStore) MarkPaymentDisputed(ctx context.Context, paymentIntentID string) error {
+	query := `
+		UPDATE payments
+		SET status = 'disputed', disputed_at = COALESCE(disputed_at, NOW()), updated_at = NOW()
+		WHERE payment_intent_id = $1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, paymentIntentID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// ExpireCheckout marks a checkout session that was never paid as expired.
+// Sessions that went on to be paid are left alone.
+func (s *PaymentStore) ExpireCheckout(ctx context.Context, sessionID string) error {
+	query := `
+		UPDATE payments
+		SET status = 'expired', updated_at = NOW()
+		WHERE checkout_session_id = $1 AND status = 'pending'`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, sessionID)
+	return err
+}
+
+// FailCheckout marks a checkout session whose payment failed after it was
+// completed. The meeting stays confirmed so the mentee can pay again.
+func (s *PaymentStore) FailCheckout(ctx context.Context, sessionID string) error {
+	query := `
+		UPDATE payments
+		SET status = 'failed', updated_at = NOW()
+		WHERE checkout_session_id = $1 AND status = 'pending'`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, sessionID)
+	return err
+}
+
+// lockPaymentMeeting finds the meeting of the payment matching where and
+// locks it, so refunds and checkouts take their locks in the same order.
+func lockPaymentMeeting(ctx context.Context, tx *sql.Tx, where string, arg any) (int64, error) {
+	var meetingID int64
+	err := tx.QueryRowContext(ctx, `SELECT meeting_id FROM payments WHERE `+where, arg).Scan(&meetingID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+
+	if _, err := lockMeetingStatus(ctx, tx, meetingID); err != nil {
+		return 0, err
+	}
+	return meetingID, nil
+}
+
+// settleRefunds sets the payment's status from how much of it was refunded
+// and moves the meeting to refunded once all of it was.
+func settleRefunds(ctx context.Context, tx *sql.Tx, paymentID, meetingID, changedBy int64) error {
+	var amount, refunded int64
+	var unapplied bool
+	err := tx.QueryRowContext(ctx, `
+		UPDATE payments
+		SET status = CASE
+				WHEN refunded_amount >= amount THEN 'refunded'
+				WHEN refunded_amount > 0 THEN 'partially_refunded'
+				ELSE status
+			END,
+			updated_at = NOW()
+		WHERE id = $1
+		RETURNING amount, refunded_amount, unapplied`, paymentID).Scan(&amount, &refunded, &unapplied)
+	if err != nil {
+		return err
+	}
+	if refunded < amount || unapplied {
+		// the meeting was never paid with this payment
+		return nil
+	}
+
+	current, err := lockMeetingStatus(ctx, tx, meetingID)
+	if err != nil {
+		return err
+	}
+	if !current.CanTransitionTo(MeetingRefunded) {
+		// the money is back either way; the meeting keeps its outcome
+		return nil
+	}
+	return transitionMeeting(ctx, tx, meetingID, current, MeetingRefunded, changedBy, "payment refunded")
+}
+
+// GetEarningItems returns the payments and package purchases a mentor kept
+// money from, paid in [from, to). A zero from or to leaves that end open.
+func (s *PaymentStore) GetEarningItems(ctx context.Context, mentorID int64, from, to time.Time) ([]*EarningItem, error) {
+	query := `
+		SELECT p.id, 0, p.meeting_id, m.status, p.refunded_amount > 0, p.currency,
+			COALESCE(COALESCE(p.session_amount, p.amount) * (p.amount - p.refunded_amount) / NULLIF(p.amount, 0), 0),
+			p.commission_rate, p.paid_at
+		FROM payments p
+		JOIN meetings m ON m.id = p.meeting_id
+		WHERE p.payee_id = $1
+			AND p.status IN ('succeeded', 'partially_refunded')
+			AND NOT p.unapplied
+			AND ($2::TIMESTAMPTZ IS NULL OR p.paid_at >= $2)
+			AND ($3::TIMESTAMPTZ IS NULL OR p.paid_at < $3)
+		UNION ALL
+		SELECT 0, pp.id, 0, '', FALSE, pp.currency, pp.session_amount, pp.commission_rate, pp.paid_at
+		FROM package_purchases pp
+		WHERE pp.mentor_id = $1
+			AND pp.status = 'paid'
+			AND ($2::TIMESTAMPTZ IS NULL OR pp.paid_at >= $2)
+			AND ($3::TIMESTAMPTZ IS NULL OR pp.paid_at < $3)
+		ORDER BY paid_at`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, mentorID,
+		sql.NullTime{Time: from, Valid: !from.IsZero()}, sql.NullTime{Time: to, Valid: !to.IsZero()})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*EarningItem{}
+	for rows.Next() {
+		item := &EarningItem{}
+		err := rows.Scan(&item.PaymentID, &item.PurchaseID, &item.MeetingID, &item.MeetingStatus, &item.Refunded, &item.Currency, &item.Gross,
+			&item.CommissionRate, &item.PaidAt)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+func scanPayment(row scanner) (*Payment, error) {
+	p := &Payment{}
+	err := row.Scan(&p.ID, &p.MeetingID, &p.PayerID, &p.PayeeID, &p.Provider, &p.CheckoutSessionID,
+		&p.PaymentIntentID, &p.Amount, &p.RefundedAmount, &p.SessionAmount, &p.CommissionRate, &p.FeeAmount, &p.TaxAmount, &p.Currency, &p.Status,
+		&p.CreatedAt, &p.UpdatedAt, &p.PaidAt, &p.DisputedAt, &p.Unapplied)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
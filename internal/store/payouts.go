@@ -0,0 +1,178 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrInsufficientBalance = errors.New("payout is more than the available balance")
+	ErrPayoutPending       = errors.New("a payout request in this currency is already waiting for review")
+	ErrPayoutDecided       = errors.New("payout was already reviewed")
+)
+
+const (
+	PayoutRequested = "requested"
+	PayoutApproved  = "approved"
+	PayoutRejected  = "rejected"
+)
+
+// Payout is a mentor asking to be paid what they earned, in the currency's
+// minor unit.
+type Payout struct {
+	ID        int64      `json:"id"`
+	MentorID  int64      `json:"mentor_id"`
+	Amount    int64      `json:"amount"`
+	Currency  string     `json:"currency"`
+	Status    string     `json:"status"`
+	Note      string     `json:"note"`
+	DecidedBy *int64     `json:"decided_by"`
+	DecidedAt *time.Time `json:"decided_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type PayoutStore struct {
+	db *sql.DB
+}
+
+// CreatePayout files a payout request if the mentor has available left
+// after the payouts already requested or approved in that currency.
+func (s *PayoutStore) CreatePayout(ctx context.Context, payout *Payout, available int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		// serialize a mentor's requests so two can't spend the same balance
+		_, err := tx.ExecContext(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, payout.MentorID)
+		if err != nil {
+			return err
+		}
+
+		var committed int64
+		err = tx.QueryRowContext(ctx, `
+			SELECT COALESCE(SUM(amount), 0) FROM payouts
+			WHERE mentor_id = $1 AND currency = $2 AND status IN ('requested', 'approved')`,
+			payout.MentorID, payout.Currency).Scan(&committed)
+		if err != nil {
+			return err
+		}
+		if payout.Amount > available-committed {
+			return ErrInsufficientBalance
+		}
+
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO payouts (mentor_id, amount, currency, note)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, status, created_at`,
+			payout.MentorID, payout.Amount, payout.Currency, payout.Note).Scan(&payout.ID, &payout.Status, &payout.CreatedAt)
+		if err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+				return ErrPayoutPending
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// DecidePayout approves or rejects a requested payout.
+func (s *PayoutStore) DecidePayout(ctx context.Context, payout *Payout, status string, decidedBy int64, note string) error {
+	query := `
+		UPDATE payouts
+		SET status = $1, decided_by = $2, decided_at = NOW(),
+			note = CASE WHEN $3 = '' THEN note ELSE $3 END
+		WHERE id = $4 AND status = 'requested'
+		RETURNING status, note, decided_by, decided_at`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, status, decidedBy, note, payout.ID).Scan(
+		&payout.Status, &payout.Note, &payout.DecidedBy, &payout.DecidedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrPayoutDecided
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *PayoutStore) GetPayoutByID(ctx context.Context, id int64) (*Payout, error) {
+	query := `
+		SELECT id, mentor_id, amount, currency, status, note, decided_by, decided_at, created_at
+		FROM payouts
+		WHERE id = $1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	payout, err := scanPayout(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return payout, nil
+}
+
+// GetPayoutsByMentorID returns every payout of the mentor, newest first.
+func (s *PayoutStore) GetPayoutsByMentorID(ctx context.Context, mentorID int64) ([]*Payout, error) {
+	query := `
+		SELECT id, mentor_id, amount, currency, status, note, decided_by, decided_at, created_at
+		FROM payouts
+		WHERE mentor_id = $1
+		ORDER BY created_at DESC, id DESC`
+
+	return s.queryPayouts(ctx, query, mentorID)
+}
+
+// GetPayouts lists payouts oldest first, so requests are reviewed in order,
+// only those in status when it is set.
+func (s *PayoutStore) GetPayouts(ctx context.Context, status string, limit, offset int) ([]*Payout, error) {
+	query := `
+		SELECT id, mentor_id, amount, currency, status, note, decided_by, decided_at, created_at
+		FROM payouts
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at, id
+		LIMIT $2 OFFSET $3`
+
+	return s.queryPayouts(ctx, query, status, limit, offset)
+}
+
+func (s *PayoutStore) queryPayouts(ctx context.Context, query string, args ...any) ([]*Payout, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payouts := []*Payout{}
+	for rows.Next() {
+		payout, err := scanPayout(rows)
+		if err != nil {
+			return nil, err
+		}
+		payouts = append(payouts, payout)
+	}
+
+	return payouts, rows.Err()
+}
+
+func scanPayout(row scanner) (*Payout, error) {
+	p := &Payout{}
+	err := row.Scan(&p.ID, &p.MentorID, &p.Amount, &p.Currency, &p.Status, &p.Note, &p.DecidedBy, &p.DecidedAt, &p.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
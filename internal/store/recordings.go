@@ -0,0 +1,183 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Recording is what a participant published in a recorded video room, saved
+// to Path on the API server's disk. EndedAt and Size are set once they
+// leave.
+type Recording struct {
+	ID        int64      `json:"id"`
+	MeetingID int64      `json:"meeting_id"`
+	RoomID    string     `json:"room_id"`
+	UserID    int64      `json:"user_id"`
+	MimeType  string     `json:"mime_type"`
+	Path      string     `json:"-"`
+	Size      int64      `json:"size"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at"`
+}
+
+type RecordingStore struct {
+	db *sql.DB
+}
+
+// SetRecordingConsent records whether userID agrees to meeting being
+// recorded.
+func (s *RecordingStore) SetRecordingConsent(ctx context.Context, meetingID, userID int64, consent bool) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	var err error
+	if consent {
+		_, err = s.db.ExecContext(ctx, `
+			INSERT INTO recording_consents (meeting_id, user_id) VALUES ($1, $2)
+			ON CONFLICT DO NOTHING`, meetingID, userID)
+	} else {
+		_, err = s.db.ExecContext(ctx, `
+			DELETE FROM recording_consents WHERE meeting_id = $1 AND user_id = $2`, meetingID, userID)
+	}
+	return err
+}
+
+// GetRecordingConsents lists the users who agreed to meeting being
+// recorded.
+func (s *RecordingStore) GetRecordingConsents(ctx context.Context, meetingID int64) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT user_id FROM recording_consents WHERE meeting_id = $1`, meetingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		users = append(users, id)
+	}
+
+	return users, rows.Err()
+}
+
+func (s *RecordingStore) CreateRecording(ctx context.Context, recording *Recording) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, `
+		INSERT INTO recordings (meeting_id, room_id, user_id, mime_type, path)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, started_at`,
+		recording.MeetingID, recording.RoomID, recording.UserID, recording.MimeType, recording.Path,
+	).Scan(&recording.ID, &recording.StartedAt)
+}
+
+// FinishRecording marks the recording ended, size bytes long.
+func (s *RecordingStore) FinishRecording(ctx context.Context, id int64, size int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `UPDATE recordings SET ended_at = NOW(), size = $2 WHERE id = $1`, id, size)
+	return err
+}
+
+func (s *RecordingStore) GetRecordingByID(ctx context.Context, id int64) (*Recording, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	recording, err := scanRecording(s.db.QueryRowContext(ctx, recordingSelect+`WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return recording, nil
+}
+
+func (s *RecordingStore) GetRecordingsByMeetingID(ctx context.Context, meetingID int64) ([]*Recording, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, recordingSelect+`WHERE meeting_id = $1 ORDER BY started_at, id`, meetingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recordings := []*Recording{}
+	for rows.Next() {
+		recording, err := scanRecording(rows)
+		if err != nil {
+			return nil, err
+		}
+		recordings = append(recordings, recording)
+	}
+
+	return recordings, rows.Err()
+}
+
+// GetRecordingsBefore lists the recordings started before.
+func (s *RecordingStore) GetRecordingsBefore(ctx context.Context, before time.Time) ([]*Recording, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, recordingSelect+`WHERE started_at < $1 ORDER BY started_at, id`, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recordings := []*Recording{}
+	for rows.Next() {
+		recording, err := scanRecording(rows)
+		if err != nil {
+			return nil, err
+		}
+		recordings = append(recordings, recording)
+	}
+
+	return recordings, rows.Err()
+}
+
+func (s *RecordingStore) DeleteRecording(ctx context.Context, id int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM recordings WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+const recordingSelect = `
+	SELECT id, meeting_id, room_id, user_id, mime_type, path, size, started_at, ended_at
+	FROM recordings
+	`
+
+func scanRecording(row scanner) (*Recording, error) {
+	r := &Recording{}
+	err := row.Scan(&r.ID, &r.MeetingID, &r.RoomID, &r.UserID, &r.MimeType, &r.Path, &r.Size,
+		&r.StartedAt, &r.EndedAt)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,188 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrReschedulePending    = errors.New("meeting already has a pending reschedule request")
+	ErrRescheduleNotPending = errors.New("reschedule request is no longer pending")
+)
+
+const (
+	ReschedulePending  = "pending"
+	RescheduleAccepted = "accepted"
+	RescheduleDeclined = "declined"
+)
+
+// RescheduleRequest is one party proposing to move a meeting to a new time,
+// waiting for the other party to accept or decline.
+type RescheduleRequest struct {
+	ID              int64      `json:"id"`
+	MeetingID       int64      `json:"meeting_id"`
+	ProposedBy      int64      `json:"proposed_by"`
+	StartAt         time.Time  `json:"start_at"`
+	DurationMinutes int        `json:"duration_minutes"`
+	Reason          string     `json:"reason"`
+	Status          string     `json:"status"`
+	RespondedBy     *int64     `json:"responded_by"`
+	CreatedAt       time.Time  `json:"created_at"`
+	RespondedAt     *time.Time `json:"responded_at"`
+}
+
+func (r *RescheduleRequest) EndAt() time.Time {
+	return r.StartAt.Add(time.Duration(r.DurationMinutes) * time.Minute)
+}
+
+type RescheduleStore struct {
+	db *sql.DB
+}
+
+func (s *RescheduleStore) CreateRescheduleRequest(ctx context.Context, req *RescheduleRequest) error {
+	query := `
+		INSERT INTO meeting_reschedules (meeting_id, proposed_by, start_at, duration_minutes, reason)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, status, created_at`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, req.MeetingID, req.ProposedBy, req.StartAt,
+		req.DurationMinutes, req.Reason).Scan(&req.ID, &req.Status, &req.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrReschedulePending
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *RescheduleStore) GetRescheduleRequestByID(ctx context.Context, id int64) (*RescheduleRequest, error) {
+	query := `
+		SELECT id, meeting_id, proposed_by, start_at, duration_minutes, reason, status, responded_by, created_at, responded_at
+		FROM meeting_reschedules
+		WHERE id = $1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	req, err := scanRescheduleRequest(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return req, nil
+}
+
+func (s *RescheduleStore) GetRescheduleRequestsByMeetingID(ctx context.Context, meetingID int64) ([]*RescheduleRequest, error) {
+	query := `
+		SELECT id, meeting_id, proposed_by, start_at, duration_minutes, reason, status, responded_by, created_at, responded_at
+		FROM meeting_reschedules
+		WHERE meeting_id = $1
+		ORDER BY created_at DESC, id DESC`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, meetingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []*RescheduleRequest{}
+	for rows.Next() {
+		req, err := scanRescheduleRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+	}
+
+	return requests, rows.Err()
+}
+
+// AcceptRescheduleRequest moves the meeting to the proposed time if check
+// passes, failing with ErrMeetingConflict if the mentor has since been
+// booked then.
+func (s *RescheduleStore) AcceptRescheduleRequest(ctx context.Context, req *RescheduleRequest, respondedBy int64, check BookingCheck) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := respondRescheduleRequest(ctx, tx, req, RescheduleAccepted, respondedBy); err != nil {
+			return err
+		}
+
+		var mentorID int64
+		err := tx.QueryRowContext(ctx, `SELECT mentorid FROM meetings WHERE id = $1`, req.MeetingID).Scan(&mentorID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrNotFound
+			}
+			return err
+		}
+
+		err = lockMentorSlot(ctx, tx, mentorID, req.StartAt, req.EndAt(), req.MeetingID)
+		if err != nil {
+			return err
+		}
+		if err := check(ctx); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE meetings
+			SET start_at = $1, duration_minutes = $2
+			WHERE id = $3`, req.StartAt, req.DurationMinutes, req.MeetingID)
+		return err
+	})
+}
+
+func (s *RescheduleStore) DeclineRescheduleRequest(ctx context.Context, req *RescheduleRequest, respondedBy int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return respondRescheduleRequest(ctx, tx, req, RescheduleDeclined, respondedBy)
+	})
+}
+
+func respondRescheduleRequest(ctx context.Context, tx *sql.Tx, req *RescheduleRequest, status string, respondedBy int64) error {
+	err := tx.QueryRowContext(ctx, `
+		UPDATE meeting_reschedules
+		SET status = $1, responded_by = $2, responded_at = NOW()
+		WHERE id = $3 AND status = 'pending'
+		RETURNING status, responded_by, responded_at`, status, respondedBy, req.ID).Scan(
+		&req.Status, &req.RespondedBy, &req.RespondedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrRescheduleNotPending
+		}
+		return err
+	}
+
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRescheduleRequest(row scanner) (*RescheduleRequest, error) {
+	req := &RescheduleRequest{}
+	err := row.Scan(&req.ID, &req.MeetingID, &req.ProposedBy, &req.StartAt, &req.DurationMinutes,
+		&req.Reason, &req.Status, &req.RespondedBy, &req.CreatedAt, &req.RespondedAt)
+	if err != nil {
+		return nil, err
+	}
+	return req, nil
+}
@@ -15,6 +15,7 @@ type Storage struct {
 		Activate(context.Context, string) error
 		Delete(context.Context, int64) error
 		GetByEmail(context.Context, string) (*User, error)
+		UpdateTimezone(context.Context, int64, string) error
 	}
 	Expertise interface {
 		Create(context.Context, *Expertise) error
@@ -59,6 +60,7 @@ type Storage struct {
 		UpdateGig(ctx context.Context, gig *Gig) error
 		DeleteGig(ctx context.Context, gigID int64) error
 		GetGigByID(ctx context.Context, id int64) (*Gig, error)
+		GetGigsByUserID(ctx context.Context, userid int64) ([]*Gig, error)
 		// GetGigByMentorID(ctx context.Context, mentorID int64) ([]*Gig, error)
 	}
 	Education interface {
@@ -90,22 +92,119 @@ type Storage struct {
 		DeleteWorkingAt(ctx context.Context, workingatID int64) error
 	}
 	Meetings interface {
-		CreateMeeting(ctx context.Context, meeting *Meetings) error
+		CreateMeeting(ctx context.Context, meeting *Meetings, check BookingCheck) error
 		GetAllMeetings(ctx context.Context, limit, offset int) ([]*Meetings, error)
 		GetMeetingByUserID(ctx context.Context, userID int64) ([]*Meetings, error)
+		GetMeetingsByMentorID(ctx context.Context, mentorID int64, from, to time.Time) ([]*Meetings, error)
 		GetMeetingMentorNotConfirm(ctx context.Context, mentorID int64) ([]*Meetings, error)
 		GetMeetingUserNotPaid(ctx context.Context, userID int64) ([]*Meetings, error)
 		GetMeetingUserNotCompleted(ctx context.Context, userID int64) ([]*Meetings, error)
 		GetMeetingMentorNotCompleted(ctx context.Context, mentorID int64) ([]*Meetings, error)
-		UpdateMeetingConfirm(ctx context.Context, meetingID int64) error
-		UpdateMeetingPaid(ctx context.Context, meetingID int64) error
-		UpdateMeetingCompleted(ctx context.Context, meetingID int64) error
+		UpdateMeetingStatus(ctx context.Context, meetingID int64, status MeetingStatus, changedBy int64, reason string) error
+		GetMeetingStatusHistory(ctx context.Context, meetingID int64) ([]*MeetingStatusChange, error)
 		UpdateLink(ctx context.Context, meeting *Meetings) error
 		DeleteMeeting(ctx context.Context, meetingID int64) error
 		GetMeetingByID(ctx context.Context, id int64) (*Meetings, error)
 	}
+	Attendees interface {
+		AddAttendee(ctx context.Context, attendee *Attendee) error
+		RemoveAttendee(ctx context.Context, meetingID, userID int64) error
+		GetAttendees(ctx context.Context, meetingID int64) ([]*Attendee, error)
+		IsAttendee(ctx context.Context, meetingID, userID int64) (bool, error)
+		SetMaxParticipants(ctx context.Context, meetingID int64, max int) error
+	}
+	Recordings interface {
+		SetRecordingConsent(ctx context.Context, meetingID, userID int64, consent bool) error
+		GetRecordingConsents(ctx context.Context, meetingID int64) ([]int64, error)
+		CreateRecording(ctx context.Context, recording *Recording) error
+		FinishRecording(ctx context.Context, id int64, size int64) error
+		GetRecordingByID(ctx context.Context, id int64) (*Recording, error)
+		GetRecordingsByMeetingID(ctx context.Context, meetingID int64) ([]*Recording, error)
+		GetRecordingsBefore(ctx context.Context, before time.Time) ([]*Recording, error)
+		DeleteRecording(ctx context.Context, id int64) error
+	}
 	BookingSlot interface {
-		CreateBookingSlot(ctx context.Context, slot *BookingSlot) error
+		CreateBookingSlot(ctx context.Context, slot *BookingSlot, check ScheduleCheck) error
+		GetBookingSlotByID(ctx context.Context, id int64) (*BookingSlot, error)
+		GetBookingSlotsByUserID(ctx context.Context, userid int64) ([]*BookingSlot, error)
+		UpdateBookingSlot(ctx context.Context, slot *BookingSlot, check ScheduleCheck) error
+		DeleteBookingSlot(ctx context.Context, id int64) error
+		ReplaceBookingSlots(ctx context.Context, userid int64, slots []*BookingSlot) error
+	}
+	Payments interface {
+		CreatePayment(ctx context.Context, payment *Payment) error
+		GetPaymentByID(ctx context.Context, id int64) (*Payment, error)
+		GetPaymentBySessionID(ctx context.Context, sessionID string) (*Payment, error)
+		GetPaymentsByUserID(ctx context.Context, userID int64, limit, offset int) ([]*Payment, error)
+		CompleteCheckout(ctx context.Context, meetingID int64, payment MeetingPayment) error
+		RecordUnappliedPayment(ctx context.Context, meetingID int64, payment MeetingPayment) (*Payment, error)
+		GetPaidPaymentByMeetingID(ctx context.Context, meetingID int64) (*Payment, error)
+		GetPendingPaymentByMeetingID(ctx context.Context, meetingID int64) (*Payment, error)
+		GetEarningItems(ctx context.Context, mentorID int64, from, to time.Time) ([]*EarningItem, error)
+		RecordRefund(ctx context.Context, refund *Refund) error
+		SyncRefundedAmount(ctx context.Context, paymentIntentID string, refunded int64) error
+		MarkPaymentDisputed(ctx context.Context, paymentIntentID string) error
+		ExpireCheckout(ctx context.Context, sessionID string) error
+		FailCheckout(ctx context.Context, sessionID string) error
+	}
+	Coupons interface {
+		CreateCoupon(ctx context.Context, coupon *Coupon) error
+		GetCouponByID(ctx context.Context, id int64) (*Coupon, error)
+		GetCoupons(ctx context.Context, limit, offset int) ([]*Coupon, error)
+		GetCouponsByMentorID(ctx context.Context, mentorID int64) ([]*Coupon, error)
+		DeactivateCoupon(ctx context.Context, id int64) error
+		ApplyCoupon(ctx context.Context, meeting *Meetings, code, currency string) (*Coupon, error)
+	}
+	Invoices interface {
+		CreateInvoice(ctx context.Context, invoice *Invoice) (bool, error)
+		GetInvoiceByPaymentID(ctx context.Context, paymentID int64) (*Invoice, error)
+		MarkInvoiceEmailed(ctx context.Context, id int64) error
+	}
+	Packages interface {
+		CreatePackage(ctx context.Context, pkg *Package) error
+		GetPackageByID(ctx context.Context, id int64) (*Package, error)
+		GetPackagesByUserID(ctx context.Context, userID int64) ([]*Package, error)
+		DeactivatePackage(ctx context.Context, id int64) error
+		CreatePurchase(ctx context.Context, purchase *Purchase) error
+		CompletePurchase(ctx context.Context, payment MeetingPayment) error
+		ExpirePurchase(ctx context.Context, sessionID string) error
+		GetCredits(ctx context.Context, buyerID int64) ([]*Credits, error)
+		GetCreditPurchase(ctx context.Context, buyerID, mentorID int64) (*Purchase, error)
+	}
+	Payouts interface {
+		CreatePayout(ctx context.Context, payout *Payout, available int64) error
+		DecidePayout(ctx context.Context, payout *Payout, status string, decidedBy int64, note string) error
+		GetPayoutByID(ctx context.Context, id int64) (*Payout, error)
+		GetPayoutsByMentorID(ctx context.Context, mentorID int64) ([]*Payout, error)
+		GetPayouts(ctx context.Context, status string, limit, offset int) ([]*Payout, error)
+	}
+	WebhookEvents interface {
+		ClaimWebhookEvent(ctx context.Context, event *WebhookEvent) (bool, error)
+		ReclaimWebhookEvent(ctx context.Context, id int64) (*WebhookEvent, error)
+		FinishWebhookEvent(ctx context.Context, id int64, status, errMsg string) error
+		GetWebhookEventByID(ctx context.Context, id int64) (*WebhookEvent, error)
+		GetWebhookEvents(ctx context.Context, status string, limit, offset int) ([]*WebhookEvent, error)
+	}
+	Reschedules interface {
+		CreateRescheduleRequest(ctx context.Context, req *RescheduleRequest) error
+		GetRescheduleRequestByID(ctx context.Context, id int64) (*RescheduleRequest, error)
+		GetRescheduleRequestsByMeetingID(ctx context.Context, meetingID int64) ([]*RescheduleRequest, error)
+		AcceptRescheduleRequest(ctx context.Context, req *RescheduleRequest, respondedBy int64, check BookingCheck) error
+		DeclineRescheduleRequest(ctx context.Context, req *RescheduleRequest, respondedBy int64) error
+	}
+	Overrides interface {
+		CreateOverride(ctx context.Context, override *AvailabilityOverride) error
+		GetOverrideByID(ctx context.Context, id int64) (*AvailabilityOverride, error)
+		GetOverridesByUserID(ctx context.Context, userid int64, from, to time.Time) ([]*AvailabilityOverride, error)
+		DeleteOverride(ctx context.Context, id int64) error
+	}
+	Holidays interface {
+		CreateHoliday(ctx context.Context, holiday *Holiday) error
+		GetHolidays(ctx context.Context, calendar string) ([]*Holiday, error)
+		GetHolidaysForUser(ctx context.Context, userid int64, from, to string) ([]*Holiday, error)
+		DeleteHoliday(ctx context.Context, id int64) error
+		GetCalendars(ctx context.Context, userid int64) ([]string, error)
+		SetCalendars(ctx context.Context, userid int64, calendars []string) error
 	}
 	Country interface {
 		GetCountry(ctx context.Context) ([]*Country, error)
@@ -114,19 +213,30 @@ type Storage struct {
 
 func NewPostgresStorage(db *sql.DB) Storage {
 	return Storage{
-		Users:       &UserStore{db},
-		Expertise:   &ExpertiseStore{db},
-		Discipline:  &DisciplineStore{db},
-		Messages:    &MessageStore{db},
-		Mentor:      &MentorStore{db},
-		Gig:         &GigStore{db},
-		Education:   &EducationStore{db},
-		Experience:  &ExperienceStore{db},
-		SocialMedia: &SocialMediaStore{db},
-		WorkingAt:   &WorkingAtStore{db},
-		BookingSlot: &BookingStore{db},
-		Meetings:    &MeetingsStore{db},
-		Country:	 &CountryStore{db},
+		Users:         &UserStore{db},
+		Expertise:     &ExpertiseStore{db},
+		Discipline:    &DisciplineStore{db},
+		Messages:      &MessageStore{db},
+		Mentor:        &MentorStore{db},
+		Gig:           &GigStore{db},
+		Education:     &EducationStore{db},
+		Experience:    &ExperienceStore{db},
+		SocialMedia:   &SocialMediaStore{db},
+		WorkingAt:     &WorkingAtStore{db},
+		BookingSlot:   &BookingStore{db},
+		Meetings:      &MeetingsStore{db},
+		Attendees:     &AttendeeStore{db},
+		Recordings:    &RecordingStore{db},
+		Payments:      &PaymentStore{db},
+		Coupons:       &CouponStore{db},
+		Invoices:      &InvoiceStore{db},
+		Packages:      &PackageStore{db},
+		Payouts:       &PayoutStore{db},
+		WebhookEvents: &WebhookEventStore{db},
+		Reschedules:   &RescheduleStore{db},
+		Overrides:     &OverrideStore{db},
+		Holidays:      &HolidayStore{db},
+		Country:       &CountryStore{db},
 	}
 }
 
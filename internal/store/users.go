@@ -29,6 +29,8 @@ type User struct {
 	Password  password `json:"-"`
 	CreatedAt string   `json:"created_at"`
 	IsActive  bool     `json:"is_active"`
+	Timezone  string   `json:"timezone"`
+	IsAdmin   bool     `json:"is_admin"`
 }
 
 type password struct {
@@ -37,12 +39,16 @@ type password struct {
 }
 
 func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
-	query := `INSERT INTO users (username, password, email) VALUES ($1,$2,$3) RETURNING id, created_at`
+	query := `INSERT INTO users (username, password, email, timezone) VALUES ($1,$2,$3,$4) RETURNING id, created_at`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
 	defer cancel()
 
-	err := tx.QueryRowContext(ctx, query, user.Username, user.Password.hash, user.Email).Scan(&user.ID, &user.CreatedAt)
+	if user.Timezone == "" {
+		user.Timezone = "UTC"
+	}
+
+	err := tx.QueryRowContext(ctx, query, user.Username, user.Password.hash, user.Email, user.Timezone).Scan(&user.ID, &user.CreatedAt)
 	if err != nil {
 		return err
 	}
@@ -67,7 +73,7 @@ func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 // }
 
 func (s *UserStore) GetByID(ctx context.Context, id int64) (*User, error) {
-	query := `SELECT id, username, email, password, created_at
+	query := `SELECT id, username, email, password, created_at, timezone, is_admin
 	FROM users WHERE id = $1 AND is_active = true`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
@@ -75,7 +81,7 @@ func (s *UserStore) GetByID(ctx context.Context, id int64) (*User, error) {
 
 	user := &User{}
 	err := s.db.QueryRowContext(ctx, query, id).Scan(&user.ID, &user.Username, &user.Email,
-		&user.Password.hash, &user.CreatedAt)
+		&user.Password.hash, &user.CreatedAt, &user.Timezone, &user.IsAdmin)
 	if err != nil {
 		switch err {
 		case sql.ErrNoRows:
@@ -88,7 +94,7 @@ func (s *UserStore) GetByID(ctx context.Context, id int64) (*User, error) {
 }
 
 func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
-	query := `SELECT id,username,email,password,created_at FROM users 
+	query := `SELECT id,username,email,password,created_at,timezone,is_admin FROM users 
 	WHERE email = $1 AND is_active = true`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
@@ -96,7 +102,7 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 
 	user := &User{}
 	err := s.db.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Username, &user.Email,
-		&user.Password.hash, &user.CreatedAt)
+		&user.Password.hash, &user.CreatedAt, &user.Timezone, &user.IsAdmin)
 	if err != nil {
 		switch err {
 		case sql.ErrNoRows:
@@ -212,6 +218,28 @@ func (s *UserStore) update(ctx context.Context, tx *sql.Tx, user *User) error {
 	return nil
 }
 
+func (s *UserStore) UpdateTimezone(ctx context.Context, userid int64, timezone string) error {
+	query := `UPDATE users SET timezone = $1 WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, query, timezone, userid)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
 func (s *UserStore) deleteUserInvitation(ctx context.Context, tx *sql.Tx, userid int64) error {
 	query := `DELETE FROM user_invitations WHERE user_id = $1`
 
@@ -0,0 +1,169 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrWebhookEventBusy = errors.New("webhook event is being processed or was already processed")
+
+const (
+	WebhookProcessing = "processing"
+	WebhookProcessed  = "processed"
+	WebhookIgnored    = "ignored"
+	WebhookFailed     = "failed"
+)
+
+// webhookStaleAfter is how long a delivery may sit in processing before
+// another one may take it over, in case the first one died.
+const webhookStaleAfter = 5 * time.Minute
+
+// WebhookEvent is one event a payment provider delivered, kept so each is
+// only acted on once and failed ones can be replayed.
+type WebhookEvent struct {
+	ID          int64           `json:"id"`
+	Provider    string          `json:"provider"`
+	EventID     string          `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload" swaggertype:"object"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	LastError   string          `json:"last_error"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+}
+
+type WebhookEventStore struct {
+	db *sql.DB
+}
+
+// ClaimWebhookEvent records a delivery and reports whether the caller should
+// process it. Redeliveries are only claimed when the earlier attempt failed
+// or went stale.
+func (s *WebhookEventStore) ClaimWebhookEvent(ctx context.Context, event *WebhookEvent) (bool, error) {
+	query := `
+		INSERT INTO webhook_events (provider, event_id, event_type, payload)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (provider, event_id) DO UPDATE
+		SET status = 'processing', attempts = webhook_events.attempts + 1, updated_at = NOW()
+		WHERE webhook_events.status = 'failed'
+			OR (webhook_events.status = 'processing' AND webhook_events.updated_at < NOW() - make_interval(secs => $5))
+		RETURNING id, status, attempts, received_at, updated_at`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, event.Provider, event.EventID, event.EventType, []byte(event.Payload),
+		webhookStaleAfter.Seconds()).Scan(&event.ID, &event.Status, &event.Attempts, &event.ReceivedAt, &event.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ReclaimWebhookEvent takes a failed event for another attempt.
+func (s *WebhookEventStore) ReclaimWebhookEvent(ctx context.Context, id int64) (*WebhookEvent, error) {
+	query := `
+		UPDATE webhook_events
+		SET status = 'processing', attempts = attempts + 1, updated_at = NOW()
+		WHERE id = $1 AND status = 'failed'
+		RETURNING id, provider, event_id, event_type, payload, status, attempts, last_error, received_at, updated_at, processed_at`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	event, err := scanWebhookEvent(s.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		if _, err := s.GetWebhookEventByID(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrWebhookEventBusy
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// FinishWebhookEvent records how processing went. errMsg is only kept for
+// failed events.
+func (s *WebhookEventStore) FinishWebhookEvent(ctx context.Context, id int64, status, errMsg string) error {
+	query := `
+		UPDATE webhook_events
+		SET status = $1, last_error = $2, updated_at = NOW(),
+			processed_at = CASE WHEN $1 = 'failed' THEN processed_at ELSE NOW() END
+		WHERE id = $3`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, status, errMsg, id)
+	return err
+}
+
+func (s *WebhookEventStore) GetWebhookEventByID(ctx context.Context, id int64) (*WebhookEvent, error) {
+	query := `
+		SELECT id, provider, event_id, event_type, payload, status, attempts, last_error, received_at, updated_at, processed_at
+		FROM webhook_events
+		WHERE id = $1`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	event, err := scanWebhookEvent(s.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// GetWebhookEvents lists events newest first, only those in status when it
+// is set.
+func (s *WebhookEventStore) GetWebhookEvents(ctx context.Context, status string, limit, offset int) ([]*WebhookEvent, error) {
+	query := `
+		SELECT id, provider, event_id, event_type, payload, status, attempts, last_error, received_at, updated_at, processed_at
+		FROM webhook_events
+		WHERE $1 = '' OR status = $1
+		ORDER BY received_at DESC, id DESC
+		LIMIT $2 OFFSET $3`
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeOutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*WebhookEvent{}
+	for rows.Next() {
+		event, err := scanWebhookEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func scanWebhookEvent(row scanner) (*WebhookEvent, error) {
+	e := &WebhookEvent{}
+	var payload []byte
+	err := row.Scan(&e.ID, &e.Provider, &e.EventID, &e.EventType, &payload, &e.Status, &e.Attempts, &e.LastError,
+		&e.ReceivedAt, &e.UpdatedAt, &e.ProcessedAt)
+	if err != nil {
+		return nil, err
+	}
+	e.Payload = payload
+	return e, nil
+}
@@ -0,0 +1,52 @@
+package video
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+// ICEConfig lists the STUN and TURN servers peers use to reach each other.
+// TURN servers take time-limited credentials derived from a secret shared
+// with them, as in coturn's use-auth-secret mode.
+type ICEConfig struct {
+	STUNURLs   []string
+	TURNURLs   []string
+	TURNSecret string
+	// TURNTTL is how long TURN credentials stay valid
+	TURNTTL time.Duration
+}
+
+// ICEServer is one entry of RTCConfiguration.iceServers.
+type ICEServer struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+// Servers returns the ICE servers for userID, with TURN credentials valid
+// until the time returned alongside. TURN servers are left out when there
+// is no secret to sign credentials with.
+func (c ICEConfig) Servers(userID int64, now time.Time) ([]ICEServer, time.Time) {
+	servers := []ICEServer{}
+	if len(c.STUNURLs) > 0 {
+		servers = append(servers, ICEServer{URLs: c.STUNURLs})
+	}
+
+	expiresAt := now.Add(c.TURNTTL)
+	if len(c.TURNURLs) > 0 && c.TURNSecret != "" {
+		username := fmt.Sprintf("%d:%d", expiresAt.Unix(), userID)
+		mac := hmac.New(sha1.New, []byte(c.TURNSecret))
+		mac.Write([]byte(username))
+
+		servers = append(servers, ICEServer{
+			URLs:       c.TURNURLs,
+			Username:   username,
+			Credential: base64.StdEncoding.EncodeToString(mac.Sum(nil)),
+		})
+	}
+
+	return servers, expiresAt
+}
@@ -0,0 +1,241 @@
+package video
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory keeps rooms in the process, for development and single instance
+// deployments. Rooms are lost on restart.
+type Memory struct {
+	mu      sync.Mutex
+	seq     int64
+	rooms   map[string]*Room
+	active  map[int64]*Participant
+	tickets map[string]*Ticket
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		rooms:   map[string]*Room{},
+		active:  map[int64]*Participant{},
+		tickets: map[string]*Ticket{},
+	}
+}
+
+func (m *Memory) CreateRoom(ctx context.Context, room *Room) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.rooms[room.ID]; exists {
+		return ErrRoomExists
+	}
+	now := time.Now()
+	for _, other := range m.rooms {
+		if other.MeetingID == room.MeetingID && other.Open(now) {
+			return ErrRoomExists
+		}
+	}
+
+	room.CreatedAt = now
+	room.ClosedAt = nil
+	room.Participants = []Participant{}
+	stored := *room
+	m.rooms[room.ID] = &stored
+	return nil
+}
+
+func (m *Memory) GetRoom(ctx context.Context, id string) (*Room, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	room, ok := m.rooms[id]
+	if !ok {
+		return nil, ErrRoomNotFound
+	}
+	return m.copyRoom(room), nil
+}
+
+func (m *Memory) GetRoomByMeetingID(ctx context.Context, meetingID int64) (*Room, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, room := range m.rooms {
+		if room.MeetingID == meetingID && room.Open(now) {
+			return m.copyRoom(room), nil
+		}
+	}
+	return nil, ErrRoomNotFound
+}
+
+func (m *Memory) Join(ctx context.Context, roomID string, userID int64) (*Participant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	room, ok := m.rooms[roomID]
+	if !ok {
+		return nil, ErrRoomNotFound
+	}
+	if !room.Open(time.Now()) {
+		return nil, ErrRoomClosed
+	}
+	current := m.participants(roomID)
+	if !hasUser(current, userID) && users(current) >= room.MaxParticipants {
+		return nil, ErrRoomFull
+	}
+
+	m.seq++
+	p := &Participant{
+		ID:       m.seq,
+		RoomID:   roomID,
+		UserID:   userID,
+		JoinedAt: time.Now(),
+	}
+	m.active[p.ID] = p
+	joined := *p
+	return &joined, nil
+}
+
+func (m *Memory) Leave(ctx context.Context, participant *Participant) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.active[participant.ID]
+	if !ok {
+		return nil
+	}
+	delete(m.active, p.ID)
+	now := time.Now()
+	participant.LeftAt = &now
+
+	room, ok := m.rooms[p.RoomID]
+	if ok && room.ClosedAt == nil && !now.Before(room.ExpiresAt) && len(m.participants(room.ID)) == 0 {
+		room.ClosedAt = &now
+	}
+	return nil
+}
+
+func (m *Memory) SetRoomExpiry(ctx context.Context, id string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	room, ok := m.rooms[id]
+	if !ok {
+		return ErrRoomNotFound
+	}
+	if room.ClosedAt != nil {
+		return ErrRoomClosed
+	}
+	room.ExpiresAt = expiresAt
+	return nil
+}
+
+func (m *Memory) SetRoomMode(ctx context.Context, id string, mode string, recording bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	room, ok := m.rooms[id]
+	if !ok {
+		return ErrRoomNotFound
+	}
+	if room.ClosedAt != nil {
+		return ErrRoomClosed
+	}
+	room.Mode = mode
+	room.Recording = recording
+	return nil
+}
+
+func (m *Memory) CloseRoom(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	room, ok := m.rooms[id]
+	if !ok {
+		return ErrRoomNotFound
+	}
+	m.close(room, time.Now())
+	return nil
+}
+
+func (m *Memory) CloseExpiredRooms(ctx context.Context) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	closed := []string{}
+	for _, room := range m.rooms {
+		if room.ClosedAt == nil && !now.Before(room.ExpiresAt) {
+			m.close(room, now)
+			closed = append(closed, room.ID)
+		}
+	}
+	for id, ticket := range m.tickets {
+		if !now.Before(ticket.ExpiresAt) {
+			delete(m.tickets, id)
+		}
+	}
+	return closed, nil
+}
+
+func (m *Memory) CreateTicket(ctx context.Context, ticket *Ticket) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.rooms[ticket.RoomID]; !ok {
+		return ErrRoomNotFound
+	}
+	stored := *ticket
+	m.tickets[ticket.ID] = &stored
+	return nil
+}
+
+func (m *Memory) RedeemTicket(ctx context.Context, id string) (*Ticket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ticket, ok := m.tickets[id]
+	if !ok {
+		return nil, ErrTicketInvalid
+	}
+	delete(m.tickets, id)
+	if !time.Now().Before(ticket.ExpiresAt) {
+		return nil, ErrTicketInvalid
+	}
+	out := *ticket
+	return &out, nil
+}
+
+// close closes room and lets everyone still in it out.
+func (m *Memory) close(room *Room, now time.Time) {
+	if room.ClosedAt == nil {
+		room.ClosedAt = &now
+	}
+	for id, p := range m.active {
+		if p.RoomID == room.ID {
+			delete(m.active, id)
+		}
+	}
+}
+
+func (m *Memory) participants(roomID string) []Participant {
+	participants := []Participant{}
+	for _, p := range m.active {
+		if p.RoomID == roomID {
+			participants = append(participants, *p)
+		}
+	}
+	sort.Slice(participants, func(i, j int) bool {
+		return participants[i].ID < participants[j].ID
+	})
+	return participants
+}
+
+func (m *Memory) copyRoom(room *Room) *Room {
+	r := *room
+	r.Participants = m.participants(room.ID)
+	return &r
+}
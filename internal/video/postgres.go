@@ -0,0 +1,344 @@
+package video
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Althaf66/Appointr/internal/store"
+	"github.com/lib/pq"
+)
+
+// Postgres keeps rooms in the database, where they outlive restarts. Any
+// instance can open a room or hand out its tickets, but the room's sockets
+// still need routing to a single instance; see Registry.
+type Postgres struct {
+	db *sql.DB
+}
+
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+func (s *Postgres) CreateRoom(ctx context.Context, room *Room) error {
+	ctx, cancel := context.WithTimeout(ctx, store.QueryTimeOutDuration)
+	defer cancel()
+
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		// an expired room nobody cleaned up yet doesn't count as open
+		_, err := tx.ExecContext(ctx, `
+			UPDATE video_rooms SET closed_at = NOW()
+			WHERE meeting_id = $1 AND closed_at IS NULL AND expires_at <= NOW()`, room.MeetingID)
+		if err != nil {
+			return err
+		}
+
+		return tx.QueryRowContext(ctx, `
+			INSERT INTO video_rooms (id, meeting_id, max_participants, mode, recording, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING created_at`,
+			room.ID, room.MeetingID, room.MaxParticipants, room.Mode, room.Recording, room.ExpiresAt).Scan(&room.CreatedAt)
+	})
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrRoomExists
+		}
+		return err
+	}
+
+	room.ClosedAt = nil
+	room.Participants = []Participant{}
+	return nil
+}
+
+func (s *Postgres) GetRoom(ctx context.Context, id string) (*Room, error) {
+	return s.getRoom(ctx, `WHERE id = $1`, id)
+}
+
+func (s *Postgres) GetRoomByMeetingID(ctx context.Context, meetingID int64) (*Room, error) {
+	return s.getRoom(ctx, `WHERE meeting_id = $1 AND closed_at IS NULL AND expires_at > NOW()`, meetingID)
+}
+
+func (s *Postgres) getRoom(ctx context.Context, where string, arg any) (*Room, error) {
+	ctx, cancel := context.WithTimeout(ctx, store.QueryTimeOutDuration)
+	defer cancel()
+
+	room, err := scanRoom(s.db.QueryRowContext(ctx, roomSelect+where, arg))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrRoomNotFound
+		}
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, room_id, user_id, joined_at, left_at
+		FROM video_room_participants
+		WHERE room_id = $1 AND left_at IS NULL
+		ORDER BY id`, room.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	room.Participants = []Participant{}
+	for rows.Next() {
+		var p Participant
+		if err := rows.Scan(&p.ID, &p.RoomID, &p.UserID, &p.JoinedAt, &p.LeftAt); err != nil {
+			return nil, err
+		}
+		room.Participants = append(room.Participants, p)
+	}
+
+	return room, rows.Err()
+}
+
+func (s *Postgres) Join(ctx context.Context, roomID string, userID int64) (*Participant, error) {
+	ctx, cancel := context.WithTimeout(ctx, store.QueryTimeOutDuration)
+	defer cancel()
+
+	p := &Participant{RoomID: roomID, UserID: userID}
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		// the lock makes concurrent joins take the places one at a time
+		room, err := scanRoom(tx.QueryRowContext(ctx, roomSelect+`WHERE id = $1 FOR UPDATE`, roomID))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrRoomNotFound
+			}
+			return err
+		}
+		if !room.Open(time.Now()) {
+			return ErrRoomClosed
+		}
+
+		var users int
+		var present bool
+		err = tx.QueryRowContext(ctx, `
+			SELECT COUNT(DISTINCT user_id), COALESCE(BOOL_OR(user_id = $2), FALSE)
+			FROM video_room_participants
+			WHERE room_id = $1 AND left_at IS NULL`, roomID, userID).Scan(&users, &present)
+		if err != nil {
+			return err
+		}
+		if !present && users >= room.MaxParticipants {
+			return ErrRoomFull
+		}
+
+		return tx.QueryRowContext(ctx, `
+			INSERT INTO video_room_participants (room_id, user_id)
+			VALUES ($1, $2)
+			RETURNING id, joined_at`, roomID, userID).Scan(&p.ID, &p.JoinedAt)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (s *Postgres) Leave(ctx context.Context, participant *Participant) error {
+	ctx, cancel := context.WithTimeout(ctx, store.QueryTimeOutDuration)
+	defer cancel()
+
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		var roomID string
+		var leftAt time.Time
+		err := tx.QueryRowContext(ctx, `
+			UPDATE video_room_participants SET left_at = NOW()
+			WHERE id = $1 AND left_at IS NULL
+			RETURNING room_id, left_at`, participant.ID).Scan(&roomID, &leftAt)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				// the room was closed under them
+				return nil
+			}
+			return err
+		}
+		participant.LeftAt = &leftAt
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE video_rooms SET closed_at = NOW()
+			WHERE id = $1 AND closed_at IS NULL AND expires_at <= NOW()
+				AND NOT EXISTS (
+					SELECT 1 FROM video_room_participants WHERE room_id = $1 AND left_at IS NULL
+				)`, roomID)
+		return err
+	})
+}
+
+func (s *Postgres) SetRoomExpiry(ctx context.Context, id string, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, store.QueryTimeOutDuration)
+	defer cancel()
+
+	var closed bool
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE video_rooms SET expires_at = CASE WHEN closed_at IS NULL THEN $2 ELSE expires_at END
+		WHERE id = $1
+		RETURNING closed_at IS NOT NULL`, id, expiresAt).Scan(&closed)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrRoomNotFound
+		}
+		return err
+	}
+	if closed {
+		return ErrRoomClosed
+	}
+
+	return nil
+}
+
+func (s *Postgres) SetRoomMode(ctx context.Context, id string, mode string, recording bool) error {
+	ctx, cancel := context.WithTimeout(ctx, store.QueryTimeOutDuration)
+	defer cancel()
+
+	var closed bool
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE video_rooms
+		SET mode = CASE WHEN closed_at IS NULL THEN $2 ELSE mode END,
+			recording = CASE WHEN closed_at IS NULL THEN $3 ELSE recording END
+		WHERE id = $1
+		RETURNING closed_at IS NOT NULL`, id, mode, recording).Scan(&closed)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrRoomNotFound
+		}
+		return err
+	}
+	if closed {
+		return ErrRoomClosed
+	}
+
+	return nil
+}
+
+func (s *Postgres) CloseRoom(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, store.QueryTimeOutDuration)
+	defer cancel()
+
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+			UPDATE video_rooms SET closed_at = COALESCE(closed_at, NOW()) WHERE id = $1`, id)
+		if err != nil {
+			return err
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrRoomNotFound
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE video_room_participants SET left_at = NOW()
+			WHERE room_id = $1 AND left_at IS NULL`, id)
+		return err
+	})
+}
+
+func (s *Postgres) CloseExpiredRooms(ctx context.Context) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, store.QueryTimeOutDuration)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM video_room_tickets WHERE expires_at <= NOW()`); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		WITH closed AS (
+			UPDATE video_rooms SET closed_at = NOW()
+			WHERE closed_at IS NULL AND expires_at <= NOW()
+			RETURNING id
+		), left_rooms AS (
+			UPDATE video_room_participants SET left_at = NOW()
+			WHERE left_at IS NULL AND room_id IN (SELECT id FROM closed)
+		)
+		SELECT id FROM closed`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	closed := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		closed = append(closed, id)
+	}
+
+	return closed, rows.Err()
+}
+
+func (s *Postgres) CreateTicket(ctx context.Context, ticket *Ticket) error {
+	ctx, cancel := context.WithTimeout(ctx, store.QueryTimeOutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO video_room_tickets (id, room_id, user_id, expires_at)
+		VALUES ($1, $2, $3, $4)`,
+		ticket.ID, ticket.RoomID, ticket.UserID, ticket.ExpiresAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return ErrRoomNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Postgres) RedeemTicket(ctx context.Context, id string) (*Ticket, error) {
+	ctx, cancel := context.WithTimeout(ctx, store.QueryTimeOutDuration)
+	defer cancel()
+
+	ticket := &Ticket{ID: id}
+	err := s.db.QueryRowContext(ctx, `
+		DELETE FROM video_room_tickets
+		WHERE id = $1
+		RETURNING room_id, user_id, expires_at`, id).Scan(&ticket.RoomID, &ticket.UserID, &ticket.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTicketInvalid
+		}
+		return nil, err
+	}
+	if !time.Now().Before(ticket.ExpiresAt) {
+		return nil, ErrTicketInvalid
+	}
+	return ticket, nil
+}
+
+func (s *Postgres) withTx(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+const roomSelect = `
+	SELECT id, meeting_id, max_participants, mode, recording, created_at, expires_at, closed_at
+	FROM video_rooms
+	`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRoom(row scanner) (*Room, error) {
+	r := &Room{}
+	err := row.Scan(&r.ID, &r.MeetingID, &r.MaxParticipants, &r.Mode, &r.Recording, &r.CreatedAt, &r.ExpiresAt, &r.ClosedAt)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
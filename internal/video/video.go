@@ -0,0 +1,113 @@
+package video
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrRoomNotFound = errors.New("video room not found")
+	ErrRoomExists   = errors.New("video room already exists")
+	ErrRoomClosed   = errors.New("video room is closed")
+	ErrRoomFull     = errors.New("video room is full")
+	// ErrTicketInvalid is a ticket that doesn't exist, was used or expired
+	ErrTicketInvalid = errors.New("video room ticket is invalid")
+)
+
+// How media flows in a room: directly between the two participants, or
+// through the server, which forwards each participant's tracks to the rest.
+const (
+	ModeP2P = "p2p"
+	ModeSFU = "sfu"
+)
+
+// Registry keeps track of video rooms and who is in them. Only the rooms
+// themselves can be shared between API instances: a room's signaling and
+// media run in the process its participants' sockets reached, so with more
+// than one instance every socket of a room has to be routed to the same
+// one, e.g. by hashing /ws/video/{roomID} on the room ID at the load
+// balancer.
+type Registry interface {
+	// CreateRoom opens room. A meeting has at most one open room.
+	CreateRoom(ctx context.Context, room *Room) error
+	// GetRoom returns the room with the participants currently in it
+	GetRoom(ctx context.Context, id string) (*Room, error)
+	// GetRoomByMeetingID returns the meeting's open room
+	GetRoomByMeetingID(ctx context.Context, meetingID int64) (*Room, error)
+	// Join records userID entering an open room with space left. Joining
+	// again from a second connection doesn't take another place.
+	Join(ctx context.Context, roomID string, userID int64) (*Participant, error)
+	// Leave records the participant leaving. The last one out of a room
+	// whose time is up closes it.
+	Leave(ctx context.Context, participant *Participant) error
+	// SetRoomExpiry moves the end of an open room, for rescheduled meetings
+	SetRoomExpiry(ctx context.Context, id string, expiresAt time.Time) error
+	// SetRoomMode switches an open room's mode and recording, for
+	// connections made from then on
+	SetRoomMode(ctx context.Context, id string, mode string, recording bool) error
+	CloseRoom(ctx context.Context, id string) error
+	// CloseExpiredRooms closes the rooms whose time is up and returns
+	// their IDs. Expired tickets are dropped along the way.
+	CloseExpiredRooms(ctx context.Context) ([]string, error)
+	CreateTicket(ctx context.Context, ticket *Ticket) error
+	// RedeemTicket uses up the ticket and returns it, or ErrTicketInvalid
+	RedeemTicket(ctx context.Context, id string) (*Ticket, error)
+}
+
+// Room is where a meeting's participants meet. It can be joined until
+// ExpiresAt, a while after the meeting ends. The SFU records what the
+// mentor and the mentee publish in a room with Recording set.
+type Room struct {
+	ID              string        `json:"id"`
+	MeetingID       int64         `json:"meeting_id"`
+	MaxParticipants int           `json:"max_participants"`
+	Mode            string        `json:"mode"`
+	Recording       bool          `json:"recording"`
+	CreatedAt       time.Time     `json:"created_at"`
+	ExpiresAt       time.Time     `json:"expires_at"`
+	ClosedAt        *time.Time    `json:"closed_at"`
+	Participants    []Participant `json:"participants"`
+}
+
+// Open reports whether the room can still be joined at now.
+func (r *Room) Open(now time.Time) bool {
+	return r.ClosedAt == nil && now.Before(r.ExpiresAt)
+}
+
+// Ticket lets UserID open one signaling connection to RoomID until
+// ExpiresAt. Browsers can't set headers on a WebSocket handshake, so it
+// goes in the socket's URL in place of the user's session token.
+type Ticket struct {
+	ID        string    `json:"ticket"`
+	RoomID    string    `json:"room_id"`
+	UserID    int64     `json:"-"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Participant is one stay of a user in a room, from a single connection.
+type Participant struct {
+	ID       int64      `json:"id"`
+	RoomID   string     `json:"room_id"`
+	UserID   int64      `json:"user_id"`
+	JoinedAt time.Time  `json:"joined_at"`
+	LeftAt   *time.Time `json:"left_at"`
+}
+
+// users counts the distinct users among participants.
+func users(participants []Participant) int {
+	seen := map[int64]bool{}
+	for _, p := range participants {
+		seen[p.UserID] = true
+	}
+	return len(seen)
+}
+
+func hasUser(participants []Participant, userID int64) bool {
+	for _, p := range participants {
+		if p.UserID == userID {
+			return true
+		}
+	}
+	return false
+}